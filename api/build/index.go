@@ -2,44 +2,86 @@ package build
 
 import (
 	"context"
-	"crypto/hmac"
-	"crypto/sha1"
-	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/slack-go/slack"
 
 	"github.com/NWACus/expo-slack-webhook/config"
 	"github.com/NWACus/expo-slack-webhook/expo"
+	"github.com/NWACus/expo-slack-webhook/github"
+	"github.com/NWACus/expo-slack-webhook/internal/failures"
+	"github.com/NWACus/expo-slack-webhook/internal/messages"
+	"github.com/NWACus/expo-slack-webhook/internal/previews"
+	"github.com/NWACus/expo-slack-webhook/internal/recovery"
+	"github.com/NWACus/expo-slack-webhook/internal/verify"
+	"github.com/NWACus/expo-slack-webhook/issues"
+	"github.com/NWACus/expo-slack-webhook/pairing"
+	"github.com/NWACus/expo-slack-webhook/threads"
+	"github.com/NWACus/expo-slack-webhook/topic"
 )
 
 type WebhookPayload struct {
-	Id        string        `json:"id"`
-	AppId     string        `json:"appId"`
-	Details   string        `json:"buildDetailsPageUrl"`
-	Platform  expo.Platform `json:"platform"`
-	Status    expo.Status   `json:"status"`
-	Metadata  Metadata      `json:"metadata"`
-	Error     expo.Error    `json:"error"`
-	CreatedAt string        `json:"createdAt"`
+	Id          string        `json:"id"`
+	AppId       string        `json:"appId"`
+	Details     string        `json:"buildDetailsPageUrl"`
+	Platform    expo.Platform `json:"platform"`
+	Status      expo.Status   `json:"status"`
+	Metadata    Metadata      `json:"metadata"`
+	Error       expo.Error    `json:"error"`
+	CreatedAt   string        `json:"createdAt"`
+	UpdatedAt   string        `json:"updatedAt"`
+	CompletedAt string        `json:"completedAt"`
 }
 
+const (
+	// pageSize is the number of builds we fetch from Expo at once.
+	pageSize = 10
+	// trendBuilds is how many of the builds before the one we were notified about we compare its duration
+	// with.
+	trendBuilds = 10
+	// fetchTimeout bounds all of the lookups we make for context together, so that slow Expo queries can't
+	// hold up the message, which matters on serverless where there's little time left after responding.
+	fetchTimeout = 15 * time.Second
+	// logLines is how much of the end of a failed build's log we show.
+	logLines = 40
+)
+
 type Metadata struct {
 	AppName                   string `json:"appName"`
+	Distribution              string `json:"distribution"`
+	BuildProfile              string `json:"buildProfile"`
 	expo.BuildVersionMetadata `json:",inline"`
 }
 
+// validate checks that the payload has the fields we need to look the build up in Expo and describe it.
+func (w *WebhookPayload) validate() error {
+	switch {
+	case w.Id == "":
+		return fmt.Errorf("missing id")
+	case w.AppId == "":
+		return fmt.Errorf("missing appId")
+	case w.Platform == "":
+		return fmt.Errorf("missing platform")
+	case w.Status == "":
+		return fmt.Errorf("missing status")
+	case w.Metadata.Channel == "":
+		return fmt.Errorf("missing metadata.channel")
+	}
+	return nil
+}
+
 // Handler is the entrypoint for Vercel serverless functions.
 func Handler(w http.ResponseWriter, r *http.Request) {
 	cfg, err := config.LoadFromEnv()
 	if err != nil {
-		log.Printf("failed to load config: %v", err)
+		slog.Error("failed to load config", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -49,75 +91,450 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 
 // Handle consumes the webhook and posts the data to Slack.
 func Handle(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
-	log.Printf("Submission webhook received")
-	if r.Method != "POST" {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+	logger := cfg.Logger.With("webhook", "build")
+	logger.Info("build webhook received")
+	body, ok := verify.Read(cfg, logger, w, r, "expo-signature")
+	if !ok {
 		return
 	}
 
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		log.Printf("failed to read request body: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	// one deployment can serve several channels, with a webhook URL registered in Expo for each
+	ctx := config.WithChannel(r.Context(), r.URL.Query().Get("channel"))
+	Process(ctx, cfg, logger, w, body)
+}
+
+// Process handles a verified build payload, responding to the webhook and then posting to Slack.
+func Process(ctx context.Context, cfg *config.Config, logger *slog.Logger, w http.ResponseWriter, body []byte) {
+	payload := WebhookPayload{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		logger.Error("failed to unmarshal payload", "error", err)
+		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	digest := hmac.New(sha1.New, []byte(cfg.ExpoHMACSecret))
-	digest.Write(body)
-	receivedSignature := r.Header.Get("expo-signature")
-	log.Printf("Received signature: %v\n", receivedSignature)
-	expectedSignature := fmt.Sprintf("sha1=%v", hex.EncodeToString(digest.Sum(nil)))
-	if expectedSignature != receivedSignature {
-		log.Printf("Invalid HMAC, received %v, expected %v\n", receivedSignature, expectedSignature)
-		w.WriteHeader(http.StatusUnauthorized)
+	if err := payload.validate(); err != nil {
+		logger.Error("invalid payload", "build_id", payload.Id, "error", err)
+		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-
-	if _, debug := os.LookupEnv("DEBUG"); debug {
-		log.Printf("Received payload: %v\n", string(body))
+	if !cfg.KnowsApp(payload.AppId) {
+		logger.Warn("rejecting payload for unknown app", "build_id", payload.Id, "app_id", payload.AppId)
+		w.WriteHeader(http.StatusForbidden)
+		return
 	}
 
-	payload := WebhookPayload{}
-	if err := json.Unmarshal(body, &payload); err != nil {
-		log.Printf("failed to unmarshal payload: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+	// builds can run for much longer than the replay window, so we check when the build last changed
+	if err := cfg.CheckPayloadAge(payload.UpdatedAt, time.Now()); err != nil {
+		logger.Warn("rejecting payload as a possible replay", "error", err)
+		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
 
 	// we want to signal to Expo that we got the webhook OK as soon as we can, as they have short timeouts on this
 	w.WriteHeader(http.StatusOK)
 
-	log.Printf("Recieved build webhook for %s %s (%s).\n", payload.Metadata.AppName, payload.Metadata.AppVersion, payload.Metadata.AppBuildVersion)
+	logger = logger.With("build_id", payload.Id, "app_id", payload.AppId, "platform", payload.Platform, "status", payload.Status, "channel", payload.Metadata.Channel)
+	logger.Info("received build webhook", "app_name", payload.Metadata.AppName, "app_version", payload.Metadata.AppVersion, "app_build_version", payload.Metadata.AppBuildVersion)
 
 	// we can handle forwarding the data to Slack on our own time
-	handlePayload(r.Context(), cfg, &payload)
+	cfg.Workers.Go(ctx, func(ctx context.Context) {
+		handlePayload(ctx, cfg, logger, &payload)
+	})
 }
 
-func handlePayload(ctx context.Context, cfg *config.Config, w *WebhookPayload) {
-	previousBuild, err := fetchPreviousBuild(ctx, cfg, w)
+// Render builds the message for a build payload without looking anything up, so that message templates
+// can be tried out offline. Sections needing context from Expo, like the previous build, are left out.
+func Render(cfg *config.Config, body []byte) ([]slack.Block, error) {
+	payload := WebhookPayload{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal payload: %v", err)
+	}
+	if err := payload.validate(); err != nil {
+		return nil, fmt.Errorf("invalid payload: %v", err)
+	}
+	return blocksFor(cfg, &payload, lookups{})
+}
+
+func handlePayload(ctx context.Context, cfg *config.Config, logger *slog.Logger, w *WebhookPayload) {
+	// this is on GitHub, so it's done whether or not the build is posted
+	if err := reportOnGitHub(ctx, cfg, logger, w); err != nil {
+		logger.Warn("failed to report build on GitHub", "error", err)
+	}
+	if !cfg.ShouldNotify("build", w.Platform, w.Status) {
+		logger.Info("build filtered out, not posting to Slack")
+		return
+	}
+
+	// the lookups are independent, so we make them at once under one deadline and send whatever we got back
+	// by then; the log and app sizes need the builds, so they follow on from them
+	var (
+		wg                          sync.WaitGroup
+		build, previousBuild        *expo.Build
+		earlierBuilds               []expo.Build
+		logs                        []string
+		size                        *expo.SizeChange
+		previousUpdate              *expo.Update
+		app                         *expo.App
+		buildErr, updateErr, appErr error
+		logsErr, sizeErr            error
+	)
+	lookupCtx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		build, earlierBuilds, buildErr = fetchBuilds(lookupCtx, cfg, logger, w)
+		if len(earlierBuilds) > 0 {
+			previousBuild = &earlierBuilds[0]
+		}
+		var artifacts sync.WaitGroup
+		artifacts.Add(2)
+		go func() {
+			defer artifacts.Done()
+			logs, logsErr = fetchLogTail(lookupCtx, cfg, w, build)
+		}()
+		go func() {
+			defer artifacts.Done()
+			size, sizeErr = fetchSizeChange(lookupCtx, cfg, w, build, previousBuild)
+		}()
+		artifacts.Wait()
+	}()
+	go func() {
+		defer wg.Done()
+		previousUpdate, updateErr = fetchPreviousUpdate(lookupCtx, cfg, logger, w)
+	}()
+	go func() {
+		defer wg.Done()
+		app, appErr = cfg.ExpoClient.FetchApp(lookupCtx, w.AppId)
+	}()
+	wg.Wait()
+	if buildErr != nil {
+		logger.Error("failed to fetch previous build", "error", buildErr)
+	}
+	if updateErr != nil {
+		logger.Error("failed to fetch previous update", "error", updateErr)
+	}
+	if appErr != nil {
+		logger.Error("failed to fetch app", "error", appErr)
+	}
+	if logsErr != nil {
+		logger.Error("failed to fetch build log", "error", logsErr)
+	}
+	if sizeErr != nil {
+		// the size is a nicety, so we don't note that it's missing
+		logger.Warn("failed to fetch app sizes", "error", sizeErr)
+	}
+	// the repository may be the one linked to the app, so this waits for the other lookups
+	changelog, err := fetchChangelog(lookupCtx, cfg, w, app, previousBuild)
 	if err != nil {
-		log.Printf("failed to fetch previous build: %v", err)
+		// the compare link is still there, so we don't note that the changelog is missing or incomplete
+		logger.Warn("failed to fetch changelog", "error", err)
+	}
+	if err := commentOnPullRequest(ctx, cfg, logger, w, app, build, previousUpdate); err != nil {
+		logger.Warn("failed to comment on pull request", "error", err)
 	}
 
-	previousUpdate, err := fetchPreviousUpdate(ctx, cfg, w)
+	event := eventFor(cfg, w, app, previousBuild)
+	escalation := cfg.EscalationFor(event)
+	blocks, err := blocksFor(cfg, w, lookups{
+		App:            app,
+		Build:          build,
+		Mention:        mentionsFor(ctx, cfg, logger, w, build, escalation),
+		Logs:           logs,
+		LogsErr:        logsErr,
+		Size:           size,
+		Trend:          durationTrend(build, earlierBuilds),
+		Changelog:      changelog,
+		PreviousBuild:  previousBuild,
+		BuildErr:       buildErr,
+		PreviousUpdate: previousUpdate,
+		UpdateErr:      updateErr,
+	})
 	if err != nil {
-		log.Printf("failed to fetch previous update: %v", err)
+		logger.Error("failed to get blocks", "error", err)
+		return
 	}
 
-	blocks, err := blocksFor(cfg, w, previousBuild, previousUpdate)
+	text := messages.Fallback(event)
+	channel := cfg.ChannelFor(ctx, event)
+	if w.Status == expo.StatusFinished && w.Metadata.Channel == topic.ReleaseChannel {
+		if err := cfg.Topic.Released(ctx, channel, w.AppId, w.Metadata.AppName, w.Platform, expo.FormatVersion(w.Metadata.BuildVersionMetadata)); err != nil {
+			logger.Warn("failed to update channel topic", "error", err)
+		}
+	}
+	if w.Status == expo.StatusFinished {
+		if err := cfg.Releases.Built(ctx, channel, w.AppId, w.Metadata.AppName, w.Metadata.Channel, w.Platform, expo.FormatVersion(w.Metadata.BuildVersionMetadata)); err != nil {
+			logger.Warn("failed to record release", "error", err)
+		}
+	}
+	if w.Status == expo.StatusFinished {
+		reply, err := recoveredBlocks(cfg, w, app)
+		if err != nil {
+			logger.Warn("failed to get blocks for the recovery", "error", err)
+		} else if reply != nil && recovery.Recovered(ctx, cfg, logger, failureKey(w), config.Message{Text: text, Channel: channel, Event: event}, reply) {
+			return
+		}
+	}
+	if escalation == config.EscalationPage {
+		if err := cfg.PostEscalation(ctx, config.Message{Blocks: blocks, Text: text, Event: event}); err != nil {
+			logger.Error("failed to post message to the escalation channel", "error", err)
+			cfg.Metrics.SlackPostFailed()
+		}
+	}
+	if ts, ok := combined(ctx, cfg, logger, w, channel, config.Message{Blocks: blocks, Text: text, Channel: channel, Event: event}); ok {
+		attachInstallCode(ctx, cfg, logger, w, channel, ts)
+		return
+	}
+
+	logger.Info("posting to Slack", "blocks", len(blocks))
+	ts, err := cfg.Poster.Post(ctx, config.Message{Blocks: blocks, Text: text, Channel: channel, Event: event})
 	if err != nil {
-		log.Printf("failed to get blocks: %v", err)
+		logger.Error("failed to post message", "error", err)
+		cfg.Metrics.SlackPostFailed()
 		return
 	}
+	if err := cfg.Threads.Remember(ctx, channel, w.Metadata.GitCommitHash, ts); err != nil {
+		logger.Warn("failed to remember message for threading", "error", err)
+	}
+	if err := cfg.Threads.RememberBuild(ctx, w.Id, threads.Message{Channel: channel, TS: ts}); err != nil {
+		logger.Warn("failed to remember message for threading", "error", err)
+	}
+	attachInstallCode(ctx, cfg, logger, w, channel, ts)
+	if w.Status == expo.StatusErrored {
+		if err := recovery.Failed(ctx, cfg, failureKey(w), threads.Message{Channel: channel, TS: ts}); err != nil {
+			logger.Warn("failed to remember message for the failure", "error", err)
+		}
+	}
+	cfg.Pairs.Hold(w.Metadata.GitCommitHash, w.Metadata.Channel, pairing.Entry{Platform: w.Platform, Channel: channel, TS: ts, Blocks: blocks, Text: text})
+}
+
+// failureKey identifies what a rebuild would be for: the same commit, on the same channel and platform.
+// Without a commit, there's no telling.
+func failureKey(w *WebhookPayload) string {
+	if w.Metadata.GitCommitHash == "" {
+		return ""
+	}
+	return fmt.Sprintf("build/%s/%s/%s/%s", w.AppId, w.Platform, w.Metadata.Channel, w.Metadata.GitCommitHash)
+}
+
+// templateData is what the build templates are rendered with; see the templates package.
+type templateData struct {
+	Payload    *WebhookPayload
+	App        *expo.App
+	Build      *expo.Build
+	Previous   *expo.Build
+	Repository string
+	Project    expo.Project
+	Mention    string
+	Diagnosis  *expo.Diagnosis
+	Size       *expo.SizeChange
+	Trend      *expo.DurationTrend
+	Changelog  *github.Changelog
+	Issues     []issues.Issue
+}
+
+// recoveredBlocks is the reply to the message for a failed build of the same commit when a rebuild succeeds.
+func recoveredBlocks(cfg *config.Config, w *WebhookPayload, app *expo.App) ([]slack.Block, error) {
+	block, err := messages.Section(cfg.Templates, "build.recovered", templateData{Payload: w, App: app, Repository: cfg.RepositoryFor(w.AppId, app), Project: app.Project()})
+	if err != nil || block == nil {
+		return nil, err
+	}
+	return []slack.Block{block}, nil
+}
+
+// combined folds the message into the one recently posted for the build of the other platform from the same
+// commit, if there is one, reporting whether it did so and the timestamp of the message it updated.
+func combined(ctx context.Context, cfg *config.Config, logger *slog.Logger, w *WebhookPayload, channel string, msg config.Message) (string, bool) {
+	updater, ok := cfg.Poster.(config.Updater)
+	if !ok {
+		return "", false
+	}
+	partner, ok := cfg.Pairs.Take(w.Metadata.GitCommitHash, w.Metadata.Channel, channel, w.Platform)
+	if !ok {
+		return "", false
+	}
+	blocks := append([]slack.Block{}, partner.Blocks...)
+	blocks = append(blocks, slack.NewDividerBlock())
+	msg.Blocks = append(blocks, msg.Blocks...)
+	msg.Text = partner.Text + " " + msg.Text
+	logger.Info("combining with the message for the other platform's build", "partner_platform", partner.Platform, "blocks", len(msg.Blocks))
+	if err := updater.Update(ctx, partner.TS, msg); err != nil {
+		// we can still post the build on its own
+		logger.Error("failed to update message", "error", err)
+		cfg.Metrics.SlackPostFailed()
+		return "", false
+	}
+	return partner.TS, true
+}
+
+// attachInstallCode replies to the message for a successful internal distribution build with a QR code
+// linking to the build's details page, which offers to install it.
+func attachInstallCode(ctx context.Context, cfg *config.Config, logger *slog.Logger, w *WebhookPayload, channel, ts string) {
+	if ts == "" || w.Status != expo.StatusFinished || !strings.EqualFold(w.Metadata.Distribution, expo.DistributionInternal) {
+		return
+	}
+	title := cfg.Catalog.T("Scan to install %s %s", w.Metadata.AppName, expo.FormatVersion(w.Metadata.BuildVersionMetadata))
+	if err := cfg.InstallQRCodes.Attach(ctx, channel, ts, w.Details, title); err != nil {
+		logger.Warn("failed to attach install QR code", "error", err)
+	}
+}
+
+// fetchLogTail reads the end of the log for a failed build, which we need to have found in Expo for its log
+// files. Builds log to a new file each time they're retried, so the last one is from the failure.
+func fetchLogTail(ctx context.Context, cfg *config.Config, w *WebhookPayload, build *expo.Build) ([]string, error) {
+	if w.Status != expo.StatusErrored || build == nil || len(build.LogFiles) == 0 {
+		return nil, nil
+	}
+	return cfg.ExpoClient.FetchLogTail(ctx, build.LogFiles[len(build.LogFiles)-1], logLines)
+}
 
-	log.Printf("Posting %d blocks to Slack channel %s", len(blocks), cfg.SlackChannel)
-	_, _, err = cfg.SlackClient.PostMessageContext(ctx, cfg.SlackChannel, slack.MsgOptionBlocks(blocks...), slack.MsgOptionDisableLinkUnfurl(), slack.MsgOptionDisableMediaUnfurl())
+// fetchChangelog lists the commits made since the previous build, when both are of known commits, with the
+// pull requests they were merged in. Commits whose pull requests couldn't be found are listed on their own,
+// with the error.
+func fetchChangelog(ctx context.Context, cfg *config.Config, w *WebhookPayload, app *expo.App, previous *expo.Build) (*github.Changelog, error) {
+	if cfg.ChangelogCommits == 0 || previous == nil || previous.GitCommitHash == "" || w.Metadata.GitCommitHash == "" || previous.GitCommitHash == w.Metadata.GitCommitHash {
+		return nil, nil
+	}
+	repository := cfg.RepositoryFor(w.AppId, app)
+	changelog, err := cfg.GitHub.Compare(ctx, repository, previous.GitCommitHash, w.Metadata.GitCommitHash, cfg.ChangelogCommits)
 	if err != nil {
-		log.Printf("failed to post message: %v", err)
+		return nil, err
+	}
+	return changelog, cfg.GitHub.PullRequests(ctx, repository, changelog)
+}
+
+// reportOnGitHub reports the build on the commit it was made from, as a status like "EAS build
+// ios/production: success", and as a deployment to its environment, each as configured and linking to the
+// build, and counts it towards or ends a streak of failures there. The app is fetched for the repository
+// linked to it, which is usually cached. Failures are diagnosed from their errors alone, as their logs are only
+// fetched for builds we post.
+func reportOnGitHub(ctx context.Context, cfg *config.Config, logger *slog.Logger, w *WebhookPayload) error {
+	if !cfg.CommitStatuses && !cfg.Deployments && cfg.FailureIssueThreshold == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	app, err := cfg.ExpoClient.FetchApp(ctx, w.AppId)
+	if err != nil {
+		return fmt.Errorf("failed to fetch app: %w", err)
+	}
+	repository := cfg.RepositoryFor(w.AppId, app)
+	environment := environmentFor(w)
+
+	var errs []error
+	key := fmt.Sprintf("build/%s/%s/%s", w.AppId, w.Platform, environment)
+	switch w.Status {
+	case expo.StatusFinished:
+		errs = append(errs, failures.Succeeded(ctx, cfg, logger, key, w.Details))
+	case expo.StatusErrored:
+		title := fmt.Sprintf("%s builds of %s on %s keep failing", expo.PlatformDisplay(nil, w.Platform), w.Metadata.AppName, environment)
+		errs = append(errs, failures.Failed(ctx, cfg, logger, key, repository, title, failures.Build{
+			URL:       w.Details,
+			Version:   expo.FormatVersion(w.Metadata.BuildVersionMetadata),
+			Commit:    w.Metadata.GitCommitHash,
+			Error:     w.Error,
+			Diagnosis: expo.Diagnose(w.Error, nil),
+		}))
+	}
+	// statuses and deployments are of the commit
+	if w.Metadata.GitCommitHash == "" {
+		return errors.Join(errs...)
+	}
+	state := github.StatePending
+	switch w.Status {
+	case expo.StatusFinished:
+		state = github.StateSuccess
+	case expo.StatusErrored:
+		state = github.StateFailure
+	case expo.StatusCancelled:
+		state = github.StateError
+	}
+	if cfg.CommitStatuses {
+		errs = append(errs, cfg.GitHub.SetStatus(ctx, repository, w.Metadata.GitCommitHash, github.Status{
+			State:       state,
+			TargetURL:   w.Details,
+			Description: "Build " + expo.StatusDisplay(nil, w.Status),
+			Context:     fmt.Sprintf("EAS build %s/%s", w.Platform, w.Metadata.BuildProfile),
+		}))
+	}
+	if cfg.Deployments {
+		errs = append(errs, cfg.GitHub.Deploy(ctx, repository, github.Deployment{
+			Ref:         w.Metadata.GitCommitHash,
+			Environment: environment,
+			Production:  environment == topic.ReleaseChannel,
+			Description: fmt.Sprintf("%s build %s", expo.PlatformDisplay(nil, w.Platform), expo.StatusDisplay(nil, w.Status)),
+			State:       state,
+			URL:         w.Details,
+		}))
+	}
+	return errors.Join(errs...)
+}
+
+// commentOnPullRequest lists a preview build in the comment on the pull request it was made from, with the
+// latest update on its channel for its runtime. The ref the build was made from is only known once we've found
+// the build in Expo.
+func commentOnPullRequest(ctx context.Context, cfg *config.Config, logger *slog.Logger, w *WebhookPayload, app *expo.App, build *expo.Build, update *expo.Update) error {
+	if !cfg.PreviewComments || w.Metadata.Channel != previews.Channel || build == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	repository := cfg.RepositoryFor(w.AppId, app)
+	pull, err := previews.PullRequest(ctx, cfg, repository, build.GitRef)
+	if err != nil || pull == nil {
+		return err
+	}
+	entry := previews.Build{
+		Platform:       w.Platform,
+		Status:         w.Status,
+		Version:        expo.FormatVersion(w.Metadata.BuildVersionMetadata),
+		Commit:         w.Metadata.GitCommitHash,
+		URL:            w.Details,
+		Channel:        w.Metadata.Channel,
+		RuntimeVersion: build.RuntimeVersion,
+	}
+	if update != nil && update.RuntimeVersion == build.RuntimeVersion {
+		entry.Update = &previews.Update{URL: expo.ProjectURL(app.Project(), "updates", update.Id), Commit: update.GitCommitHash}
+	}
+	return previews.Built(ctx, cfg, logger, repository, pull.Number, entry)
+}
+
+// environmentFor names what the build is for after its channel, or its build profile if it has none.
+func environmentFor(w *WebhookPayload) string {
+	if w.Metadata.Channel == "" {
+		return w.Metadata.BuildProfile
 	}
+	return w.Metadata.Channel
 }
 
-func fetchPreviousUpdate(ctx context.Context, cfg *config.Config, w *WebhookPayload) (*expo.Update, error) {
+// fetchSizeChange compares the size of the app a successful build produced with the previous build's, if
+// that finished too. The sizes are looked up at once, as each is a request to Expo's storage.
+func fetchSizeChange(ctx context.Context, cfg *config.Config, w *WebhookPayload, build, previous *expo.Build) (*expo.SizeChange, error) {
+	if w.Status != expo.StatusFinished || build == nil || previous == nil || build.Artifacts.App() == "" || previous.Artifacts.App() == "" {
+		return nil, nil
+	}
+	var (
+		wg                   sync.WaitGroup
+		size, previousSize   int64
+		sizeErr, previousErr error
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		size, sizeErr = cfg.ExpoClient.FetchArtifactSize(ctx, build.Artifacts.App())
+	}()
+	go func() {
+		defer wg.Done()
+		previousSize, previousErr = cfg.ExpoClient.FetchArtifactSize(ctx, previous.Artifacts.App())
+	}()
+	wg.Wait()
+	if err := errors.Join(sizeErr, previousErr); err != nil {
+		return nil, err
+	}
+	return expo.NewSizeChange(build.Artifacts.App(), size, previousSize), nil
+}
+
+func fetchPreviousUpdate(ctx context.Context, cfg *config.Config, logger *slog.Logger, w *WebhookPayload) (*expo.Update, error) {
 	createdAt, err := time.Parse(time.RFC3339, w.CreatedAt)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse createdAt: %v", err)
@@ -128,134 +545,325 @@ func fetchPreviousUpdate(ctx context.Context, cfg *config.Config, w *WebhookPayl
 		return nil, fmt.Errorf("failed to fetch update channel: %v", err)
 	}
 
-	updateBranch := updateBranchFor(w.Platform, channel)
+	updateBranch, err := updateBranchFor(w.Platform, channel)
+	if err != nil {
+		// the order of the branches will have to do
+		logger.Warn("failed to follow the branch mapping", "error", err)
+	}
 	if updateBranch == "" {
 		return nil, fmt.Errorf("failed to find update branch for platform %v", w.Platform)
 	}
 
-	updates, err := cfg.ExpoClient.FetchUpdates(ctx, w.AppId, updateBranch, 10, 0)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch updates: %v", err)
-	}
-
-	return previousUpdateFor(w.Platform, w.Id, createdAt, updates)
+	return cfg.ExpoClient.FetchPreviousUpdate(ctx, w.AppId, updateBranch, w.Platform, w.Id, createdAt)
 }
 
-func updateBranchFor(platform expo.Platform, channel *expo.UpdateChannel) string {
+// updateBranchFor finds the branch the channel sends most clients updates for the platform from, following its
+// branch mapping, so that the previous update is the one most users are on. Should the mapping not say, the
+// first branch with an update for the platform is taken.
+func updateBranchFor(platform expo.Platform, channel *expo.UpdateChannel) (string, error) {
 	if channel == nil {
-		return ""
+		return "", nil
+	}
+	ids, err := channel.BranchesByAudience()
+	for _, id := range ids {
+		for _, branch := range channel.UpdateBranches {
+			if branch.Id == id && hasPlatform(branch, platform) {
+				return branch.Name, err
+			}
+		}
 	}
 	for _, branch := range channel.UpdateBranches {
-		for _, group := range branch.UpdateGroups {
-			for _, update := range group {
-				if update.Platform.Equal(platform) {
-					return update.Branch.Name
-				}
+		if hasPlatform(branch, platform) {
+			return branch.Name, err
+		}
+	}
+	return "", err
+}
+
+// hasPlatform reports whether the branch has updates for the platform.
+func hasPlatform(branch expo.UpdateBranch, platform expo.Platform) bool {
+	for _, group := range branch.UpdateGroups {
+		for _, update := range group {
+			if update.Platform.Equal(platform) {
+				return true
 			}
 		}
 	}
-	return ""
+	return false
+}
+
+// mentionsFor mentions whoever started the build when it failed, and the on-call group when it's paged for.
+func mentionsFor(ctx context.Context, cfg *config.Config, logger *slog.Logger, w *WebhookPayload, build *expo.Build, escalation config.Escalation) string {
+	var mentions []string
+	if escalation != config.EscalationQuiet && w.Status == expo.StatusErrored && build != nil {
+		mention, err := cfg.Mentions.Mention(ctx, build.InitiatingActor.Email)
+		if err != nil {
+			logger.Warn("failed to find Slack user to mention", "error", err)
+		}
+		if mention != "" {
+			mentions = append(mentions, mention)
+		}
+	}
+	if escalation == config.EscalationPage {
+		mention, err := cfg.OnCall.Mention(ctx)
+		if err != nil {
+			logger.Warn("failed to find on-call group to mention", "error", err)
+		}
+		if mention != "" {
+			mentions = append(mentions, mention)
+		}
+	}
+	return strings.Join(mentions, " ")
 }
 
-func previousUpdateFor(platform expo.Platform, id string, createdAt time.Time, updates [][]expo.Update) (*expo.Update, error) {
-	for i := 0; i < len(updates); i++ {
-		for j := 0; j < len(updates[i]); j++ {
-			if !updates[i][j].Platform.Equal(platform) || updates[i][j].Id == id {
+// fetchBuilds finds the build we were notified about, which has more detail than the payload, and up to
+// trendBuilds of the builds before it, most recent first.
+func fetchBuilds(ctx context.Context, cfg *config.Config, logger *slog.Logger, w *WebhookPayload) (*expo.Build, []expo.Build, error) {
+	// the webhook may arrive after newer builds were started, so we page through the build list until we
+	// find the build we were notified about - the builds before it are the next ones in the list, which may
+	// be on the following page
+	var (
+		current *expo.Build
+		earlier []expo.Build
+	)
+	for offset := 0; offset < cfg.ExpoClient.MaxSearched(); offset += pageSize {
+		builds, err := cfg.ExpoClient.FetchBuilds(ctx, w.AppId, w.Metadata.Channel, w.Platform, pageSize, offset)
+		if err != nil {
+			return current, earlier, fmt.Errorf("failed to fetch build list: %v", err)
+		}
+		for i := 0; i < len(builds); i++ {
+			if current != nil {
+				if len(earlier) == 0 {
+					logger.Info("found previous build", "previous_build_id", builds[i].Id)
+				}
+				earlier = append(earlier, builds[i])
+				if len(earlier) == trendBuilds {
+					return current, earlier, nil
+				}
 				continue
 			}
-			updateCreatedAt, err := time.Parse(time.RFC3339, updates[i][j].CreatedAt)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse createdAt for update %s: %v", updates[i][j].Id, err)
-			}
-			if updateCreatedAt.After(createdAt) {
-				continue
+			if builds[i].Id == w.Id {
+				current = &builds[i]
 			}
-			return &updates[i][j], nil
 		}
+		if len(builds) < pageSize {
+			break
+		}
+	}
+	if current == nil {
+		logger.Warn("did not find build in the most recent builds", "searched", cfg.ExpoClient.MaxSearched())
 	}
-	return nil, nil
+	return current, earlier, nil
 }
 
-func fetchPreviousBuild(ctx context.Context, cfg *config.Config, w *WebhookPayload) (*expo.Build, error) {
-	builds, err := cfg.ExpoClient.FetchBuilds(ctx, w.AppId, w.Metadata.Channel, w.Platform, 10, 0)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch build list: %v", err)
+// durationTrend compares how long the build ran for with the average of the earlier builds that ran. It is nil
+// when the build hasn't completed or too few earlier builds did to make a fair comparison.
+func durationTrend(current *expo.Build, earlier []expo.Build) *expo.DurationTrend {
+	if current == nil || current.Metrics == nil || current.Metrics.Duration() <= 0 {
+		return nil
 	}
-	for i := 0; i < len(builds); i++ {
-		if builds[i].Id == w.Id && i < len(builds)-1 {
-			log.Printf("Found previous build: %v", builds[i+1].Id)
-			return &builds[i+1], nil
+	var durations []time.Duration
+	for _, build := range earlier {
+		if build.Status.Equal(expo.StatusFinished) && build.Metrics != nil && build.Metrics.Duration() > 0 {
+			durations = append(durations, build.Metrics.Duration())
 		}
 	}
-	return nil, nil
+	return expo.NewDurationTrend(current.Metrics.Duration(), durations)
 }
 
-func blocksFor(cfg *config.Config, w *WebhookPayload, build *expo.Build, update *expo.Update) ([]slack.Block, error) {
+// eventFor summarises the build for destinations that don't render Slack blocks.
+func eventFor(cfg *config.Config, w *WebhookPayload, app *expo.App, previous *expo.Build) config.Event {
+	event := config.Event{
+		Kind:       "build",
+		Id:         w.Id,
+		AppId:      w.AppId,
+		AppName:    w.Metadata.AppName,
+		Platform:   w.Platform,
+		Status:     w.Status,
+		Channel:    w.Metadata.Channel,
+		Version:    expo.FormatVersion(w.Metadata.BuildVersionMetadata),
+		Commit:     w.Metadata.GitCommitHash,
+		Repository: cfg.RepositoryFor(w.AppId, app),
+		Title:      cfg.Catalog.T("%s build of %s %s %s.", expo.PlatformDisplay(cfg.Catalog, w.Platform), w.Metadata.AppName, expo.FormatVersion(w.Metadata.BuildVersionMetadata), expo.StatusDisplay(cfg.Catalog, w.Status)),
+		DetailsURL: w.Details,
+	}
+	if previous != nil {
+		event.PreviousCommit = previous.GitCommitHash
+	}
+	if w.Error.Failed() {
+		event.Error = w.Error.Error()
+	}
+	return event
+}
+
+// lookups are what we found out about a build beyond its payload, each left empty if we didn't look it up or
+// didn't find it.
+type lookups struct {
+	App *expo.App
+	// Build is what Expo told us about the build itself.
+	Build   *expo.Build
+	Mention string
+	// Logs are the end of the build's log, if it failed.
+	Logs      []string
+	LogsErr   error
+	Size      *expo.SizeChange
+	Trend     *expo.DurationTrend
+	Changelog *github.Changelog
+	// PreviousBuild and PreviousUpdate are the last build and update on the build's channel before it.
+	PreviousBuild  *expo.Build
+	BuildErr       error
+	PreviousUpdate *expo.Update
+	UpdateErr      error
+}
+
+// blocksFor builds the message for the build, with whatever we found out about it. The errors from fetching
+// the log and the previous build and update are noted at the end of the message, which is otherwise sent
+// without that context.
+func blocksFor(cfg *config.Config, w *WebhookPayload, found lookups) ([]slack.Block, error) {
+	app, current := found.App, found.Build
+	data := templateData{Payload: w, App: app, Build: current, Previous: found.PreviousBuild, Repository: cfg.RepositoryFor(w.AppId, app), Project: app.Project(), Mention: found.Mention, Diagnosis: expo.Diagnose(w.Error, found.Logs), Size: found.Size, Trend: found.Trend, Changelog: found.Changelog, Issues: cfg.IssueTrackers.Find(found.Changelog.Texts()...)}
+	var iconURL string
+	if app != nil {
+		iconURL = app.IconUrl
+	}
+	title, err := cfg.Templates.Render("build.title", data)
+	if err != nil {
+		return nil, err
+	}
 	blocks := []slack.Block{
-		&slack.HeaderBlock{
+		&slack.SectionBlock{
 			Type: slack.MBTSection,
 			Text: &slack.TextBlockObject{
 				Type: slack.MarkdownType,
-				Text: fmt.Sprintf(`:hammer_and_wrench:%s%s| %s build of %s %s %s.`, expo.PlatformEmoji(w.Platform), expo.StatusEmoji(w.Status), expo.PlatformDisplay(w.Platform), w.Metadata.AppName, expo.FormatBuildVersion(w.Metadata.BuildVersionMetadata), expo.StatusDisplay(w.Status)),
+				Text: title,
 			},
+			Accessory: messages.IconAccessory(iconURL),
 		},
 	}
-	if build != nil {
-		createdAt, err := time.Parse(time.RFC3339, build.CreatedAt)
+	for _, name := range []string{"build.commit", "build.dirty"} {
+		block, err := messages.Section(cfg.Templates, name, data)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse createdAt for build %s: %v", build.Id, err)
+			return nil, err
+		}
+		if block != nil {
+			blocks = append(blocks, block)
 		}
-		blocks = append(blocks, &slack.SectionBlock{
-			Type: slack.MBTSection,
-			Text: &slack.TextBlockObject{
-				Type: slack.MarkdownType,
-				Text: fmt.Sprintf(`The <https://expo.dev/accounts/nwac/projects/avalanche-forecast/builds/%s|previous build>, %s, was published %s ago. See the changelog on <https://github.com/NWACus/avy/compare/%s...%s|GitHub>`, build.Id, expo.FormatBuildVersion(build.BuildVersionMetadata), formatDuration(time.Since(createdAt)), build.GitCommitHash, w.Metadata.GitCommitHash),
-			},
-		})
 	}
-	if update != nil {
-		createdAt, err := time.Parse(time.RFC3339, update.CreatedAt)
+	if cfg.BuildLayout == config.BuildLayoutFields {
+		blocks = append(blocks, fieldsBlock(cfg, w, current, data.Repository, data.Project))
+	} else {
+		if block := messages.BuildProfile(cfg.Catalog, w.Metadata.BuildProfile, w.Metadata.Distribution); block != nil {
+			blocks = append(blocks, block)
+		}
+		block, err := messages.Context(cfg.Templates, data, "build.metrics", "build.actor")
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse createdAt for update %s: %v", update.Id, err)
+			return nil, err
 		}
-		blocks = append(blocks, &slack.SectionBlock{
-			Type: slack.MBTSection,
-			Text: &slack.TextBlockObject{
-				Type: slack.MarkdownType,
-				Text: fmt.Sprintf(`The <https://expo.dev/accounts/nwac/projects/avalanche-forecast/updates/%s|previous update>, for commit <https://github.com/NWACus/avy/commit/%s|%s>, was published %s ago. See the changelog on <https://github.com/NWACus/avy/compare/%s...%s|GitHub>`, update.Id, update.GitCommitHash, update.GitCommitHash[0:7], formatDuration(time.Since(createdAt)), update.GitCommitHash, w.Metadata.GitCommitHash),
-			},
-		})
-	}
-	blocks = append(blocks, &slack.SectionBlock{
-		Type: slack.MBTSection,
-		Text: &slack.TextBlockObject{
-			Type: slack.MarkdownType,
-			Text: func() string {
-				msg := ""
-				if w.Error.Failed() {
-					msg += fmt.Sprintf("Error %s\n", w.Error.Error())
-				}
-				msg += fmt.Sprintf("See build details <%s|here>.", w.Details)
-				return msg
-			}(),
-		},
-	})
+		if block != nil {
+			blocks = append(blocks, block)
+		}
+	}
+	if found.PreviousBuild != nil {
+		for _, name := range []string{"build.previous", "build.changelog", "build.size"} {
+			block, err := messages.Section(cfg.Templates, name, data)
+			if err != nil {
+				return nil, err
+			}
+			if block != nil {
+				blocks = append(blocks, block)
+			}
+		}
+	}
+	if found.PreviousUpdate != nil {
+		block, err := messages.PreviousUpdate(cfg.Templates, data.Repository, data.Project, found.PreviousUpdate, w.Metadata.GitCommitHash)
+		if err != nil {
+			return nil, err
+		}
+		if block != nil {
+			blocks = append(blocks, block)
+		}
+	}
+	block, err := messages.Section(cfg.Templates, "build.details", data)
+	if err != nil {
+		return nil, err
+	}
+	if block != nil {
+		blocks = append(blocks, block)
+	}
+	if block := messages.LogExcerpt(cfg.Catalog, found.Logs); block != nil {
+		blocks = append(blocks, block)
+	}
+	block, err = messages.Section(cfg.Templates, "build.issues", data)
+	if err != nil {
+		return nil, err
+	}
+	if block != nil {
+		blocks = append(blocks, block)
+	}
+	var failed []string
+	if found.LogsErr != nil {
+		failed = append(failed, "build log")
+	}
+	if found.BuildErr != nil {
+		failed = append(failed, "previous build")
+	}
+	if found.UpdateErr != nil {
+		failed = append(failed, "previous update")
+	}
+	if block := messages.Degraded(cfg.Catalog, failed...); block != nil {
+		blocks = append(blocks, block)
+	}
 	return blocks, nil
 }
 
-func formatDuration(d time.Duration) string {
-	switch {
-	case d < time.Minute:
-		return fmt.Sprintf("%d seconds", int(d.Seconds()))
-	case d < time.Hour:
-		return fmt.Sprintf("%d minutes", int(d.Minutes()))
-	case d < 24*time.Hour:
-		return fmt.Sprintf("%d hours", int(d.Hours()))
-	case d < 30*24*time.Hour:
-		return fmt.Sprintf("%d days", int(d.Hours()/24))
-	case d < 365*24*time.Hour:
-		return fmt.Sprintf("%d months", int(d.Hours()/(30*24)))
-	default:
-		return fmt.Sprintf("%d years", int(d.Hours()/(365*24)))
+// fieldsBlock lists the build's details side by side, for the fields layout. Details we don't have, like the
+// duration of a build that hasn't completed, are left out.
+func fieldsBlock(cfg *config.Config, w *WebhookPayload, current *expo.Build, repository string, project expo.Project) slack.Block {
+	var fields []*slack.TextBlockObject
+	field := func(label, value string) {
+		if value != "" {
+			fields = append(fields, slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*%s*\n%s", cfg.Catalog.T(label), value), false, false))
+		}
+	}
+	field("Platform", cfg.Emoji.Platform(w.Platform)+" "+expo.PlatformDisplay(cfg.Catalog, w.Platform))
+	field("Version", w.Metadata.AppVersion)
+	field("Build", w.Metadata.AppBuildVersion)
+	field("Channel", fmt.Sprintf("<%s|%s>", expo.ProjectURL(project, "channels", w.Metadata.Channel), w.Metadata.Channel))
+	if commit := w.Metadata.GitCommitHash; commit != "" {
+		field("Commit", fmt.Sprintf("<%s|%s>", expo.CommitURL(repository, commit), expo.ShortCommit(commit)))
+	}
+	field("Duration", buildDuration(cfg, w, current))
+	if current != nil && current.Metrics != nil && current.Metrics.Queue() > 0 {
+		field("Queued", expo.FormatDuration(cfg.Catalog, current.Metrics.Queue()))
+	}
+	if profile := w.Metadata.BuildProfile; profile != "" {
+		if w.Metadata.Distribution != "" {
+			// the webhook sends these in lower case, but the GraphQL API in upper case
+			profile = cfg.Catalog.T("`%s` (%s)", profile, strings.ToLower(w.Metadata.Distribution))
+		} else {
+			profile = fmt.Sprintf("`%s`", profile)
+		}
+		field("Profile", profile)
+	}
+	if current != nil {
+		field("Triggered by", expo.FormatActor(cfg.Catalog, current.InitiatingActor))
+	}
+	return slack.NewSectionBlock(nil, fields, nil)
+}
+
+// buildDuration describes how long the build ran for, as Expo measured it, or failing that, from when it
+// was created until it completed. It is empty if the build hasn't completed.
+func buildDuration(cfg *config.Config, w *WebhookPayload, current *expo.Build) string {
+	if current != nil && current.Metrics != nil && current.Metrics.Duration() > 0 {
+		return expo.FormatDuration(cfg.Catalog, current.Metrics.Duration())
+	}
+	createdAt, err := time.Parse(time.RFC3339, w.CreatedAt)
+	if err != nil {
+		return ""
+	}
+	completedAt, err := time.Parse(time.RFC3339, w.CompletedAt)
+	if err != nil {
+		return ""
 	}
+	return expo.FormatDuration(cfg.Catalog, completedAt.Sub(createdAt))
 }
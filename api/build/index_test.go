@@ -0,0 +1,63 @@
+package build_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/NWACus/expo-slack-webhook/api/build"
+	"github.com/NWACus/expo-slack-webhook/config/configtest"
+	"github.com/NWACus/expo-slack-webhook/expo/expotest"
+)
+
+var webhook = configtest.Webhook{Handle: build.Handle, Header: "expo-signature", Sample: "build.sample.json"}
+
+func TestHandle(t *testing.T) {
+	posted := webhook.PostSample(t)
+	if got, expected := posted.Text, "Android build of Avy (Preview) 1.0.0 (41) succeeded."; got != expected {
+		t.Errorf("expected text %q, got %q", expected, got)
+	}
+	configtest.ExpectTexts(t, posted.Blocks,
+		// the title, linking the commit
+		"Android build of Avy (Preview) 1.0.0 (41) [<https://github.com/NWACus/avy/commit/499a175e6eedad4c3a68be1e8d4fbc072c99aefd|499a175>]",
+		// who started the build, from Expo
+		"Triggered by jdoe.",
+		// the previous build, with the changelog between them
+		"<https://expo.dev/accounts/nwac/projects/avalanche-forecast/builds/a6b1c0a4-3a1e-4c54-9f57-0b5d0f6f2f1e|previous build>",
+		"<https://github.com/NWACus/avy/compare/8349b793e0c824f32d4619d7955f0f6b6ce29896...499a175e6eedad4c3a68be1e8d4fbc072c99aefd|GitHub>",
+		// the update the build's users had before
+		"<https://expo.dev/accounts/nwac/projects/avalanche-forecast/updates/153dc64f-88b9-44b7-bee3-ee9a576f4082|previous update>",
+		"See build details <https://expo.dev/accounts/nwac/projects/avalanche-forecast/builds/35425398-97b0-4f02-ac41-beb723090aa2|here>.",
+	)
+}
+
+func TestHandleRejectsUnsignedPayloads(t *testing.T) {
+	webhook.RejectsUnsigned(t)
+}
+
+func TestHandleCachesAcrossRequests(t *testing.T) {
+	server := expotest.NewServer()
+	defer server.Close()
+	// the serverless functions share one Config between requests, as the server does
+	cfg, poster := configtest.New(t, server, nil)
+	body := webhook.ReadSample(t)
+
+	for range 2 {
+		if w := webhook.Post(cfg, body, configtest.Sign(body)); w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+	}
+	if posted := poster.Messages(); len(posted) != 2 {
+		t.Fatalf("expected two messages, got %d", len(posted))
+	}
+	for _, operation := range []string{"ViewUpdateChannelOnApp", "AppByIdQuery"} {
+		var count int
+		for _, requested := range server.Operations() {
+			if requested == operation {
+				count++
+			}
+		}
+		if count != 1 {
+			t.Errorf("expected the second request to use the cached %s, got %d lookups", operation, count)
+		}
+	}
+}
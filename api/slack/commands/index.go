@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/slack-go/slack"
+
+	"github.com/NWACus/expo-slack-webhook/config"
+	"github.com/NWACus/expo-slack-webhook/internal/status"
+	"github.com/NWACus/expo-slack-webhook/internal/verify"
+)
+
+const usage = "Usage: `/expo status [channel]` shows the latest build and OTA update on an update channel, `production` by default."
+
+// Handler is the entrypoint for Vercel serverless functions.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.LoadFromEnv()
+	if err != nil {
+		slog.Error("failed to load config", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	Handle(cfg, w, r)
+}
+
+// Handle consumes the requests Slack sends when someone uses our slash command.
+func Handle(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	logger := cfg.Logger.With("webhook", "command")
+	logger.Info("command received")
+
+	body, ok := verify.ReadSlack(cfg, logger, w, r)
+	if !ok {
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	command, err := slack.SlashCommandParse(r)
+	if err != nil {
+		logger.Error("failed to parse command", "error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// Slack gives up on us after three seconds, so we acknowledge the command before answering it
+	w.WriteHeader(http.StatusOK)
+
+	Run(r.Context(), cfg, logger, command)
+}
+
+// Run answers the command, however it reached us. It should only be called once the command has been
+// acknowledged.
+func Run(ctx context.Context, cfg *config.Config, logger *slog.Logger, command slack.SlashCommand) {
+	logger = logger.With("command", command.Command, "text", command.Text, "user_id", command.UserID)
+	args := strings.Fields(command.Text)
+	if len(args) == 0 || args[0] != "status" || len(args) > 2 {
+		respond(ctx, logger, command, usage)
+		return
+	}
+	query := status.Query{Channel: status.DefaultChannel, Builds: true, Updates: true}
+	if len(args) == 2 {
+		query.Channel = args[1]
+	}
+	cfg.Workers.Go(ctx, func(ctx context.Context) {
+		respond(ctx, logger, command, status.Report(ctx, cfg, logger, query))
+	})
+}
+
+// respond replies to the command, only to the person who used it.
+func respond(ctx context.Context, logger *slog.Logger, command slack.SlashCommand, text string) {
+	msg := &slack.WebhookMessage{Text: text, ResponseType: slack.ResponseTypeEphemeral}
+	if err := slack.PostWebhookContext(ctx, command.ResponseURL, msg); err != nil {
+		logger.Error("failed to respond to command", "error", err)
+	}
+}
@@ -0,0 +1,106 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+
+	"github.com/NWACus/expo-slack-webhook/config"
+	"github.com/NWACus/expo-slack-webhook/internal/status"
+	"github.com/NWACus/expo-slack-webhook/internal/verify"
+)
+
+// Handler is the entrypoint for Vercel serverless functions.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.LoadFromEnv()
+	if err != nil {
+		slog.Error("failed to load config", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	Handle(cfg, w, r)
+}
+
+// Handle consumes events from Slack's Events API, answering questions people ask when they mention us.
+func Handle(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	logger := cfg.Logger.With("webhook", "event")
+	logger.Info("event received")
+
+	body, ok := verify.ReadSlack(cfg, logger, w, r)
+	if !ok {
+		return
+	}
+	event, err := slackevents.ParseEvent(json.RawMessage(body), slackevents.OptionNoVerifyToken())
+	if err != nil {
+		logger.Error("failed to parse event", "error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// Slack checks the endpoint is ours when it's configured by having us echo a challenge
+	if event.Type == slackevents.URLVerification {
+		verification, ok := event.Data.(*slackevents.EventsAPIURLVerificationEvent)
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(verification.Challenge))
+		return
+	}
+
+	// Slack retries events it doesn't see acknowledged within three seconds, which happens when we answer
+	// before responding, as the serverless functions do; we've already answered those
+	w.WriteHeader(http.StatusOK)
+	if r.Header.Get("X-Slack-Retry-Reason") == "http_timeout" {
+		logger.Info("ignoring retried event")
+		return
+	}
+
+	Dispatch(r.Context(), cfg, logger, event)
+}
+
+// Dispatch acts on the event, however it reached us. It should only be called once the event has been
+// acknowledged.
+func Dispatch(ctx context.Context, cfg *config.Config, logger *slog.Logger, event slackevents.EventsAPIEvent) {
+	if event.Type != slackevents.CallbackEvent {
+		logger.Info("ignoring event", "type", event.Type)
+		return
+	}
+	mention, ok := event.InnerEvent.Data.(*slackevents.AppMentionEvent)
+	if !ok {
+		logger.Info("ignoring event", "type", event.InnerEvent.Type)
+		return
+	}
+	if mention.BotID != "" {
+		return
+	}
+	logger = logger.With("user_id", mention.User, "channel_id", mention.Channel, "text", mention.Text)
+	cfg.Workers.Go(ctx, func(ctx context.Context) {
+		answer(ctx, cfg, logger, mention)
+	})
+}
+
+// answer replies in a thread under the mention, reporting on whatever it asked about.
+func answer(ctx context.Context, cfg *config.Config, logger *slog.Logger, mention *slackevents.AppMentionEvent) {
+	if cfg.SlackClient == nil {
+		logger.Error("can't answer mentions without a Slack bot token")
+		return
+	}
+	query := status.ParseQuestion(mention.Text)
+	logger.Info("answering mention", "channel", query.Channel, "platforms", query.Platforms, "builds", query.Builds, "updates", query.Updates)
+	thread := mention.ThreadTimeStamp
+	if thread == "" {
+		thread = mention.TimeStamp
+	}
+	text := status.Report(ctx, cfg, logger, query)
+	if _, _, err := cfg.SlackClient.PostMessageContext(ctx, mention.Channel, slack.MsgOptionText(text, false), slack.MsgOptionTS(thread)); err != nil {
+		logger.Error("failed to answer mention", "error", err)
+		cfg.Metrics.SlackPostFailed()
+	}
+}
@@ -0,0 +1,236 @@
+package interactions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+
+	"github.com/NWACus/expo-slack-webhook/config"
+	"github.com/NWACus/expo-slack-webhook/expo"
+	"github.com/NWACus/expo-slack-webhook/internal/messages"
+	"github.com/NWACus/expo-slack-webhook/internal/verify"
+)
+
+// ActionHandler acts on a press of one of the buttons in our messages. Handlers run after the interaction has
+// been acknowledged, so they reply through the interaction's response URL rather than the HTTP response.
+type ActionHandler func(ctx context.Context, cfg *config.Config, logger *slog.Logger, callback *slack.InteractionCallback, action *slack.BlockAction)
+
+// actions maps the action IDs of our buttons to their handlers.
+var actions = map[string]ActionHandler{
+	messages.PromoteUpdateAction:  promote,
+	messages.RollBackUpdateAction: rollBack,
+}
+
+// Register adds a handler for the button with the action ID, replacing any registered before. It must be
+// called before requests are handled, such as from an init function.
+func Register(actionId string, handler ActionHandler) {
+	actions[actionId] = handler
+}
+
+// Handler is the entrypoint for Vercel serverless functions.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.LoadFromEnv()
+	if err != nil {
+		slog.Error("failed to load config", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	Handle(cfg, w, r)
+}
+
+// Handle consumes the requests Slack sends when someone presses a button on one of our messages.
+func Handle(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	logger := cfg.Logger.With("webhook", "interaction")
+	logger.Info("interaction received")
+
+	body, ok := verify.ReadSlack(cfg, logger, w, r)
+	if !ok {
+		return
+	}
+
+	// the interaction is JSON, sent form-encoded as the payload field
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		logger.Error("failed to parse form", "error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var callback slack.InteractionCallback
+	if err := json.Unmarshal([]byte(form.Get("payload")), &callback); err != nil {
+		logger.Error("failed to unmarshal interaction", "error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// Slack gives up on us after three seconds, so we acknowledge the interaction before acting on it
+	w.WriteHeader(http.StatusOK)
+
+	Dispatch(r.Context(), cfg, logger, &callback)
+}
+
+// Dispatch runs the handlers for the buttons pressed in the interaction, however it reached us. It should
+// only be called once the interaction has been acknowledged.
+func Dispatch(ctx context.Context, cfg *config.Config, logger *slog.Logger, callback *slack.InteractionCallback) {
+	if callback.Type != slack.InteractionTypeBlockActions {
+		logger.Info("ignoring interaction", "type", callback.Type)
+		return
+	}
+	logger = logger.With("user_id", callback.User.ID, "channel_id", callback.Channel.ID)
+	for _, action := range callback.ActionCallback.BlockActions {
+		handler, ok := actions[action.ActionID]
+		if !ok {
+			logger.Warn("ignoring unknown action", "action_id", action.ActionID)
+			continue
+		}
+		cfg.Workers.Go(ctx, func(ctx context.Context) {
+			handler(ctx, cfg, logger.With("action_id", action.ActionID), callback, action)
+		})
+	}
+}
+
+// promote republishes the update group the button was pressed for on the branch we promote to.
+func promote(ctx context.Context, cfg *config.Config, logger *slog.Logger, callback *slack.InteractionCallback, action *slack.BlockAction) {
+	if !cfg.Promotion.Allowed(callback.User.ID) {
+		logger.Warn("user isn't allowed to promote updates")
+		respond(ctx, logger, callback, false, "Sorry, you aren't allowed to promote updates.")
+		return
+	}
+	appId, updates, ok := updateGroupFor(ctx, cfg, logger, callback, action)
+	if !ok {
+		return
+	}
+	logger = logger.With("app_id", appId, "group", updates[0].Group)
+
+	// the button is only offered for the branch we promote from, but the branch may have been configured
+	// differently when the message was posted
+	if from := updates[0].Branch.Name; !cfg.Promotion.Offered(from) {
+		logger.Warn("refusing to promote update from another branch", "branch", from)
+		respond(ctx, logger, callback, false, fmt.Sprintf("Sorry, only updates on `%s` can be promoted.", cfg.Promotion.From))
+		return
+	}
+	branch, err := cfg.ExpoClient.FetchUpdateBranch(ctx, appId, cfg.Promotion.To)
+	if err != nil {
+		logger.Error("failed to fetch branch to promote to", "error", err)
+		respond(ctx, logger, callback, false, fmt.Sprintf("Sorry, we couldn't find the `%s` branch.", cfg.Promotion.To))
+		return
+	}
+
+	message := fmt.Sprintf("Promoted from %s by %s", cfg.Promotion.From, callback.User.Name)
+	if updates[0].Message != "" {
+		message = fmt.Sprintf("%s (%s)", updates[0].Message, message)
+	}
+	published, err := cfg.ExpoClient.RepublishUpdateGroup(ctx, updates, branch.Id, message)
+	if err != nil {
+		logger.Error("failed to promote update group", "error", err)
+		respond(ctx, logger, callback, false, fmt.Sprintf("Sorry, promoting the update failed: %v", err))
+		return
+	}
+	logger.Info("promoted update group", "branch", cfg.Promotion.To, "published", len(published))
+	respond(ctx, logger, callback, true, fmt.Sprintf("<@%s> promoted this update to `%s`.", callback.User.ID, cfg.Promotion.To))
+}
+
+// rollBack undoes the update group the button was pressed for, by republishing the update before it for each
+// platform. Platforms with no earlier update for the same runtime are rolled back to the update embedded in
+// their build instead.
+func rollBack(ctx context.Context, cfg *config.Config, logger *slog.Logger, callback *slack.InteractionCallback, action *slack.BlockAction) {
+	if !slices.Contains(cfg.RollbackUsers, callback.User.ID) {
+		logger.Warn("user isn't allowed to roll back updates")
+		respond(ctx, logger, callback, false, "Sorry, you aren't allowed to roll back updates.")
+		return
+	}
+	appId, updates, ok := updateGroupFor(ctx, cfg, logger, callback, action)
+	if !ok {
+		return
+	}
+	group, branch := updates[0].Group, updates[0].Branch
+	logger = logger.With("app_id", appId, "group", group, "branch", branch.Name)
+
+	// republishing what came before an older update would undo everything published since, too
+	latest, err := cfg.ExpoClient.FetchUpdates(ctx, appId, branch.Name, 1, 0)
+	if err != nil {
+		logger.Error("failed to fetch latest update", "error", err)
+		respond(ctx, logger, callback, false, "Sorry, we couldn't check whether this is the latest update.")
+		return
+	}
+	if len(latest) == 0 || len(latest[0]) == 0 || latest[0][0].Group != group {
+		logger.Warn("refusing to roll back an update that has been superseded")
+		respond(ctx, logger, callback, false, fmt.Sprintf("Sorry, only the latest update on `%s` can be rolled back.", branch.Name))
+		return
+	}
+
+	var replacements []expo.Update
+	for _, update := range updates {
+		createdAt, err := time.Parse(time.RFC3339, update.CreatedAt)
+		if err != nil {
+			logger.Error("failed to parse createdAt", "update_id", update.Id, "error", err)
+			respond(ctx, logger, callback, false, "Sorry, we couldn't find the update to roll back to.")
+			return
+		}
+		previous, err := cfg.ExpoClient.FetchPreviousUpdate(ctx, appId, branch.Name, update.Platform, update.Id, createdAt)
+		if err != nil {
+			logger.Error("failed to fetch previous update", "update_id", update.Id, "error", err)
+			respond(ctx, logger, callback, false, "Sorry, we couldn't find the update to roll back to.")
+			return
+		}
+		if previous == nil || previous.RuntimeVersion != update.RuntimeVersion {
+			replacements = append(replacements, expo.Update{
+				Group:                group,
+				Platform:             update.Platform,
+				RuntimeVersion:       update.RuntimeVersion,
+				IsRollBackToEmbedded: true,
+			})
+			continue
+		}
+		replacements = append(replacements, *previous)
+	}
+
+	message := fmt.Sprintf("Rolled back from Slack by %s", callback.User.Name)
+	published, err := cfg.ExpoClient.RepublishUpdateGroup(ctx, replacements, branch.Id, message)
+	if err != nil {
+		logger.Error("failed to roll back update group", "error", err)
+		respond(ctx, logger, callback, false, fmt.Sprintf("Sorry, rolling back the update failed: %v", err))
+		return
+	}
+	logger.Info("rolled back update group", "published", len(published))
+	respond(ctx, logger, callback, true, fmt.Sprintf("<@%s> rolled this update back on `%s`.", callback.User.ID, branch.Name))
+}
+
+// updateGroupFor finds the updates in the group a button was pressed for, responding to the interaction
+// and returning false if they can't be found.
+func updateGroupFor(ctx context.Context, cfg *config.Config, logger *slog.Logger, callback *slack.InteractionCallback, action *slack.BlockAction) (string, []expo.Update, bool) {
+	appId, group, ok := strings.Cut(action.Value, "/")
+	if !ok || appId == "" || group == "" || !cfg.KnowsApp(appId) {
+		logger.Error("invalid update group", "value", action.Value)
+		respond(ctx, logger, callback, false, "Sorry, we couldn't tell which update this is.")
+		return "", nil, false
+	}
+	updates, err := cfg.ExpoClient.FetchUpdateGroup(ctx, group)
+	if err != nil || len(updates) == 0 {
+		logger.Error("failed to fetch update group", "group", group, "error", err)
+		respond(ctx, logger, callback, false, "Sorry, we couldn't find the update.")
+		return "", nil, false
+	}
+	return appId, updates, true
+}
+
+// respond replies to the interaction, in the message's thread for everyone to see if public, or only to
+// the person who pressed the button otherwise.
+func respond(ctx context.Context, logger *slog.Logger, callback *slack.InteractionCallback, public bool, text string) {
+	msg := &slack.WebhookMessage{Text: text, ResponseType: slack.ResponseTypeEphemeral}
+	if public {
+		msg.ResponseType = slack.ResponseTypeInChannel
+		msg.ThreadTimestamp = callback.Message.Timestamp
+	}
+	if err := slack.PostWebhookContext(ctx, callback.ResponseURL, msg); err != nil {
+		logger.Error("failed to respond to interaction", "error", err)
+	}
+}
@@ -2,39 +2,60 @@ package submit
 
 import (
 	"context"
-	"crypto/hmac"
-	"crypto/sha1"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
+	"time"
 
 	"github.com/slack-go/slack"
 
 	"github.com/NWACus/expo-slack-webhook/config"
 	"github.com/NWACus/expo-slack-webhook/expo"
+	"github.com/NWACus/expo-slack-webhook/internal/messages"
+	"github.com/NWACus/expo-slack-webhook/internal/recovery"
+	"github.com/NWACus/expo-slack-webhook/internal/verify"
+	"github.com/NWACus/expo-slack-webhook/threads"
+	"github.com/NWACus/expo-slack-webhook/topic"
 )
 
 type WebhookPayload struct {
-	Id       string        `json:"id"`
-	Details  string        `json:"submissionDetailsPageUrl"`
-	Platform expo.Platform `json:"platform"`
-	Status   expo.Status   `json:"status"`
-	Info     Info          `json:"submissionInfo"`
+	Id        string        `json:"id"`
+	AppId     string        `json:"appId"`
+	Details   string        `json:"submissionDetailsPageUrl"`
+	Platform  expo.Platform `json:"platform"`
+	Status    expo.Status   `json:"status"`
+	Info      Info          `json:"submissionInfo"`
+	UpdatedAt string        `json:"updatedAt"`
 }
 
+const (
+	// pageSize is the number of submissions we fetch from Expo at once.
+	pageSize = 10
+)
+
 type Info struct {
 	Error expo.Error `json:"error"`
 }
 
+// validate checks that the payload has the fields we need to look the submission up in Expo and describe it.
+func (w *WebhookPayload) validate() error {
+	switch {
+	case w.Id == "":
+		return fmt.Errorf("missing id")
+	case w.Platform == "":
+		return fmt.Errorf("missing platform")
+	case w.Status == "":
+		return fmt.Errorf("missing status")
+	}
+	return nil
+}
+
 // Handler is the entrypoint for Vercel serverless functions.
 func Handler(w http.ResponseWriter, r *http.Request) {
 	cfg, err := config.LoadFromEnv()
 	if err != nil {
-		log.Printf("failed to load config: %v", err)
+		slog.Error("failed to load config", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -44,97 +65,384 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 
 // Handle consumes the webhook and posts the data to Slack.
 func Handle(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
-	log.Printf("Submission webhook received")
-	if r.Method != "POST" {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+	logger := cfg.Logger.With("webhook", "submit")
+	logger.Info("submission webhook received")
+	body, ok := verify.Read(cfg, logger, w, r, "expo-signature")
+	if !ok {
 		return
 	}
 
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		log.Printf("failed to read request body: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	// one deployment can serve several channels, with a webhook URL registered in Expo for each
+	ctx := config.WithChannel(r.Context(), r.URL.Query().Get("channel"))
+	Process(ctx, cfg, logger, w, body)
+}
+
+// Process handles a verified submission payload, responding to the webhook and then posting to Slack.
+func Process(ctx context.Context, cfg *config.Config, logger *slog.Logger, w http.ResponseWriter, body []byte) {
+	payload := WebhookPayload{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		logger.Error("failed to unmarshal payload", "error", err)
+		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	digest := hmac.New(sha1.New, []byte(cfg.ExpoHMACSecret))
-	digest.Write(body)
-	receivedSignature := r.Header.Get("expo-signature")
-	log.Printf("Received signature: %v\n", receivedSignature)
-	expectedSignature := fmt.Sprintf("sha1=%v", hex.EncodeToString(digest.Sum(nil)))
-	if expectedSignature != receivedSignature {
-		log.Printf("Invalid HMAC, received %v, expected %v\n", receivedSignature, expectedSignature)
-		w.WriteHeader(http.StatusUnauthorized)
+	if err := payload.validate(); err != nil {
+		logger.Error("invalid payload", "submission_id", payload.Id, "error", err)
+		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-
-	if _, debug := os.LookupEnv("DEBUG"); debug {
-		log.Printf("Received payload: %v\n", string(body))
+	if !cfg.KnowsApp(payload.AppId) {
+		logger.Warn("rejecting payload for unknown app", "submission_id", payload.Id, "app_id", payload.AppId)
+		w.WriteHeader(http.StatusForbidden)
+		return
 	}
 
-	payload := WebhookPayload{}
-	if err := json.Unmarshal(body, &payload); err != nil {
-		log.Printf("failed to unmarshal payload: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+	// submissions can take a while to process, so we check when the submission last changed
+	if err := cfg.CheckPayloadAge(payload.UpdatedAt, time.Now()); err != nil {
+		logger.Warn("rejecting payload as a possible replay", "error", err)
+		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
 
 	// we want to signal to Expo that we got the webhook OK as soon as we can, as they have short timeouts on this
 	w.WriteHeader(http.StatusOK)
 
-	log.Printf("Received submission webhook for %s.\n", payload.Platform)
+	logger = logger.With("submission_id", payload.Id, "platform", payload.Platform, "status", payload.Status)
+	logger.Info("received submission webhook")
 
 	// we can handle forwarding the data to Slack on our own time
-	handlePayload(r.Context(), cfg, &payload)
+	cfg.Workers.Go(ctx, func(ctx context.Context) {
+		handlePayload(ctx, cfg, logger, &payload)
+	})
+}
+
+// Render builds the message for a submission payload without looking anything up, so that message
+// templates can be tried out offline. Sections needing context from Expo, like the submitted build, are
+// left out.
+func Render(cfg *config.Config, body []byte) ([]slack.Block, error) {
+	payload := WebhookPayload{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal payload: %v", err)
+	}
+	if err := payload.validate(); err != nil {
+		return nil, fmt.Errorf("invalid payload: %v", err)
+	}
+	return blocksFor(cfg, &payload, nil, "", nil, nil)
 }
 
-func handlePayload(ctx context.Context, cfg *config.Config, w *WebhookPayload) {
+func handlePayload(ctx context.Context, cfg *config.Config, logger *slog.Logger, w *WebhookPayload) {
+	if !cfg.ShouldNotify("submit", w.Platform, w.Status) {
+		logger.Info("submission filtered out, not posting to Slack")
+		return
+	}
+
 	submission, err := cfg.ExpoClient.FetchSubmission(ctx, w.Id)
 	if err != nil {
-		log.Printf("failed to fetch submission: %v", err)
+		logger.Error("failed to fetch submission", "error", err)
 	}
 
-	blocks, err := blocksFor(cfg, w, submission)
+	previous, previousErr := fetchPreviousSubmission(ctx, cfg, logger, w)
+	if previousErr != nil {
+		logger.Error("failed to fetch previous submission", "error", previousErr)
+	}
+
+	event := eventFor(cfg, w, submission, previous)
+	escalation := cfg.EscalationFor(event)
+	var mention string
+	if escalation == config.EscalationPage {
+		if mention, err = cfg.OnCall.Mention(ctx); err != nil {
+			logger.Warn("failed to find on-call group to mention", "error", err)
+		}
+	}
+
+	blocks, err := blocksFor(cfg, w, submission, mention, previous, previousErr)
 	if err != nil {
-		log.Printf("failed to get blocks: %v", err)
+		logger.Error("failed to get blocks", "error", err)
 		return
 	}
 
-	_, _, err = cfg.SlackClient.PostMessageContext(ctx, cfg.SlackChannel, slack.MsgOptionBlocks(blocks...), slack.MsgOptionDisableLinkUnfurl(), slack.MsgOptionDisableMediaUnfurl())
+	logger.Info("posting to Slack", "blocks", len(blocks))
+	msg := config.Message{Blocks: blocks, Text: messages.Fallback(event), Event: event}
+	msg.Channel = cfg.ChannelFor(ctx, msg.Event)
+	if submission != nil {
+		msg.ThreadTS = threadFor(ctx, cfg, logger, msg.Channel, submission)
+	}
+	if submission != nil && w.Status == expo.StatusFinished && submission.SubmittedBuild.Channel == topic.ReleaseChannel {
+		if err := cfg.Topic.Released(ctx, msg.Channel, submission.App.Id, submission.App.Name, w.Platform, expo.FormatVersion(submission.SubmittedBuild.BuildVersionMetadata)); err != nil {
+			logger.Warn("failed to update channel topic", "error", err)
+		}
+	}
+	if submission != nil && w.Status == expo.StatusFinished {
+		if err := cfg.Releases.Submitted(ctx, msg.Channel, submission.App.Id, submission.App.Name, submission.SubmittedBuild.Channel, w.Platform, expo.FormatVersion(submission.SubmittedBuild.BuildVersionMetadata)); err != nil {
+			logger.Warn("failed to record release", "error", err)
+		}
+	}
+	if updated(ctx, cfg, logger, w, msg) {
+		return
+	}
+	if submission != nil && w.Status == expo.StatusFinished {
+		reply, err := recoveredBlocks(cfg, w, submission)
+		if err != nil {
+			logger.Warn("failed to get blocks for the recovery", "error", err)
+		} else if reply != nil && recovery.Recovered(ctx, cfg, logger, failureKey(w, submission), msg, reply) {
+			return
+		}
+	}
+	// retries edit the message above rather than getting here, so the escalation is only posted once
+	if escalation == config.EscalationPage {
+		if err := cfg.PostEscalation(ctx, msg); err != nil {
+			logger.Error("failed to post message to the escalation channel", "error", err)
+			cfg.Metrics.SlackPostFailed()
+		}
+	}
+	ts, err := cfg.Poster.Post(ctx, msg)
+	if err != nil {
+		logger.Error("failed to post message", "error", err)
+		cfg.Metrics.SlackPostFailed()
+		return
+	}
+	if err := rememberMessage(ctx, cfg, w.Id, postedMessage{Message: threads.Message{Channel: msg.Channel, TS: ts}, Status: w.Status}); err != nil {
+		logger.Warn("failed to remember message for the submission", "error", err)
+	}
+	if submission != nil && w.Status == expo.StatusErrored {
+		if err := recovery.Failed(ctx, cfg, failureKey(w, submission), threads.Message{Channel: msg.Channel, TS: ts}); err != nil {
+			logger.Warn("failed to remember message for the failure", "error", err)
+		}
+	}
+}
+
+// failureKey identifies what a retried submission would be for: the same build, which is submitted again.
+func failureKey(w *WebhookPayload, submission *expo.Submission) string {
+	if submission.SubmittedBuild.Id == "" {
+		return ""
+	}
+	return fmt.Sprintf("submit/%s/%s", w.Platform, submission.SubmittedBuild.Id)
+}
+
+// templateData is what the submission templates are rendered with; see the templates package.
+type templateData struct {
+	Payload    *WebhookPayload
+	Submission *expo.Submission
+	Previous   *expo.Submission
+	Repository string
+	Project    expo.Project
+	Mention    string
+	Diagnosis  *expo.Diagnosis
+}
+
+// appFor is the app the submission is for, or nil if we couldn't fetch the submission.
+func appFor(submission *expo.Submission) *expo.App {
+	if submission == nil {
+		return nil
+	}
+	return &submission.App
+}
+
+// recoveredBlocks is the reply to the message for a failed submission of the same build when a retry succeeds.
+func recoveredBlocks(cfg *config.Config, w *WebhookPayload, submission *expo.Submission) ([]slack.Block, error) {
+	block, err := messages.Section(cfg.Templates, "submit.recovered", templateData{Payload: w, Submission: submission, Repository: cfg.RepositoryFor(w.AppId, appFor(submission)), Project: appFor(submission).Project()})
+	if err != nil || block == nil {
+		return nil, err
+	}
+	return []slack.Block{block}, nil
+}
+
+// updated edits the message we already posted for the submission, as retried submissions keep their ID and
+// would otherwise be posted again for each attempt. It reports whether there was a message to edit.
+func updated(ctx context.Context, cfg *config.Config, logger *slog.Logger, w *WebhookPayload, msg config.Message) bool {
+	updater, ok := cfg.Poster.(config.Updater)
+	if !ok {
+		return false
+	}
+	previous, ok, err := lookupMessage(ctx, cfg, w.Id)
 	if err != nil {
-		log.Printf("failed to post message: %v", err)
+		logger.Warn("failed to look up message for the submission", "error", err)
+	}
+	// the message can't move, so if the submission is now routed elsewhere we post there instead
+	if !ok || previous.Channel != msg.Channel {
+		return false
+	}
+	logger.Info("updating the message for the submission", "ts", previous.TS)
+	msg.Unchanged = previous.Status.Equal(w.Status)
+	if err := updater.Update(ctx, previous.TS, msg); err != nil {
+		logger.Error("failed to update message", "error", err)
+		cfg.Metrics.SlackPostFailed()
+		return false
+	}
+	previous.Status = w.Status
+	if err := rememberMessage(ctx, cfg, w.Id, previous); err != nil {
+		logger.Warn("failed to remember message for the submission", "error", err)
+	}
+	return true
+}
+
+// postedMessage is the message we posted for a submission, and the status it last described.
+type postedMessage struct {
+	threads.Message
+	Status expo.Status `json:"status,omitempty"`
+}
+
+func rememberMessage(ctx context.Context, cfg *config.Config, id string, msg postedMessage) error {
+	if cfg.Store == nil || msg.TS == "" {
+		return nil
+	}
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %v", err)
+	}
+	return cfg.Store.Set(ctx, "submission/"+id, string(raw), config.DefaultThreadTTL)
+}
+
+func lookupMessage(ctx context.Context, cfg *config.Config, id string) (postedMessage, bool, error) {
+	if cfg.Store == nil {
+		return postedMessage{}, false, nil
+	}
+	raw, ok, err := cfg.Store.Get(ctx, "submission/"+id)
+	if err != nil || !ok {
+		return postedMessage{}, false, err
+	}
+	var msg postedMessage
+	if err := json.Unmarshal([]byte(raw), &msg); err != nil {
+		return postedMessage{}, false, fmt.Errorf("failed to unmarshal message: %v", err)
+	}
+	return msg, true, nil
+}
+
+// threadFor finds the message to thread the submission under: the message for the build that was
+// submitted, or failing that, the message for a build of the same commit.
+func threadFor(ctx context.Context, cfg *config.Config, logger *slog.Logger, channel string, submission *expo.Submission) string {
+	parent, ok, err := cfg.Threads.LookupBuild(ctx, submission.SubmittedBuild.Id)
+	if err != nil {
+		logger.Warn("failed to look up build message to thread under", "build_id", submission.SubmittedBuild.Id, "error", err)
+	}
+	// the build may have been posted elsewhere, and threads can't span channels
+	if ok && parent.Channel == channel {
+		return parent.TS
+	}
+	ts, err := cfg.Threads.Lookup(ctx, channel, submission.SubmittedBuild.GitCommitHash)
+	if err != nil {
+		logger.Warn("failed to look up build message to thread under", "error", err)
+	}
+	return ts
+}
+
+func fetchPreviousSubmission(ctx context.Context, cfg *config.Config, logger *slog.Logger, w *WebhookPayload) (*expo.Submission, error) {
+	if w.AppId == "" {
+		return nil, nil
+	}
+	// like builds, newer submissions may have started before the webhook arrived, so we page through the list
+	// until we find the submission we were notified about and take the one after it
+	found := false
+	for offset := 0; offset < cfg.ExpoClient.MaxSearched(); offset += pageSize {
+		submissions, err := cfg.ExpoClient.FetchSubmissions(ctx, w.AppId, w.Platform, pageSize, offset)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch submission list: %v", err)
+		}
+		for i := 0; i < len(submissions); i++ {
+			if found {
+				logger.Info("found previous submission", "previous_submission_id", submissions[i].Id)
+				return &submissions[i], nil
+			}
+			found = submissions[i].Id == w.Id
+		}
+		if len(submissions) < pageSize {
+			break
+		}
+	}
+	if !found {
+		logger.Warn("did not find submission in the most recent submissions", "searched", cfg.ExpoClient.MaxSearched())
 	}
+	// this may be the first submission for the app, in which case there's nothing to compare against
+	return nil, nil
 }
 
-func blocksFor(cfg *config.Config, w *WebhookPayload, submission *expo.Submission) ([]slack.Block, error) {
-	msg := expo.FormatTitle(":arrow_up:", "submission", w.Platform, w.Status)
+// eventFor summarises the submission for destinations that don't render Slack blocks.
+func eventFor(cfg *config.Config, w *WebhookPayload, submission *expo.Submission, previous *expo.Submission) config.Event {
+	event := config.Event{
+		Kind:       "submit",
+		Id:         w.Id,
+		AppId:      w.AppId,
+		Platform:   w.Platform,
+		Status:     w.Status,
+		Repository: cfg.RepositoryFor(w.AppId, appFor(submission)),
+		Title:      cfg.Catalog.T("%s submission %s.", expo.PlatformDisplay(cfg.Catalog, w.Platform), expo.StatusDisplay(cfg.Catalog, w.Status)),
+		DetailsURL: w.Details,
+	}
 	if submission != nil {
-		msg = fmt.Sprintf(`:arrow_up:%s%s| %s submission of %s %s %s.`, expo.PlatformEmoji(w.Platform), expo.StatusEmoji(w.Status), expo.PlatformDisplay(w.Platform), submission.App.Name, expo.FormatBuildVersion(submission.SubmittedBuild.BuildVersionMetadata), expo.StatusDisplay(w.Status))
+		event.AppName = submission.App.Name
+		event.Channel = submission.SubmittedBuild.Channel
+		event.Version = expo.FormatVersion(submission.SubmittedBuild.BuildVersionMetadata)
+		event.Commit = submission.SubmittedBuild.GitCommitHash
+		event.Title = cfg.Catalog.T("%s submission of %s %s %s.", expo.PlatformDisplay(cfg.Catalog, w.Platform), submission.App.Name, event.Version, expo.StatusDisplay(cfg.Catalog, w.Status))
 	}
-	blocks := []slack.Block{
-		&slack.HeaderBlock{
-			Type: slack.MBTSection,
-			Text: &slack.TextBlockObject{
-				Type: slack.MarkdownType,
-				Text: msg,
-			},
-		},
+	if previous != nil {
+		event.PreviousCommit = previous.SubmittedBuild.GitCommitHash
+	}
+	if w.Info.Error.Failed() {
+		event.Error = w.Info.Error.Error()
 	}
-	blocks = append(blocks,
+	return event
+}
+
+// blocksFor builds the message for the submission. An error from fetching the previous submission is noted at
+// the end of the message, which is otherwise sent without that context.
+func blocksFor(cfg *config.Config, w *WebhookPayload, submission *expo.Submission, mention string, previous *expo.Submission, previousErr error) ([]slack.Block, error) {
+	data := templateData{Payload: w, Submission: submission, Previous: previous, Repository: cfg.RepositoryFor(w.AppId, appFor(submission)), Project: appFor(submission).Project(), Mention: mention, Diagnosis: expo.Diagnose(w.Info.Error, nil)}
+	var iconURL string
+	if submission != nil {
+		iconURL = submission.App.IconUrl
+	}
+	title, err := cfg.Templates.Render("submit.title", data)
+	if err != nil {
+		return nil, err
+	}
+	blocks := []slack.Block{
 		&slack.SectionBlock{
 			Type: slack.MBTSection,
 			Text: &slack.TextBlockObject{
 				Type: slack.MarkdownType,
-				Text: func() string {
-					msg := ""
-					if w.Info.Error.Failed() {
-						msg += fmt.Sprintf("Error %s\n", w.Info.Error.Error())
-					}
-					msg += fmt.Sprintf("See details <%s|here>.", w.Details)
-					return msg
-				}(),
+				Text: title,
 			},
+			Accessory: messages.IconAccessory(iconURL),
 		},
-	)
+	}
+	block, err := messages.Section(cfg.Templates, "submit.dirty", data)
+	if err != nil {
+		return nil, err
+	}
+	if block != nil {
+		blocks = append(blocks, block)
+	}
+	if submission != nil {
+		if block := messages.BuildProfile(cfg.Catalog, submission.SubmittedBuild.BuildProfile, submission.SubmittedBuild.Distribution); block != nil {
+			blocks = append(blocks, block)
+		}
+	}
+	block, err = messages.Context(cfg.Templates, data, "submit.actor")
+	if err != nil {
+		return nil, err
+	}
+	if block != nil {
+		blocks = append(blocks, block)
+	}
+	if previous != nil {
+		block, err := messages.Section(cfg.Templates, "submit.previous", data)
+		if err != nil {
+			return nil, err
+		}
+		if block != nil {
+			blocks = append(blocks, block)
+		}
+	}
+	block, err = messages.Section(cfg.Templates, "submit.details", data)
+	if err != nil {
+		return nil, err
+	}
+	if block != nil {
+		blocks = append(blocks, block)
+	}
+	if previousErr != nil {
+		blocks = append(blocks, messages.Degraded(cfg.Catalog, "previous submission"))
+	}
 	return blocks, nil
 }
@@ -0,0 +1,30 @@
+package submit_test
+
+import (
+	"testing"
+
+	"github.com/NWACus/expo-slack-webhook/api/submit"
+	"github.com/NWACus/expo-slack-webhook/config/configtest"
+)
+
+var webhook = configtest.Webhook{Handle: submit.Handle, Header: "expo-signature", Sample: "submit.sample.json"}
+
+func TestHandle(t *testing.T) {
+	posted := webhook.PostSample(t)
+	if got, expected := posted.Text, "iOS submission of avalanche-forecast 1.0.0 (41) succeeded."; got != expected {
+		t.Errorf("expected text %q, got %q", expected, got)
+	}
+	configtest.ExpectTexts(t, posted.Blocks,
+		// the title, with the version and commit of the build submitted, from Expo
+		"iOS submission of avalanche-forecast 1.0.0 (41) [<https://github.com/NWACus/avy/commit/499a175e6eedad4c3a68be1e8d4fbc072c99aefd|499a175>]",
+		"Profile: `production`",
+		// the previous submission, with the changelog between them
+		"<https://expo.dev/accounts/nwac/projects/avalanche-forecast/submissions/4be0f7a1-2c8d-4e6b-9f3a-7d1c0e5b2a9f|previous submission>",
+		"<https://github.com/NWACus/avy/compare/8349b793e0c824f32d4619d7955f0f6b6ce29896...499a175e6eedad4c3a68be1e8d4fbc072c99aefd|GitHub>",
+		"See details <https://expo.dev/accounts/nwac/projects/avalanche-forecast/submissions/812c84ca-4106-476e-ae56-6d5b323585d3|here>.",
+	)
+}
+
+func TestHandleRejectsUnsignedPayloads(t *testing.T) {
+	webhook.RejectsUnsigned(t)
+}
@@ -2,13 +2,9 @@ package update
 
 import (
 	"context"
-	"crypto/hmac"
-	"crypto/sha1"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"strings"
@@ -18,6 +14,12 @@ import (
 
 	"github.com/NWACus/expo-slack-webhook/config"
 	"github.com/NWACus/expo-slack-webhook/expo"
+	"github.com/NWACus/expo-slack-webhook/i18n"
+	"github.com/NWACus/expo-slack-webhook/internal/messages"
+	"github.com/NWACus/expo-slack-webhook/internal/verify"
+	"github.com/NWACus/expo-slack-webhook/issues"
+	"github.com/NWACus/expo-slack-webhook/threads"
+	"github.com/NWACus/expo-slack-webhook/topic"
 )
 
 type Update struct {
@@ -28,13 +30,49 @@ type Update struct {
 	Branch        string        `json:"branch"`
 	Platform      expo.Platform `json:"platform"`
 	GitCommitHash string        `json:"gitCommitHash"`
+	// Message is what the update was published with, which is usually the commit message.
+	Message string `json:"message"`
+	// RuntimeVersion is the runtime of the builds the update can be delivered to.
+	RuntimeVersion string `json:"runtimeVersion"`
+	// RolloutPercentage is the share of users the update reaches while it's rolled out gradually. Only some
+	// ways of publishing send it, so otherwise we look it up.
+	RolloutPercentage *int `json:"rolloutPercentage"`
+	// IsRollBackToEmbedded updates have no commit of their own.
+	IsRollBackToEmbedded bool `json:"isRollBackToEmbedded"`
+}
+
+const (
+	// buildPageSize is how many of the latest builds on a channel we look through for the latest store build.
+	buildPageSize = 10
+	// maxRuntimeBuilds is how many of the builds on an update's runtime we look through for the oldest; builds
+	// older than that are rarely still installed.
+	maxRuntimeBuilds = 50
+	// fetchTimeout bounds each lookup we make for context, so that one slow query can't hold up the message.
+	fetchTimeout = 15 * time.Second
+)
+
+// validate checks that the update has the fields we need to find the previous update and describe it.
+func (u *Update) validate() error {
+	switch {
+	case u.Id == "":
+		return fmt.Errorf("missing id")
+	case u.AppId == "":
+		return fmt.Errorf("missing appId")
+	case u.Platform == "":
+		return fmt.Errorf("missing platform")
+	case u.Branch == "":
+		return fmt.Errorf("missing branch")
+	case u.CreatedAt == "":
+		return fmt.Errorf("missing createdAt")
+	}
+	return nil
 }
 
 // Handler is the entrypoint for Vercel serverless functions.
 func Handler(w http.ResponseWriter, r *http.Request) {
 	cfg, err := config.LoadFromEnv()
 	if err != nil {
-		log.Printf("failed to load config: %v", err)
+		slog.Error("failed to load config", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -44,80 +82,364 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 
 // Handle consumes the webhook and posts the data to Slack.
 func Handle(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
-	log.Printf("Update webhook received")
-	if r.Method != "POST" {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		return
-	}
-
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		log.Printf("failed to read request body: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	log.Printf("body: %v", string(body))
-
-	digest := hmac.New(sha1.New, []byte(cfg.ExpoHMACSecret))
-	digest.Write(body)
-	receivedSignature := r.Header.Get("signature")
-	log.Printf("Received signature: %v\n", receivedSignature)
-	expectedSignature := fmt.Sprintf("sha1=%v", hex.EncodeToString(digest.Sum(nil)))
-	if expectedSignature != receivedSignature {
-		log.Printf("Invalid HMAC, received %v, expected %v\n", receivedSignature, expectedSignature)
-		w.WriteHeader(http.StatusUnauthorized)
+	logger := cfg.Logger.With("webhook", "update")
+	logger.Info("update webhook received")
+	body, ok := verify.Read(cfg, logger, w, r, "signature")
+	if !ok {
 		return
 	}
 
-	if _, debug := os.LookupEnv("DEBUG"); debug {
-		log.Printf("Received payload: %v\n", string(body))
-	}
+	// one deployment can serve several channels, with a webhook URL registered in Expo for each
+	ctx := config.WithChannel(r.Context(), r.URL.Query().Get("channel"))
+	Process(ctx, cfg, logger, w, body)
+}
 
+// Process handles a verified update payload, responding to the webhook and then posting to Slack.
+func Process(ctx context.Context, cfg *config.Config, logger *slog.Logger, w http.ResponseWriter, body []byte) {
 	payload := []Update{}
 	if err := json.Unmarshal(body, &payload); err != nil {
-		log.Printf("failed to unmarshal payload: %v", err)
+		logger.Error("failed to unmarshal payload", "error", err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
+	for i, update := range payload {
+		if err := update.validate(); err != nil {
+			logger.Error("invalid payload", "index", i, "update_id", update.Id, "error", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if !cfg.KnowsApp(update.AppId) {
+			logger.Warn("rejecting payload for unknown app", "index", i, "update_id", update.Id, "app_id", update.AppId)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if err := cfg.CheckPayloadAge(update.CreatedAt, time.Now()); err != nil {
+			logger.Warn("rejecting payload as a possible replay", "update_id", update.Id, "error", err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+
 	// we want to signal to Expo that we got the webhook OK as soon as we can, as they have short timeouts on this
 	w.WriteHeader(http.StatusOK)
 
+	// an empty array (or a null body) is valid JSON but there's nothing for us to look up or post
+	if len(payload) == 0 {
+		logger.Info("received empty update payload, nothing to do")
+		return
+	}
+
 	var ids []string
 	var group string
 	for _, update := range payload {
 		group = update.Group
 		ids = append(ids, update.Id)
 	}
-	log.Printf("Recieved update webhook for group %s, updates: %v.\n", group, strings.Join(ids, ","))
+	logger.Info("received update webhook", "group", group, "update_ids", strings.Join(ids, ","))
 
 	// we can handle forwarding the data to Slack on our own time
-	handlePayload(r.Context(), cfg, payload)
+	cfg.Workers.Go(ctx, func(ctx context.Context) {
+		handlePayload(ctx, cfg, logger, payload)
+	})
 }
 
-func handlePayload(ctx context.Context, cfg *config.Config, updates []Update) {
+// Render builds the messages for an update payload, one for each update group, without looking anything
+// up, so that message templates can be tried out offline. Sections needing context from Expo, like the
+// previous update, are left out.
+func Render(cfg *config.Config, body []byte) ([][]slack.Block, error) {
+	payload := []Update{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal payload: %v", err)
+	}
+	for i, update := range payload {
+		if err := update.validate(); err != nil {
+			return nil, fmt.Errorf("invalid update %d in payload: %v", i, err)
+		}
+	}
+	var rendered [][]slack.Block
+	for _, group := range groupUpdates(payload) {
+		blocks, err := blocksFor(cfg, group, lookups{})
+		if err != nil {
+			return nil, err
+		}
+		rendered = append(rendered, blocks)
+	}
+	return rendered, nil
+}
+
+func handlePayload(ctx context.Context, cfg *config.Config, logger *slog.Logger, updates []Update) {
+	var kept []Update
 	for _, update := range updates {
+		logger := logger.With("update_id", update.Id, "app_id", update.AppId, "platform", update.Platform, "branch", update.Branch)
 		if _, allowPreviews := os.LookupEnv("ALLOW_PREVIEW"); !allowPreviews && strings.HasPrefix(update.Branch, "xxx") {
-			log.Printf("skipping update for preview branch %s\n", update.Branch)
+			logger.Info("skipping update for preview branch")
 			continue
 		}
-		previousUpdate, err := fetchPreviousUpdate(ctx, cfg, update)
-		if err != nil {
-			log.Printf("failed to fetch previous update: %v", err)
+		// we're only told about updates once they're published
+		if !cfg.ShouldNotify("update", update.Platform, expo.StatusFinished) {
+			logger.Info("update filtered out, not posting to Slack")
+			continue
 		}
+		kept = append(kept, update)
+	}
 
-		blocks, err := blocksFor(cfg, update, previousUpdate)
+	// each platform in an update group gets its own update, but they're one publish as far as anyone reading
+	// the channel cares, so we post one message per group
+	for _, group := range groupUpdates(kept) {
+		handleGroup(ctx, cfg, logger, group)
+	}
+}
+
+// groupUpdates collects updates by their group, keeping the order they were sent in.
+func groupUpdates(updates []Update) [][]Update {
+	var groups [][]Update
+	indices := map[string]int{}
+	for _, update := range updates {
+		key := update.Group
+		if key == "" {
+			// without a group, we can't tell what the update was published with
+			key = update.Id
+		}
+		if i, ok := indices[key]; ok {
+			groups[i] = append(groups[i], update)
+			continue
+		}
+		indices[key] = len(groups)
+		groups = append(groups, []Update{update})
+	}
+	return groups
+}
+
+func handleGroup(ctx context.Context, cfg *config.Config, logger *slog.Logger, group []Update) {
+	first := group[0]
+	var ids []string
+	for _, update := range group {
+		ids = append(ids, update.Id)
+	}
+	logger = logger.With("group", first.Group, "update_ids", strings.Join(ids, ","), "app_id", first.AppId, "branch", first.Branch)
+
+	// a rollback has no commit of its own, so there are no changes since the previous update to link to
+	previousUpdates := make([]*expo.Update, len(group))
+	var updateErr error
+	for i, update := range group {
+		if update.IsRollBackToEmbedded {
+			continue
+		}
+		previous, err := fetchPreviousUpdate(ctx, cfg, update)
 		if err != nil {
-			log.Printf("failed to get blocks: %v", err)
-			return
+			logger.Error("failed to fetch previous update", "update_id", update.Id, "platform", update.Platform, "error", err)
+			updateErr = err
+			continue
 		}
+		previousUpdates[i] = previous
+	}
+
+	unsigned := unsignedFor(group, fetchGroup(ctx, cfg, logger, group))
+	if len(unsigned) > 0 {
+		logger.Warn("update published to the release branch without code signing", "count", len(unsigned))
+	}
+
+	app, err := fetchApp(ctx, cfg, first.AppId)
+	if err != nil {
+		logger.Error("failed to fetch app", "error", err)
+	}
+
+	incompatible, reaches, buildsErr := fetchBuilds(ctx, cfg, logger, group)
+	shipped := fetchIssues(ctx, cfg, logger, group, app, previousUpdates)
+
+	blocks, err := blocksFor(cfg, group, lookups{
+		App:          app,
+		Previous:     previousUpdates,
+		PreviousErr:  updateErr,
+		Incompatible: incompatible,
+		Reaches:      reaches,
+		Unsigned:     unsigned,
+		Shipped:      shipped,
+		BuildsErr:    buildsErr,
+	})
+	if err != nil {
+		logger.Error("failed to get blocks", "error", err)
+		return
+	}
+
+	logger.Info("posting to Slack", "blocks", len(blocks))
+	event := eventFor(cfg, group, app, previousUpdates)
+	msg := config.Message{Blocks: blocks, Text: messages.Fallback(event), Event: event}
+	msg.Channel = cfg.ChannelFor(ctx, msg.Event)
+	ts, err := cfg.Threads.Lookup(ctx, msg.Channel, first.GitCommitHash)
+	if err != nil {
+		logger.Warn("failed to look up build message to thread under", "error", err)
+	}
+	msg.ThreadTS = ts
+	if rolloutChanged(ctx, cfg, logger, group, app, msg) {
+		return
+	}
+	ts, err = cfg.Poster.Post(ctx, msg)
+	if err != nil {
+		logger.Error("failed to post message", "error", err)
+		cfg.Metrics.SlackPostFailed()
+	}
+	if rollout := rolloutFor(group); rollout != nil {
+		if err := rememberRollout(ctx, cfg, first.Group, rolloutMessage{Message: threads.Message{Channel: msg.Channel, TS: ts}, Percentage: rollout.Percentage}); err != nil {
+			logger.Warn("failed to remember message for the rollout", "error", err)
+		}
+	}
+	var appName string
+	if app != nil {
+		appName = app.Name
+	}
+	for _, update := range group {
+		if err := cfg.Releases.Updated(ctx, msg.Channel, update.AppId, appName, update.Branch, update.Platform, update.GitCommitHash, update.IsRollBackToEmbedded); err != nil {
+			logger.Warn("failed to record release", "platform", update.Platform, "error", err)
+		}
+	}
+}
+
+// rollout is how far an update group is rolled out, while it's rolled out gradually.
+type rollout struct {
+	Percentage int
+}
+
+// rolloutFor is how far the group is rolled out, or nil if it reaches everyone. The updates in a group are
+// rolled out together.
+func rolloutFor(group []Update) *rollout {
+	if percentage := group[0].RolloutPercentage; percentage != nil && *percentage < 100 {
+		return &rollout{Percentage: *percentage}
+	}
+	return nil
+}
+
+// fetchGroup looks the group up in Expo for what the webhook payload doesn't say, filling in how far it's
+// rolled out if the payload doesn't say. It returns nil if the group couldn't be found.
+func fetchGroup(ctx context.Context, cfg *config.Config, logger *slog.Logger, group []Update) []expo.Update {
+	if group[0].Group == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	updates, err := cfg.ExpoClient.FetchUpdateGroup(ctx, group[0].Group)
+	if err != nil {
+		logger.Warn("failed to fetch update group", "error", err)
+		return nil
+	}
+	for i := range group {
+		for _, update := range updates {
+			if update.Id == group[i].Id && group[i].RolloutPercentage == nil {
+				group[i].RolloutPercentage = update.RolloutPercentage
+			}
+		}
+	}
+	return updates
+}
+
+// unsignedFor lists the updates in the group published to the release branch without code signing, which
+// means whatever published them wasn't set up with the signing key, so apps checking signatures will refuse
+// them. Updates we couldn't look up are left out, as we can't tell.
+func unsignedFor(group []Update, fetched []expo.Update) []Update {
+	var unsigned []Update
+	for _, update := range group {
+		if update.Branch != topic.ReleaseChannel {
+			continue
+		}
+		for _, f := range fetched {
+			if f.Id == update.Id && !f.Signed() {
+				unsigned = append(unsigned, update)
+			}
+		}
+	}
+	return unsigned
+}
+
+// rolloutMessage is the message posted for an update group being rolled out gradually, and how far it was
+// rolled out when we last said.
+type rolloutMessage struct {
+	threads.Message
+	Percentage int `json:"percentage"`
+}
 
-		log.Printf("Posting %d blocks to Slack channel %s", len(blocks), cfg.SlackChannel)
-		_, _, err = cfg.SlackClient.PostMessageContext(ctx, cfg.SlackChannel, slack.MsgOptionBlocks(blocks...), slack.MsgOptionDisableLinkUnfurl(), slack.MsgOptionDisableMediaUnfurl())
+// rolloutChanged replies to the message for an update group being rolled out gradually when its rollout has
+// changed since, rather than posting the group again. It reports whether the group was posted before, in
+// which case there's nothing more to post.
+func rolloutChanged(ctx context.Context, cfg *config.Config, logger *slog.Logger, group []Update, app *expo.App, msg config.Message) bool {
+	first := group[0]
+	previous, ok, err := lookupRollout(ctx, cfg, first.Group)
+	if err != nil {
+		logger.Warn("failed to look up message for the rollout", "error", err)
+	}
+	// threads can't span channels, so if the group is now routed elsewhere we post there instead
+	if !ok || previous.Channel != msg.Channel {
+		return false
+	}
+	current := rolloutFor(group)
+	if current != nil && current.Percentage == previous.Percentage {
+		// we've already said as much
+		logger.Info("rollout unchanged, not posting the update group again", "percentage", current.Percentage)
+		return true
+	}
+	data := templateData{Group: group, First: first, Project: app.Project(), Platforms: platformsDisplay(cfg.Catalog, group), URL: updateURL(app.Project(), first), Rollout: current, PreviousRollout: &rollout{Percentage: previous.Percentage}}
+	block, err := messages.Section(cfg.Templates, "update.rollout", data)
+	if err != nil || block == nil {
 		if err != nil {
-			log.Printf("failed to post message: %v", err)
+			logger.Warn("failed to get blocks for the rollout", "error", err)
 		}
+		return false
+	}
+	logger.Info("replying to the message for the rollout", "ts", previous.TS, "previous_percentage", previous.Percentage)
+	reply := config.Message{Blocks: []slack.Block{block}, Text: msg.Text, Channel: msg.Channel, ThreadTS: previous.TS, Event: msg.Event}
+	if _, err := cfg.Poster.Post(ctx, reply); err != nil {
+		logger.Error("failed to post message", "error", err)
+		cfg.Metrics.SlackPostFailed()
+		return false
+	}
+	if current == nil {
+		err = forgetRollout(ctx, cfg, first.Group)
+	} else {
+		err = rememberRollout(ctx, cfg, first.Group, rolloutMessage{Message: previous.Message, Percentage: current.Percentage})
+	}
+	if err != nil {
+		logger.Warn("failed to remember message for the rollout", "error", err)
+	}
+	return true
+}
+
+func rememberRollout(ctx context.Context, cfg *config.Config, group string, msg rolloutMessage) error {
+	if cfg.Store == nil || group == "" || msg.TS == "" {
+		return nil
+	}
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %v", err)
+	}
+	return cfg.Store.Set(ctx, "rollout/"+group, string(raw), config.DefaultThreadTTL)
+}
+
+func lookupRollout(ctx context.Context, cfg *config.Config, group string) (rolloutMessage, bool, error) {
+	if cfg.Store == nil || group == "" {
+		return rolloutMessage{}, false, nil
+	}
+	raw, ok, err := cfg.Store.Get(ctx, "rollout/"+group)
+	if err != nil || !ok {
+		return rolloutMessage{}, false, err
 	}
+	var msg rolloutMessage
+	if err := json.Unmarshal([]byte(raw), &msg); err != nil {
+		return rolloutMessage{}, false, fmt.Errorf("failed to unmarshal message: %v", err)
+	}
+	return msg, true, nil
+}
+
+func forgetRollout(ctx context.Context, cfg *config.Config, group string) error {
+	if cfg.Store == nil || group == "" {
+		return nil
+	}
+	return cfg.Store.Delete(ctx, "rollout/"+group)
+}
+
+func fetchApp(ctx context.Context, cfg *config.Config, appId string) (*expo.App, error) {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	return cfg.ExpoClient.FetchApp(ctx, appId)
 }
 
 func fetchPreviousUpdate(ctx context.Context, cfg *config.Config, update Update) (*expo.Update, error) {
@@ -126,79 +448,297 @@ func fetchPreviousUpdate(ctx context.Context, cfg *config.Config, update Update)
 		return nil, fmt.Errorf("failed to parse createdAt: %v", err)
 	}
 
-	updates, err := cfg.ExpoClient.FetchUpdates(ctx, update.AppId, update.Branch, 10, 0)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch updates: %v", err)
-	}
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	return cfg.ExpoClient.FetchPreviousUpdate(ctx, update.AppId, update.Branch, update.Platform, update.Id, createdAt)
+}
 
-	return previousUpdateFor(update.Platform, update.Id, createdAt, updates)
+// incompatibility is an update that won't reach the latest store build for its platform, as the build is for
+// a different runtime.
+type incompatibility struct {
+	Update Update
+	Build  *expo.Build
 }
 
-func previousUpdateFor(platform expo.Platform, id string, createdAt time.Time, updates [][]expo.Update) (*expo.Update, error) {
-	for i := 0; i < len(updates); i++ {
-		for j := 0; j < len(updates[i]); j++ {
-			if !updates[i][j].Platform.Equal(platform) || updates[i][j].Id == id {
+// reach is the range of builds an update will be delivered to, from Oldest to Newest, which are the same
+// build if only one is on the update's runtime. Both are nil if no build is.
+type reach struct {
+	Update         Update
+	Oldest, Newest *expo.Build
+	// Latest is set when Newest is the latest successful build, so later builds will likely get the update
+	// too.
+	Latest bool
+}
+
+// fetchBuilds looks up the builds on each update's channel, finding the updates that won't reach the latest
+// store build for their platform and which builds each update will reach. Builds are looked for on the
+// channel named for the update's branch, which is how channels are usually set up. Rollbacks to the embedded
+// update reach every build, so they're skipped.
+func fetchBuilds(ctx context.Context, cfg *config.Config, logger *slog.Logger, group []Update) ([]incompatibility, []reach, error) {
+	var (
+		incompatible []incompatibility
+		reaches      []reach
+		failed       error
+	)
+	for _, update := range group {
+		if update.IsRollBackToEmbedded || update.RuntimeVersion == "" {
+			continue
+		}
+		latest, runtime, err := fetchUpdateBuilds(ctx, cfg, update)
+		if err != nil {
+			logger.Error("failed to fetch builds", "update_id", update.Id, "platform", update.Platform, "error", err)
+			failed = err
+			continue
+		}
+		var latestBuild, latestStore *expo.Build
+		for i, build := range latest {
+			if !build.Status.Equal(expo.StatusFinished) {
 				continue
 			}
-			updateCreatedAt, err := time.Parse(time.RFC3339, updates[i][j].CreatedAt)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse createdAt for update %s: %v", updates[i][j].Id, err)
+			if latestBuild == nil {
+				latestBuild = &latest[i]
 			}
-			if updateCreatedAt.After(createdAt) {
-				continue
+			if latestStore == nil && strings.EqualFold(build.Distribution, expo.DistributionStore) {
+				latestStore = &latest[i]
 			}
-			return &updates[i][j], nil
 		}
+		if latestStore != nil && latestStore.RuntimeVersion != "" && latestStore.RuntimeVersion != update.RuntimeVersion {
+			logger.Info("update won't reach the latest store build", "update_id", update.Id, "platform", update.Platform, "build_id", latestStore.Id, "runtime_version", update.RuntimeVersion, "build_runtime_version", latestStore.RuntimeVersion)
+			incompatible = append(incompatible, incompatibility{Update: update, Build: latestStore})
+		}
+		r := reach{Update: update}
+		if len(runtime) > 0 {
+			r.Newest, r.Oldest = &runtime[0], &runtime[len(runtime)-1]
+			r.Latest = latestBuild != nil && latestBuild.Id == r.Newest.Id
+		}
+		reaches = append(reaches, r)
 	}
-	return nil, nil
+	return incompatible, reaches, failed
 }
 
-func blocksFor(cfg *config.Config, update Update, previous *expo.Update) ([]slack.Block, error) {
+// fetchUpdateBuilds lists the latest builds on the update's channel, and the successful builds on its runtime.
+func fetchUpdateBuilds(ctx context.Context, cfg *config.Config, update Update) ([]expo.Build, []expo.Build, error) {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	latest, err := cfg.ExpoClient.FetchBuilds(ctx, update.AppId, update.Branch, update.Platform, buildPageSize, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	runtime, err := cfg.ExpoClient.FetchRuntimeBuilds(ctx, update.AppId, update.Branch, update.Platform, update.RuntimeVersion, maxRuntimeBuilds)
+	if err != nil {
+		return nil, nil, err
+	}
+	return latest, runtime, nil
+}
+
+// eventFor summarises the update group for destinations that don't render Slack blocks. Groups for more
+// than one platform have no single platform to report.
+func eventFor(cfg *config.Config, group []Update, app *expo.App, previous []*expo.Update) config.Event {
+	first := group[0]
+	event := config.Event{
+		Kind:       "update",
+		Id:         first.Id,
+		AppId:      first.AppId,
+		Status:     expo.StatusFinished,
+		Channel:    first.Branch,
+		Branch:     first.Branch,
+		Commit:     first.GitCommitHash,
+		Repository: cfg.RepositoryFor(first.AppId, app),
+		Title:      cfg.Catalog.T("%s OTA update %s.", platformsDisplay(cfg.Catalog, group), expo.StatusDisplay(cfg.Catalog, expo.StatusFinished)),
+		DetailsURL: updateURL(app.Project(), first),
+	}
+	if len(group) == 1 {
+		event.Platform = first.Platform
+	}
+	if rollout := rolloutFor(group); rollout != nil {
+		event.Title = cfg.Catalog.T("%s OTA update %s at %d%% rollout.", platformsDisplay(cfg.Catalog, group), expo.StatusDisplay(cfg.Catalog, expo.StatusFinished), rollout.Percentage)
+	}
+	if first.IsRollBackToEmbedded {
+		event.Title = "⏪ " + cfg.Catalog.T("Rollback published") + ": " + cfg.Catalog.T("%s OTA updates rolled back to the update embedded in the build.", platformsDisplay(cfg.Catalog, group))
+	}
+	if app != nil {
+		event.AppName = app.Name
+	}
+	for _, update := range previous {
+		if update != nil {
+			event.PreviousCommit = update.GitCommitHash
+			break
+		}
+	}
+	return event
+}
+
+// lookups are what we found out about an update group beyond its payload, each left empty if we didn't look
+// it up or didn't find it.
+type lookups struct {
+	App *expo.App
+	// Previous are the updates before each of the group's on its branch, in the same order.
+	Previous    []*expo.Update
+	PreviousErr error
+	// Incompatible, Reaches and Unsigned are as in templateData.
+	Incompatible []incompatibility
+	Reaches      []reach
+	Unsigned     []Update
+	BuildsErr    error
+	// Shipped are the issues the commits since the previous update mention.
+	Shipped []issues.Issue
+}
+
+// blocksFor builds the message for the update group, listing the builds it will reach and warning about
+// updates that won't reach the latest store build or weren't code signed. Errors from fetching a previous
+// update or the latest builds are noted at the end of the message, which is otherwise sent without that
+// context.
+func blocksFor(cfg *config.Config, group []Update, found lookups) ([]slack.Block, error) {
+	first, app := group[0], found.App
+	var iconURL string
+	if app != nil {
+		iconURL = app.IconUrl
+	}
+	project := app.Project()
+	var links []string
+	for _, update := range group {
+		links = append(links, fmt.Sprintf("<%s|%s>", updateURL(project, update), expo.PlatformDisplay(cfg.Catalog, update.Platform)))
+	}
+	data := templateData{Group: group, First: first, Project: project, Platforms: platformsDisplay(cfg.Catalog, group), URL: updateURL(project, first), Links: joinAnd(cfg.Catalog, links), Incompatible: found.Incompatible, Reaches: found.Reaches, Rollout: rolloutFor(group), Unsigned: found.Unsigned, Issues: found.Shipped}
+	title, err := cfg.Templates.Render("update.title", data)
+	if err != nil {
+		return nil, err
+	}
 	blocks := []slack.Block{
-		&slack.HeaderBlock{
+		&slack.SectionBlock{
 			Type: slack.MBTSection,
 			Text: &slack.TextBlockObject{
 				Type: slack.MarkdownType,
-				Text: fmt.Sprintf(`:arrows_counterclockwise:%s%s| %s OTA update %s.`, expo.PlatformEmoji(update.Platform), expo.StatusEmoji(expo.StatusFinished), expo.PlatformDisplay(update.Platform), expo.StatusDisplay(expo.StatusFinished)),
+				Text: title,
 			},
+			Accessory: messages.IconAccessory(iconURL),
 		},
 	}
-	if previous != nil {
-		createdAt, err := time.Parse(time.RFC3339, previous.CreatedAt)
+	for _, name := range []string{"update.signing", "update.commit", "update.compatibility", "update.reach"} {
+		block, err := messages.Section(cfg.Templates, name, data)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse createdAt for update %s: %v", update.Id, err)
+			return nil, err
+		}
+		if block != nil {
+			blocks = append(blocks, block)
+		}
+	}
+	// the platforms were usually last published together too, in which case one description covers them
+	described := map[string]bool{}
+	for i, update := range found.Previous {
+		if update == nil {
+			continue
+		}
+		key := update.Group
+		if key == "" {
+			key = update.Id
+		}
+		if described[key] {
+			continue
+		}
+		described[key] = true
+		block, err := messages.PreviousUpdate(cfg.Templates, cfg.RepositoryFor(first.AppId, app), project, update, group[i].GitCommitHash)
+		if err != nil {
+			return nil, err
+		}
+		if block != nil {
+			blocks = append(blocks, block)
+		}
+	}
+	for _, name := range []string{"update.details", "update.issues"} {
+		block, err := messages.Section(cfg.Templates, name, data)
+		if err != nil {
+			return nil, err
+		}
+		if block != nil {
+			blocks = append(blocks, block)
+		}
+	}
+	var failed []string
+	if found.PreviousErr != nil {
+		failed = append(failed, "previous update")
+	}
+	if found.BuildsErr != nil {
+		failed = append(failed, "latest build")
+	}
+	if block := messages.Degraded(cfg.Catalog, failed...); block != nil {
+		blocks = append(blocks, block)
+	}
+	if first.Group != "" {
+		var buttons []slack.BlockElement
+		if cfg.Promotion.Offered(first.Branch) {
+			buttons = append(buttons, messages.PromoteButton(cfg.Catalog, first.AppId, first.Group, cfg.Promotion.To))
+		}
+		if len(cfg.RollbackUsers) > 0 {
+			buttons = append(buttons, messages.RollBackButton(cfg.Catalog, first.AppId, first.Group))
+		}
+		if len(buttons) > 0 {
+			blocks = append(blocks, slack.NewActionBlock("", buttons...))
 		}
-		blocks = append(blocks, &slack.SectionBlock{
-			Type: slack.MBTSection,
-			Text: &slack.TextBlockObject{
-				Type: slack.MarkdownType,
-				Text: fmt.Sprintf(`The <https://expo.dev/accounts/nwac/projects/avalanche-forecast/updates/%s|previous update>, for commit <https://github.com/NWACus/avy/commit/%s|%s>, was published %s ago. See the changelog on <https://github.com/NWACus/avy/compare/%s...%s|GitHub>`, update.Id, previous.GitCommitHash, previous.GitCommitHash[0:7], formatDuration(time.Since(createdAt)), previous.GitCommitHash, update.GitCommitHash),
-			},
-		})
 	}
-	blocks = append(blocks, &slack.SectionBlock{
-		Type: slack.MBTSection,
-		Text: &slack.TextBlockObject{
-			Type: slack.MarkdownType,
-			Text: fmt.Sprintf("See update details <https://expo.dev/accounts/nwac/projects/avalanche-forecast/updates/%s|here>.", update.Id),
-		},
-	})
 	return blocks, nil
 }
 
-func formatDuration(d time.Duration) string {
-	switch {
-	case d < time.Minute:
-		return fmt.Sprintf("%d seconds", int(d.Seconds()))
-	case d < time.Hour:
-		return fmt.Sprintf("%d minutes", int(d.Minutes()))
-	case d < 24*time.Hour:
-		return fmt.Sprintf("%d hours", int(d.Hours()))
-	case d < 30*24*time.Hour:
-		return fmt.Sprintf("%d days", int(d.Hours()/24))
-	case d < 365*24*time.Hour:
-		return fmt.Sprintf("%d months", int(d.Hours()/(30*24)))
-	default:
-		return fmt.Sprintf("%d years", int(d.Hours()/(365*24)))
+// templateData is what the update templates are rendered with; see the templates package.
+type templateData struct {
+	Group     []Update
+	First     Update
+	Project   expo.Project
+	Platforms string
+	URL       string
+	Links     string
+	// Incompatible are the updates that won't reach the latest store build, with the build.
+	Incompatible []incompatibility
+	// Reaches are the builds each update will be delivered to.
+	Reaches []reach
+	// Rollout is how far the group is rolled out, while it's rolled out gradually, and PreviousRollout how far
+	// it was before, for the reply when that changes.
+	Rollout, PreviousRollout *rollout
+	// Unsigned are the updates published to the release branch without code signing.
+	Unsigned []Update
+	// Issues are those the commits since the previous update mention.
+	Issues []issues.Issue
+}
+
+// fetchIssues finds the issues mentioned by the commits since the previous update, as the changelog for the
+// first platform with one lists them; the platforms are almost always published from the same commits.
+func fetchIssues(ctx context.Context, cfg *config.Config, logger *slog.Logger, group []Update, app *expo.App, previous []*expo.Update) []issues.Issue {
+	if len(cfg.IssueTrackers) == 0 || cfg.ChangelogCommits == 0 {
+		return nil
+	}
+	for i, update := range previous {
+		if update == nil || update.GitCommitHash == "" || group[i].GitCommitHash == "" || update.GitCommitHash == group[i].GitCommitHash {
+			continue
+		}
+		compareCtx, cancel := context.WithTimeout(ctx, fetchTimeout)
+		changelog, err := cfg.GitHub.Compare(compareCtx, cfg.RepositoryFor(group[i].AppId, app), update.GitCommitHash, group[i].GitCommitHash, 0)
+		cancel()
+		if err != nil {
+			logger.Warn("failed to fetch changelog", "error", err)
+			return nil
+		}
+		return cfg.IssueTrackers.Find(changelog.Texts()...)
+	}
+	return nil
+}
+
+func updateURL(project expo.Project, update Update) string {
+	return expo.ProjectURL(project, "updates", update.Id)
+}
+
+// platformsDisplay names the platforms in the group, like "iOS and Android".
+func platformsDisplay(catalog *i18n.Catalog, group []Update) string {
+	var names []string
+	for _, update := range group {
+		names = append(names, expo.PlatformDisplay(catalog, update.Platform))
+	}
+	return joinAnd(catalog, names)
+}
+
+// joinAnd joins items into a list, like "a, b and c".
+func joinAnd(catalog *i18n.Catalog, items []string) string {
+	if len(items) < 2 {
+		return strings.Join(items, "")
 	}
+	return catalog.T("%s and %s", strings.Join(items[:len(items)-1], ", "), items[len(items)-1])
 }
@@ -0,0 +1,84 @@
+package update_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/NWACus/expo-slack-webhook/api/update"
+	"github.com/NWACus/expo-slack-webhook/config/configtest"
+	"github.com/NWACus/expo-slack-webhook/expo/expotest"
+)
+
+var webhook = configtest.Webhook{Handle: update.Handle, Header: "signature", Sample: "update.sample.json"}
+
+func TestHandle(t *testing.T) {
+	posted := webhook.PostSample(t)
+	if got, expected := posted.Text, "iOS and Android OTA update succeeded."; got != expected {
+		t.Errorf("expected text %q, got %q", expected, got)
+	}
+	configtest.ExpectTexts(t, posted.Blocks,
+		// both platforms in the group are posted as one update
+		":arrows_counterclockwise::apple_logo::android::large_green_circle:| iOS and Android OTA update succeeded.",
+		// the builds that will load it, from Expo
+		":apple_logo: Reaches 1.0.0 (40)+ on iOS.",
+		// the update before it on the branch, with the changelog between them
+		"<https://expo.dev/accounts/nwac/projects/avalanche-forecast/updates/e1f0c9a2-4b7d-4f3a-8c6e-2d9b5a1f0e7c|previous update>",
+		"<https://github.com/NWACus/avy/compare/1c0d5e7a2b9f4e3d8a6c1b0f9e2d7a5c4b3e1f0a...8349b793e0c824f32d4619d7955f0f6b6ce29896|GitHub>",
+		"<https://expo.dev/accounts/nwac/projects/avalanche-forecast/updates/153dc64f-88b9-44b7-bee3-ee9a576f4082|Android>",
+	)
+}
+
+func TestHandleRejectsUnsignedPayloads(t *testing.T) {
+	webhook.RejectsUnsigned(t)
+}
+
+func TestHandleEmptyPayload(t *testing.T) {
+	for _, body := range []string{`[]`, `null`} {
+		t.Run(body, func(t *testing.T) {
+			server := expotest.NewServer()
+			defer server.Close()
+			cfg, poster := configtest.New(t, server, nil)
+
+			w := webhook.Post(cfg, []byte(body), configtest.Sign([]byte(body)))
+			if w.Code != http.StatusOK {
+				t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+			}
+			if posted := poster.Messages(); len(posted) != 0 {
+				t.Errorf("expected nothing to be posted, got %d messages", len(posted))
+			}
+			if operations := server.Operations(); len(operations) != 0 {
+				t.Errorf("expected nothing to be looked up, got %v", operations)
+			}
+		})
+	}
+}
+
+func TestHandleRollback(t *testing.T) {
+	// rollbacks to the embedded update have no commit of their own
+	body := []byte(`[{
+		"id": "c5a1e3b7-2d4f-4e6a-8b9c-0d1e2f3a4b5c",
+		"createdAt": "2025-03-12T16:02:11.000Z",
+		"group": "f0e1d2c3-b4a5-4968-8776-655443322110",
+		"branch": "preview",
+		"runtimeVersion": "1.0.0",
+		"platform": "ios",
+		"manifestPermalink": "https://u.expo.dev/update/c5a1e3b7-2d4f-4e6a-8b9c-0d1e2f3a4b5c",
+		"isRollBackToEmbedded": true,
+		"appId": "47e2fd36-5165-4eb4-9a2d-21beec393379"
+	}]`)
+
+	posted := webhook.PostSigned(t, body)
+	texts := configtest.Texts(posted.Blocks)
+	if len(texts) == 0 || !strings.Contains(texts[0], "*Rollback published:* iOS OTA updates rolled back to the update embedded in the build.") {
+		t.Errorf("expected a rollback title, got:\n%s", strings.Join(texts, "\n"))
+	}
+	for _, text := range texts {
+		if strings.Contains(text, "github.com") {
+			t.Errorf("expected no commit or compare links, got %q", text)
+		}
+	}
+	if commit := posted.Event.Commit; commit != "" {
+		t.Errorf("expected no commit, got %q", commit)
+	}
+}
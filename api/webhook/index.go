@@ -0,0 +1,80 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/NWACus/expo-slack-webhook/api/build"
+	"github.com/NWACus/expo-slack-webhook/api/submit"
+	"github.com/NWACus/expo-slack-webhook/api/update"
+	"github.com/NWACus/expo-slack-webhook/config"
+	"github.com/NWACus/expo-slack-webhook/internal/verify"
+)
+
+// Handler is the entrypoint for Vercel serverless functions.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.LoadFromEnv()
+	if err != nil {
+		slog.Error("failed to load config", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	Handle(cfg, w, r)
+}
+
+// Handle consumes any of the webhooks we support, for deployments that can only register one endpoint,
+// and dispatches on the shape of the payload.
+func Handle(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	logger := cfg.Logger.With("webhook", "generic")
+	logger.Info("webhook received")
+
+	// Expo signs build and submission payloads in expo-signature, while our update action uses signature
+	body, ok := verify.Read(cfg, logger, w, r, "expo-signature", "signature")
+	if !ok {
+		return
+	}
+
+	ctx := config.WithChannel(r.Context(), r.URL.Query().Get("channel"))
+	switch kind := kindOf(body); kind {
+	case kindBuild:
+		build.Process(ctx, cfg, cfg.Logger.With("webhook", kind), w, body)
+	case kindSubmission:
+		submit.Process(ctx, cfg, cfg.Logger.With("webhook", kind), w, body)
+	case kindUpdate:
+		update.Process(ctx, cfg, cfg.Logger.With("webhook", kind), w, body)
+	default:
+		logger.Error("could not determine the type of payload")
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+const (
+	kindBuild      = "build"
+	kindSubmission = "submit"
+	kindUpdate     = "update"
+)
+
+// kindOf determines what a payload is: updates are sent as an array, while builds and submissions are
+// objects that link to their details pages.
+func kindOf(body []byte) string {
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		return kindUpdate
+	}
+	var probe struct {
+		BuildDetails      string `json:"buildDetailsPageUrl"`
+		SubmissionDetails string `json:"submissionDetailsPageUrl"`
+	}
+	if err := json.Unmarshal(body, &probe); err != nil {
+		return ""
+	}
+	switch {
+	case probe.BuildDetails != "":
+		return kindBuild
+	case probe.SubmissionDetails != "":
+		return kindSubmission
+	}
+	return ""
+}
@@ -0,0 +1,48 @@
+package webhook
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKindOf(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{name: "update", body: `[{"id":"7782ae22-1c38-4f5d-8053-3ee87de4c8cb"}]`, expected: kindUpdate},
+		{name: "empty update", body: `[]`, expected: kindUpdate},
+		{name: "whitespace before the array", body: "\n\t [{\"id\":\"7782ae22\"}]", expected: kindUpdate},
+		{name: "build", body: `{"buildDetailsPageUrl":"https://expo.dev/builds/35425398"}`, expected: kindBuild},
+		{name: "submission", body: `{"submissionDetailsPageUrl":"https://expo.dev/submissions/812c84ca"}`, expected: kindSubmission},
+		{name: "empty details page", body: `{"buildDetailsPageUrl":""}`},
+		{name: "unknown object", body: `{"id":"35425398","status":"finished"}`},
+		{name: "invalid JSON", body: `{"buildDetailsPageUrl":`},
+		{name: "empty body"},
+		{name: "scalar", body: `"build"`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := kindOf([]byte(tc.body)); got != tc.expected {
+				t.Errorf("expected kind %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestKindOfSamples(t *testing.T) {
+	for sample, expected := range map[string]string{
+		"build.sample.json":  kindBuild,
+		"submit.sample.json": kindSubmission,
+		"update.sample.json": kindUpdate,
+	} {
+		body, err := os.ReadFile(filepath.Join("..", "..", "test", sample))
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", sample, err)
+		}
+		if got := kindOf(body); got != expected {
+			t.Errorf("expected %s to be a %s payload, got %q", sample, expected, got)
+		}
+	}
+}
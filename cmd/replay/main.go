@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http/httptest"
+	"os"
+
+	"github.com/slack-go/slack"
+
+	"github.com/NWACus/expo-slack-webhook/api/build"
+	"github.com/NWACus/expo-slack-webhook/api/submit"
+	"github.com/NWACus/expo-slack-webhook/api/update"
+	"github.com/NWACus/expo-slack-webhook/config"
+)
+
+type Options struct {
+	PayloadPath string
+	Event       string
+	DryRun      bool
+}
+
+func DefaultOptions() *Options {
+	return &Options{}
+}
+
+func BindOptions(fs *flag.FlagSet, opts *Options) {
+	fs.StringVar(&opts.PayloadPath, "payload", opts.PayloadPath, "Path to a JSON file holding a captured payload.")
+	fs.StringVar(&opts.Event, "event", opts.Event, "Type of payload, one of build, submit or update.")
+	fs.BoolVar(&opts.DryRun, "dry-run", opts.DryRun, "Print the message without posting it anywhere or acting on it in Slack or GitHub.")
+}
+
+func (o *Options) Validate() error {
+	if o.PayloadPath == "" {
+		return fmt.Errorf("payload is required")
+	}
+	switch o.Event {
+	case "build", "submit", "update":
+	case "":
+		return fmt.Errorf("event is required")
+	default:
+		return fmt.Errorf("event must be one of build, submit or update")
+	}
+	return nil
+}
+
+// printingPoster prints messages in a form that can be pasted into Slack's Block Kit Builder, before
+// handing them on to be posted, if we're posting at all.
+type printingPoster struct {
+	next config.Poster
+}
+
+func (p *printingPoster) Post(ctx context.Context, msg config.Message) (string, error) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	// Slack's link syntax uses angle brackets, which are much easier to read unescaped
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(struct {
+		Blocks []slack.Block `json:"blocks"`
+	}{Blocks: msg.Blocks}); err != nil {
+		return "", fmt.Errorf("failed to print blocks: %v", err)
+	}
+	if p.next == nil {
+		return "", nil
+	}
+	return p.next.Post(ctx, msg)
+}
+
+// dryRun turns off everything in the settings that would be seen elsewhere: the destinations, what's done in
+// Slack besides posting, like setting topics and pinning releases, and reporting builds on GitHub. What a
+// message is made from, like Expo and the changelog on GitHub, is read as usual. State is kept in memory, so
+// that the replay doesn't leave any behind to thread under or edit later.
+func dryRun(settings config.Settings) config.Settings {
+	replayed := settings
+
+	replayed.SlackToken = ""
+	replayed.SlackWebhookURL = ""
+	replayed.SlackWorkspaces = nil
+	replayed.TeamsWebhookURL = ""
+	replayed.MattermostURL = ""
+	replayed.MattermostToken = ""
+	replayed.TelegramBotToken = ""
+	replayed.SMTPAddr = ""
+	replayed.SMTPPassword = ""
+	replayed.PagerDutyRoutingKey = ""
+	replayed.OpsgenieAPIKey = ""
+
+	replayed.SetTopic = false
+	replayed.PinReleases = false
+	replayed.InstallQRCodes = false
+
+	replayed.GitHubCommitStatuses = false
+	replayed.GitHubDeployments = false
+	replayed.GitHubPreviewComments = false
+	replayed.GitHubIssueAfterFailures = 0
+
+	replayed.StateStore = ""
+	replayed.UpdateChannelCacheShared = false
+	return replayed
+}
+
+func main() {
+	opts := DefaultOptions()
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	BindOptions(flags, opts)
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		log.Fatalf("failed to parse flags: %v", err)
+	}
+	if err := opts.Validate(); err != nil {
+		log.Fatalf("failed to validate options: %v", err)
+	}
+
+	payload, err := os.ReadFile(opts.PayloadPath)
+	if err != nil {
+		log.Fatalf("failed to read payload file: %v", err)
+	}
+
+	// we're configured just like the serverless functions, so the same environment works for both
+	settings, err := config.SettingsFromEnv()
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+	if opts.DryRun {
+		settings = dryRun(settings)
+	} else if err := settings.Validate(); err != nil {
+		log.Fatalf("failed to validate config: %v", err)
+	}
+	cfg, err := config.New(settings)
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+	// captured payloads are old by definition
+	cfg.MaxPayloadAge = 0
+	poster := &printingPoster{next: cfg.Poster}
+	if opts.DryRun {
+		poster.next = nil
+	}
+	cfg.Poster = poster
+
+	// the payload was signed when it was captured, so we skip straight to processing it; with no worker
+	// pool configured, that happens before Process returns
+	recorder := httptest.NewRecorder()
+	logger := cfg.Logger.With("webhook", opts.Event)
+	ctx := context.Background()
+	switch opts.Event {
+	case "build":
+		build.Process(ctx, cfg, logger, recorder, payload)
+	case "submit":
+		submit.Process(ctx, cfg, logger, recorder, payload)
+	case "update":
+		update.Process(ctx, cfg, logger, recorder, payload)
+	}
+	if recorder.Code != 200 {
+		log.Fatalf("payload was rejected with status %d", recorder.Code)
+	}
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/NWACus/expo-slack-webhook/expo"
+)
+
+// App is what we know about one of the Expo apps webhooks are sent for.
+type App struct {
+	// Channel is the Slack channel the app's events are posted to, instead of the defaults.
+	Channel string
+	// Repository is the GitHub repository the app is built from, as owner/name.
+	Repository string
+}
+
+// KnowsApp determines whether we accept webhooks for the app. When no apps are configured, every app is
+// accepted.
+func (c *Config) KnowsApp(appId string) bool {
+	if len(c.Apps) == 0 {
+		return true
+	}
+	_, ok := c.Apps[appId]
+	return ok
+}
+
+// RepositoryFor determines the GitHub repository to link the app's commits to: the one configured for the
+// app, or else the one linked to it in Expo, given the app as we fetched it, which may be nil. Failing those,
+// it's DefaultRepository, or expo.DefaultRepository if that isn't set.
+func (c *Config) RepositoryFor(appId string, app *expo.App) string {
+	if configured, ok := c.Apps[appId]; ok && configured.Repository != "" {
+		return configured.Repository
+	}
+	if linked := app.Repository(); linked != "" {
+		return linked
+	}
+	if c.DefaultRepository != "" {
+		return c.DefaultRepository
+	}
+	return expo.DefaultRepository
+}
+
+// AppIds lists the apps we know of, for commands that aren't about any one webhook. These are the configured
+// apps or, failing that, ExpoAppId.
+func (c *Config) AppIds() []string {
+	if len(c.Apps) > 0 {
+		return slices.Sorted(maps.Keys(c.Apps))
+	}
+	if c.ExpoAppId != "" {
+		return []string{c.ExpoAppId}
+	}
+	return nil
+}
+
+// ParseApps builds the app map from appId=channel and appId=owner/name pairs. Apps may appear in either
+// list or both.
+func ParseApps(channels, repositories []string) (map[string]App, error) {
+	apps := map[string]App{}
+	for _, pair := range channels {
+		appId, channel, ok := strings.Cut(pair, "=")
+		if !ok || appId == "" || channel == "" {
+			return nil, fmt.Errorf("invalid app channel %q, expected appId=channel", pair)
+		}
+		app := apps[appId]
+		app.Channel = channel
+		apps[appId] = app
+	}
+	for _, pair := range repositories {
+		appId, repository, ok := strings.Cut(pair, "=")
+		if !ok || appId == "" || strings.Count(repository, "/") != 1 {
+			return nil, fmt.Errorf("invalid app repository %q, expected appId=owner/name", pair)
+		}
+		app := apps[appId]
+		app.Repository = repository
+		apps[appId] = app
+	}
+	if len(apps) == 0 {
+		return nil, nil
+	}
+	return apps, nil
+}
@@ -0,0 +1,190 @@
+// Package configtest configures the webhook handlers for tests, looking things up in a fake Expo and recording
+// the messages they post rather than sending them anywhere.
+package configtest
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/slack-go/slack"
+
+	"github.com/NWACus/expo-slack-webhook/config"
+	"github.com/NWACus/expo-slack-webhook/expo/expotest"
+)
+
+// Secret is the HMAC secret configured by New, which Sign signs payloads with.
+const Secret = "configtest"
+
+// Poster records the messages it is given, in place of posting them.
+type Poster struct {
+	lock     sync.Mutex
+	messages []config.Message
+}
+
+func (p *Poster) Post(_ context.Context, msg config.Message) (string, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.messages = append(p.messages, msg)
+	return fmt.Sprintf("%d.000000", len(p.messages)), nil
+}
+
+// Messages lists the messages posted so far, in order.
+func (p *Poster) Messages() []config.Message {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return append([]config.Message(nil), p.messages...)
+}
+
+// New configures the handlers with the settings, which start from config.DefaultSettings, to look things up
+// in the fake Expo and post to the returned Poster. Like LoadFromEnv's, the Config can be shared by requests. Payloads are accepted whatever their age, and processed
+// before the handler responds.
+func New(t testing.TB, server *expotest.Server, settings func(*config.Settings)) (*config.Config, *Poster) {
+	t.Helper()
+	s := config.DefaultSettings()
+	s.ExpoHMACSecrets = config.List{Secret}
+	if settings != nil {
+		settings(&s)
+	}
+	cfg, err := config.New(s)
+	if err != nil {
+		t.Fatalf("failed to configure: %v", err)
+	}
+	cfg.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	// the client keeps the caches the settings configure, as the handlers share them between requests
+	cfg.ExpoClient.BaseURL = server.URL
+	cfg.ExpoClient.HTTPClient = server.Server.Client()
+	cfg.ExpoClient.Logger = cfg.Logger
+	poster := &Poster{}
+	cfg.Poster = poster
+	return cfg, poster
+}
+
+// Sign signs the payload with Secret, as Expo does, for its signature header.
+func Sign(body []byte) string {
+	digest := hmac.New(sha1.New, []byte(Secret))
+	digest.Write(body)
+	return "sha1=" + hex.EncodeToString(digest.Sum(nil))
+}
+
+// Texts lists the text of the blocks, in order, with each element of a context block on its own, so that
+// tests can look for what a message says without matching its layout.
+func Texts(blocks []slack.Block) []string {
+	var texts []string
+	for _, block := range blocks {
+		switch block := block.(type) {
+		case *slack.SectionBlock:
+			if block.Text != nil {
+				texts = append(texts, block.Text.Text)
+			}
+			for _, field := range block.Fields {
+				texts = append(texts, field.Text)
+			}
+		case *slack.ContextBlock:
+			for _, element := range block.ContextElements.Elements {
+				if text, ok := element.(*slack.TextBlockObject); ok {
+					texts = append(texts, text.Text)
+				}
+			}
+		}
+	}
+	return texts
+}
+
+// Webhook is one of the endpoints Expo sends payloads to, for tests to post to.
+type Webhook struct {
+	Handle func(cfg *config.Config, w http.ResponseWriter, r *http.Request)
+	// Header is the header the endpoint takes the payload's signature from.
+	Header string
+	// Sample names the endpoint's sample payload in the test directory, like build.sample.json.
+	Sample string
+}
+
+// Post sends the body to the endpoint with the signature, returning the response.
+func (h Webhook) Post(cfg *config.Config, body []byte, signature string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	r.Header.Set(h.Header, signature)
+	w := httptest.NewRecorder()
+	h.Handle(cfg, w, r)
+	return w
+}
+
+// ReadSample reads the endpoint's sample payload.
+func (h Webhook) ReadSample(t testing.TB) []byte {
+	t.Helper()
+	_, file, _, ok := runtime.Caller(0)
+	if !ok {
+		t.Fatalf("failed to find the test directory")
+	}
+	body, err := os.ReadFile(filepath.Join(filepath.Dir(file), "..", "..", "test", h.Sample))
+	if err != nil {
+		t.Fatalf("failed to read payload: %v", err)
+	}
+	return body
+}
+
+// PostSample sends the endpoint its sample payload; see PostSigned.
+func (h Webhook) PostSample(t testing.TB) config.Message {
+	t.Helper()
+	return h.PostSigned(t, h.ReadSample(t))
+}
+
+// PostSigned sends the endpoint the payload, signed, looking things up in a fake Expo, and returns the message
+// posted for it, failing the test unless there's exactly one.
+func (h Webhook) PostSigned(t testing.TB, body []byte) config.Message {
+	t.Helper()
+	server := expotest.NewServer()
+	t.Cleanup(server.Close)
+	cfg, poster := New(t, server, nil)
+	if w := h.Post(cfg, body, Sign(body)); w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	posted := poster.Messages()
+	if len(posted) != 1 {
+		t.Fatalf("expected one message, got %d", len(posted))
+	}
+	return posted[0]
+}
+
+// RejectsUnsigned checks that the endpoint refuses its sample payload with a bad signature, before looking
+// anything up or posting anything.
+func (h Webhook) RejectsUnsigned(t testing.TB) {
+	t.Helper()
+	server := expotest.NewServer()
+	t.Cleanup(server.Close)
+	cfg, poster := New(t, server, nil)
+	if w := h.Post(cfg, h.ReadSample(t), "sha1=0000"); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+	if posted := poster.Messages(); len(posted) != 0 {
+		t.Errorf("expected nothing to be posted, got %d messages", len(posted))
+	}
+	if operations := server.Operations(); len(operations) != 0 {
+		t.Errorf("expected nothing to be looked up, got %v", operations)
+	}
+}
+
+// ExpectTexts fails the test for each of the expected strings that none of the blocks' Texts contain.
+func ExpectTexts(t testing.TB, blocks []slack.Block, expected ...string) {
+	t.Helper()
+	texts := Texts(blocks)
+	for _, want := range expected {
+		if !slices.ContainsFunc(texts, func(text string) bool { return strings.Contains(text, want) }) {
+			t.Errorf("expected the message to contain %q, got:\n%s", want, strings.Join(texts, "\n"))
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+
+	"github.com/NWACus/expo-slack-webhook/expo"
+)
+
+// EmailPoster emails failed builds and submissions, for teams that don't always have chat open. Other
+// events are skipped, so it can sit alongside any chat destination.
+type EmailPoster struct {
+	// Addr is the host:port of the SMTP server. STARTTLS is used when the server offers it.
+	Addr     string
+	Username string
+	Password string
+	From     string
+	// To receives failures for every platform, unless PlatformTo has recipients for the platform.
+	To         []string
+	PlatformTo map[expo.Platform][]string
+}
+
+func (p *EmailPoster) Post(ctx context.Context, msg Message) (string, error) {
+	e := msg.Event
+	if e.Kind == "update" || !e.Status.Equal(expo.StatusErrored) {
+		return "", nil
+	}
+	to := p.recipients(e.Platform)
+	if len(to) == 0 {
+		return "", nil
+	}
+	var auth smtp.Auth
+	if p.Username != "" {
+		host, _, err := net.SplitHostPort(p.Addr)
+		if err != nil {
+			return "", fmt.Errorf("invalid SMTP address %q: %v", p.Addr, err)
+		}
+		auth = smtp.PlainAuth("", p.Username, p.Password, host)
+	}
+	if err := smtp.SendMail(p.Addr, auth, p.From, to, emailMessage(p.From, to, e)); err != nil {
+		return "", fmt.Errorf("failed to send email: %w", err)
+	}
+	return "", nil
+}
+
+func (p *EmailPoster) recipients(platform expo.Platform) []string {
+	for candidate, to := range p.PlatformTo {
+		if candidate.Equal(platform) && len(to) > 0 {
+			return to
+		}
+	}
+	return p.To
+}
+
+func emailMessage(from string, to []string, e Event) []byte {
+	var body strings.Builder
+	body.WriteString(e.Title + "\r\n\r\n")
+	if e.Error != "" {
+		body.WriteString("Error " + e.Error + "\r\n")
+	}
+	if e.Channel != "" {
+		body.WriteString("Channel: " + e.Channel + "\r\n")
+	}
+	if e.Commit != "" {
+		body.WriteString("Commit: " + expo.CommitURL(e.Repository, e.Commit) + "\r\n")
+	}
+	if e.DetailsURL != "" {
+		body.WriteString("Details: " + e.DetailsURL + "\r\n")
+	}
+
+	var message strings.Builder
+	message.WriteString("From: " + from + "\r\n")
+	message.WriteString("To: " + strings.Join(to, ", ") + "\r\n")
+	message.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", e.Title) + "\r\n")
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	message.WriteString("\r\n")
+	message.WriteString(body.String())
+	return []byte(message.String())
+}
@@ -0,0 +1,181 @@
+package config
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/NWACus/expo-slack-webhook/expo"
+)
+
+// mail is an email received by smtpRecorder.
+type mail struct {
+	From string
+	To   []string
+	Data string
+}
+
+// smtpRecorder is an SMTP server that accepts whatever it's sent, without authentication or TLS, and
+// records the mail.
+type smtpRecorder struct {
+	Addr string
+
+	lock sync.Mutex
+	mail []mail
+}
+
+func newSMTPRecorder(t *testing.T) *smtpRecorder {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+	r := &smtpRecorder{Addr: listener.Addr().String()}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go r.serve(conn)
+		}
+	}()
+	return r
+}
+
+func (r *smtpRecorder) serve(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+	}()
+	text := textproto.NewConn(conn)
+	var received mail
+	reply := func(code int, message string) bool {
+		return text.PrintfLine("%d %s", code, message) == nil
+	}
+	if !reply(220, "localhost ESMTP") {
+		return
+	}
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command, argument, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(command) {
+		case "EHLO", "HELO":
+			reply(250, "localhost")
+		case "MAIL":
+			received.From = strings.Trim(strings.TrimPrefix(argument, "FROM:"), "<>")
+			reply(250, "OK")
+		case "RCPT":
+			received.To = append(received.To, strings.Trim(strings.TrimPrefix(argument, "TO:"), "<>"))
+			reply(250, "OK")
+		case "DATA":
+			reply(354, "go ahead")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			received.Data = string(data)
+			r.lock.Lock()
+			r.mail = append(r.mail, received)
+			r.lock.Unlock()
+			received = mail{}
+			reply(250, "OK")
+		case "QUIT":
+			reply(221, "bye")
+			return
+		default:
+			reply(502, "not implemented")
+		}
+	}
+}
+
+// Mail lists the mail received so far, in order.
+func (r *smtpRecorder) Mail() []mail {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]mail(nil), r.mail...)
+}
+
+func TestEmailPoster(t *testing.T) {
+	server := newSMTPRecorder(t)
+	poster := &EmailPoster{
+		Addr:       server.Addr,
+		From:       "expo@example.com",
+		To:         []string{"mobile@example.com"},
+		PlatformTo: map[expo.Platform][]string{expo.PlatformIOS: {"ios@example.com", "release@example.com"}},
+	}
+	ios := failedBuild
+	ios.Platform = expo.PlatformIOS
+	ios.Title = "iOS build of Avy 1.0.0 (41) failed."
+	for _, e := range []Event{failedBuild, ios} {
+		if _, err := poster.Post(context.Background(), Message{Event: e}); err != nil {
+			t.Fatalf("failed to send email: %v", err)
+		}
+	}
+
+	sent := server.Mail()
+	if len(sent) != 2 {
+		t.Fatalf("expected two emails, got %d", len(sent))
+	}
+	if sent[0].From != "expo@example.com" || strings.Join(sent[0].To, ",") != "mobile@example.com" {
+		t.Errorf("expected the Android failure to go to the default recipients, got %v", sent[0].To)
+	}
+	if got, expected := strings.Join(sent[1].To, ","), "ios@example.com,release@example.com"; got != expected {
+		t.Errorf("expected the iOS failure to go to %s, got %s", expected, got)
+	}
+	// the server reads the data with CRLFs made LFs
+	for _, expected := range []string{
+		"From: expo@example.com\n",
+		"To: mobile@example.com\n",
+		"Subject: Android build of Avy 1.0.0 (41) failed.\n",
+		"Content-Type: text/plain; charset=utf-8\n",
+		"\n\nAndroid build of Avy 1.0.0 (41) failed.\n\n",
+		"Error Gradle build failed\n",
+		"Channel: production\n",
+		"Commit: https://github.com/NWACus/avy/commit/499a175e6eedad4c3a68be1e8d4fbc072c99aefd\n",
+		"Details: https://expo.dev/builds/35425398-97b0-4f02-ac41-beb723090aa2\n",
+	} {
+		if !strings.Contains(sent[0].Data, expected) {
+			t.Errorf("expected the email to contain %q, got:\n%s", expected, sent[0].Data)
+		}
+	}
+}
+
+func TestEmailPosterOnlySendsFailures(t *testing.T) {
+	server := newSMTPRecorder(t)
+	poster := &EmailPoster{
+		Addr:       server.Addr,
+		From:       "expo@example.com",
+		PlatformTo: map[expo.Platform][]string{expo.PlatformIOS: {"ios@example.com"}},
+	}
+	succeeded := failedBuild
+	succeeded.Platform, succeeded.Status = expo.PlatformIOS, expo.StatusFinished
+	update := failedBuild
+	update.Kind, update.Platform = "update", expo.PlatformIOS
+	// nobody receives Android failures
+	for _, e := range []Event{succeeded, update, failedBuild} {
+		if _, err := poster.Post(context.Background(), Message{Event: e}); err != nil {
+			t.Fatalf("failed to post: %v", err)
+		}
+	}
+	if sent := server.Mail(); len(sent) != 0 {
+		t.Errorf("expected no emails, got %d", len(sent))
+	}
+}
+
+func TestEmailMessageEncodesSubject(t *testing.T) {
+	e := failedBuild
+	e.Title = "Échec de la compilation Android."
+	message := string(emailMessage("expo@example.com", []string{"mobile@example.com"}, e))
+	if expected := "\r\nSubject: =?utf-8?q?=C3=89chec_de_la_compilation_Android.?=\r\n"; !strings.Contains(message, expected) {
+		t.Errorf("expected the subject to be encoded as %q, got:\n%s", expected, message)
+	}
+}
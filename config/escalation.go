@@ -0,0 +1,77 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/NWACus/expo-slack-webhook/expo"
+)
+
+// Escalation is how loudly a build or submission is announced.
+type Escalation string
+
+const (
+	// EscalationQuiet posts the message without mentioning anyone.
+	EscalationQuiet Escalation = "quiet"
+	// EscalationPost posts the message, mentioning whoever started a failed build when Mentions is set.
+	EscalationPost Escalation = "post"
+	// EscalationPage also mentions the on-call group and copies the message to EscalationChannel.
+	EscalationPage Escalation = "page"
+)
+
+// EscalationRule sets the escalation for events matching its conditions. Escalation rules are written like
+// routing rules, with the escalation in place of the channel:
+//
+//	channel=production && status=errored -> page
+type EscalationRule struct {
+	Rule
+	Escalation Escalation
+}
+
+// ParseEscalationRules parses each escalation rule in turn.
+func ParseEscalationRules(texts []string) ([]EscalationRule, error) {
+	var rules []EscalationRule
+	for _, text := range texts {
+		rule, err := ParseRule(text)
+		if err != nil {
+			return nil, err
+		}
+		escalation := Escalation(strings.ToLower(rule.Channel))
+		switch escalation {
+		case EscalationQuiet, EscalationPost, EscalationPage:
+		default:
+			return nil, fmt.Errorf("invalid escalation rule %q: escalation must be quiet, post or page", text)
+		}
+		rules = append(rules, EscalationRule{Rule: rule, Escalation: escalation})
+	}
+	return rules, nil
+}
+
+// EscalationFor decides how loudly to announce the event. The first escalation rule it matches decides;
+// failing that, failures on the on-call group's channels page it and everything else is posted.
+func (c *Config) EscalationFor(e Event) Escalation {
+	for _, rule := range c.EscalationRules {
+		if rule.Matches(e) {
+			return rule.Escalation
+		}
+	}
+	if e.Status.Equal(expo.StatusErrored) && c.OnCall != nil && slices.Contains(c.OnCall.Channels, e.Channel) {
+		return EscalationPage
+	}
+	return EscalationPost
+}
+
+// PostEscalation copies a paging message to EscalationChannel, if there is one. It is posted with the bot
+// token alone, so that destinations other than Slack don't get the message twice.
+func (c *Config) PostEscalation(ctx context.Context, msg Message) error {
+	if c.EscalationChannel == "" || c.SlackClient == nil {
+		return nil
+	}
+	msg.ThreadTS = ""
+	msg.Channel = c.EscalationChannel
+	poster := &ChannelPoster{Client: c.SlackClient, Channel: c.EscalationChannel}
+	_, err := poster.Post(ctx, msg)
+	return err
+}
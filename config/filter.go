@@ -0,0 +1,28 @@
+package config
+
+import (
+	"github.com/NWACus/expo-slack-webhook/expo"
+)
+
+// ShouldNotify determines whether an event of the kind (build, submit or update) for the platform and status
+// should be posted, given the configured filters. Statuses configured for the kind of event replace
+// NotifyStatuses for it. An empty filter allows everything.
+func (c *Config) ShouldNotify(kind string, platform expo.Platform, status expo.Status) bool {
+	statuses := c.NotifyStatuses
+	if forKind, ok := c.EventStatuses[kind]; ok && len(forKind) > 0 {
+		statuses = forKind
+	}
+	return matchesAny(c.NotifyPlatforms, platform.Equal) && matchesAny(statuses, status.Equal)
+}
+
+func matchesAny[T any](allowed []T, equal func(T) bool) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, candidate := range allowed {
+		if equal(candidate) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/NWACus/expo-slack-webhook/expo"
+)
+
+func TestShouldNotify(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		cfg      Config
+		kind     string
+		platform expo.Platform
+		status   expo.Status
+		expected bool
+	}{
+		{name: "no filters", kind: "build", platform: expo.PlatformIOS, status: expo.StatusFinished, expected: true},
+		{
+			name:     "listed platform",
+			cfg:      Config{NotifyPlatforms: []expo.Platform{expo.PlatformIOS}},
+			kind:     "build",
+			platform: expo.PlatformIOS,
+			status:   expo.StatusFinished,
+			expected: true,
+		},
+		{
+			name:     "unlisted platform",
+			cfg:      Config{NotifyPlatforms: []expo.Platform{expo.PlatformIOS}},
+			kind:     "build",
+			platform: expo.PlatformAndroid,
+			status:   expo.StatusFinished,
+		},
+		{
+			name:     "platform in another case",
+			cfg:      Config{NotifyPlatforms: []expo.Platform{expo.PlatformIOS}},
+			kind:     "submit",
+			platform: "IOS",
+			status:   expo.StatusFinished,
+			expected: true,
+		},
+		{
+			name:     "listed status",
+			cfg:      Config{NotifyStatuses: []expo.Status{expo.StatusErrored}},
+			kind:     "build",
+			platform: expo.PlatformAndroid,
+			status:   "ERRORED",
+			expected: true,
+		},
+		{
+			name:     "unlisted status",
+			cfg:      Config{NotifyStatuses: []expo.Status{expo.StatusErrored}},
+			kind:     "build",
+			platform: expo.PlatformAndroid,
+			status:   expo.StatusFinished,
+		},
+		{
+			name:     "both filters must match",
+			cfg:      Config{NotifyPlatforms: []expo.Platform{expo.PlatformIOS}, NotifyStatuses: []expo.Status{expo.StatusErrored}},
+			kind:     "build",
+			platform: expo.PlatformAndroid,
+			status:   expo.StatusErrored,
+		},
+		{
+			name: "statuses for the kind replace the others",
+			cfg: Config{
+				NotifyStatuses: []expo.Status{expo.StatusErrored},
+				EventStatuses:  map[string][]expo.Status{"submit": {expo.StatusFinished}},
+			},
+			kind:     "submit",
+			platform: expo.PlatformIOS,
+			status:   expo.StatusFinished,
+			expected: true,
+		},
+		{
+			name: "replaced statuses no longer apply",
+			cfg: Config{
+				NotifyStatuses: []expo.Status{expo.StatusErrored},
+				EventStatuses:  map[string][]expo.Status{"submit": {expo.StatusFinished}},
+			},
+			kind:     "submit",
+			platform: expo.PlatformIOS,
+			status:   expo.StatusErrored,
+		},
+		{
+			name: "statuses for another kind",
+			cfg: Config{
+				NotifyStatuses: []expo.Status{expo.StatusErrored},
+				EventStatuses:  map[string][]expo.Status{"submit": {expo.StatusFinished}},
+			},
+			kind:     "build",
+			platform: expo.PlatformIOS,
+			status:   expo.StatusFinished,
+		},
+		{
+			name: "no statuses for the kind",
+			cfg: Config{
+				NotifyStatuses: []expo.Status{expo.StatusErrored},
+				EventStatuses:  map[string][]expo.Status{"build": {}},
+			},
+			kind:     "build",
+			platform: expo.PlatformIOS,
+			status:   expo.StatusErrored,
+			expected: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.cfg.ShouldNotify(tc.kind, tc.platform, tc.status); got != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
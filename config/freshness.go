@@ -0,0 +1,32 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// CheckPayloadAge limits how long a captured, signed payload can be replayed for by rejecting payloads
+// stamped longer ago than MaxPayloadAge. Payloads without a usable timestamp are only rejected when
+// RequirePayloadTimestamp is set.
+func (c *Config) CheckPayloadAge(timestamp string, now time.Time) error {
+	if c.MaxPayloadAge <= 0 {
+		return nil
+	}
+	if timestamp == "" {
+		if c.RequirePayloadTimestamp {
+			return fmt.Errorf("payload has no timestamp")
+		}
+		return nil
+	}
+	stamped, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		if c.RequirePayloadTimestamp {
+			return fmt.Errorf("failed to parse payload timestamp %q: %v", timestamp, err)
+		}
+		return nil
+	}
+	if age := now.Sub(stamped); age > c.MaxPayloadAge {
+		return fmt.Errorf("payload is %s old, older than the allowed %s", age.Round(time.Second), c.MaxPayloadAge)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/NWACus/expo-slack-webhook/expo"
+)
+
+// failedBuild is the event the destinations' tests post, with everything a build can have set.
+var failedBuild = Event{
+	Kind:           "build",
+	Id:             "35425398-97b0-4f02-ac41-beb723090aa2",
+	AppId:          "47e2fd36-5165-4eb4-9a2d-21beec393379",
+	AppName:        "Avy",
+	Platform:       expo.PlatformAndroid,
+	Status:         expo.StatusErrored,
+	Channel:        "production",
+	Version:        "1.0.0 (41)",
+	Commit:         "499a175e6eedad4c3a68be1e8d4fbc072c99aefd",
+	Repository:     "NWACus/avy",
+	PreviousCommit: "8349b793e0c824f32d4619d7955f0f6b6ce29896",
+	Title:          "Android build of Avy 1.0.0 (41) failed.",
+	DetailsURL:     "https://expo.dev/builds/35425398-97b0-4f02-ac41-beb723090aa2",
+	Error:          "Gradle build failed",
+}
+
+// request is a request a destination sent, as recorded by recorder.
+type request struct {
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// recorder stands in for a destination's API, recording the requests sent to it and responding with
+// status, 200 unless set, and body.
+type recorder struct {
+	*httptest.Server
+	status int
+	body   string
+
+	lock     sync.Mutex
+	requests []request
+}
+
+func newRecorder(t *testing.T) *recorder {
+	t.Helper()
+	r := &recorder{status: http.StatusOK}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			t.Errorf("failed to read request: %v", err)
+		}
+		r.lock.Lock()
+		r.requests = append(r.requests, request{Path: req.URL.Path, Header: req.Header, Body: body})
+		status, response := r.status, r.body
+		r.lock.Unlock()
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, response)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// Requests lists the requests received so far, in order.
+func (r *recorder) Requests() []request {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]request(nil), r.requests...)
+}
+
+// decode unmarshals the body of the request into a generic value, to compare with what's expected
+// without depending on field order.
+func decode(t *testing.T, body []byte) map[string]any {
+	t.Helper()
+	var decoded map[string]any
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatalf("failed to unmarshal request %s: %v", body, err)
+	}
+	return decoded
+}
@@ -0,0 +1,20 @@
+package config
+
+import "fmt"
+
+const (
+	// BuildLayoutText describes a build's version, channel and profile in the title and a line of context,
+	// as the build templates word them.
+	BuildLayoutText = "text"
+	// BuildLayoutFields lists a build's details side by side in fields, which is easier to scan at a glance.
+	BuildLayoutFields = "fields"
+)
+
+// ValidateBuildLayout checks that the layout is one we know; empty is taken to mean BuildLayoutText.
+func ValidateBuildLayout(layout string) error {
+	switch layout {
+	case "", BuildLayoutText, BuildLayoutFields:
+		return nil
+	}
+	return fmt.Errorf("unknown build layout %q, expected %q or %q", layout, BuildLayoutText, BuildLayoutFields)
+}
@@ -2,39 +2,351 @@ package config
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/slack-go/slack"
 
 	"github.com/NWACus/expo-slack-webhook/expo"
+	"github.com/NWACus/expo-slack-webhook/github"
+	"github.com/NWACus/expo-slack-webhook/i18n"
+	"github.com/NWACus/expo-slack-webhook/install"
+	"github.com/NWACus/expo-slack-webhook/issues"
+	"github.com/NWACus/expo-slack-webhook/metrics"
+	"github.com/NWACus/expo-slack-webhook/pairing"
+	"github.com/NWACus/expo-slack-webhook/releases"
+	"github.com/NWACus/expo-slack-webhook/store"
+	"github.com/NWACus/expo-slack-webhook/templates"
+	"github.com/NWACus/expo-slack-webhook/threads"
+	"github.com/NWACus/expo-slack-webhook/topic"
+	"github.com/NWACus/expo-slack-webhook/worker"
+)
+
+const (
+	DefaultUpdateChannelCacheTTL  = 5 * time.Minute
+	DefaultUpdateChannelCacheSize = 100
+	// DefaultAppCacheTTL is how long apps are cached for; we only need their names, icons and where they are
+	// on expo.dev, which hardly ever change.
+	DefaultAppCacheTTL  = time.Hour
+	DefaultAppCacheSize = 10
+	DefaultMaxBodyBytes = 4 << 20
+	// DefaultThreadTTL is how long build messages are remembered to thread under; releases are usually
+	// built, submitted and updated within a day or so.
+	DefaultThreadTTL = 7 * 24 * time.Hour
+	// UpdateChannelCachePrefix is the prefix of the update channels cached in the store, when shared.
+	UpdateChannelCachePrefix = "update-channel/"
 )
 
 type Config struct {
-	ExpoHMACSecret string
-	ExpoClient     *expo.Client
+	// ExpoHMACSecrets are the secrets payloads may be signed with; more than one is accepted so that the
+	// secret can be rotated without rejecting webhooks while Expo and this server disagree.
+	ExpoHMACSecrets []string
+	ExpoClient      *expo.Client
+	// ExpoAppId is the app Slack commands report on when Apps isn't set; see AppIds.
+	ExpoAppId string
+	// DefaultRepository is the GitHub repository, as owner/name, to link commits to for apps without one of
+	// their own; see RepositoryFor.
+	DefaultRepository string
+	// GitHub looks up the commits made since the previous build, up to ChangelogCommits of which are listed,
+	// and reports builds if CommitStatuses, Deployments, PreviewComments or FailureIssueThreshold is set; it is
+	// nil when all are turned off.
+	GitHub           *github.Client
+	ChangelogCommits int
+	// CommitStatuses sets a status on the commit each build was made from, like "EAS build ios/production".
+	CommitStatuses bool
+	// Deployments records each build as a deployment of its commit to the environment named for its channel.
+	Deployments bool
+	// PreviewComments keeps a comment on the pull request each preview build was made from up to date with
+	// the builds.
+	PreviewComments bool
+	// FailureIssueThreshold is how many builds in a row must fail on a channel for a platform before an issue
+	// is opened about them; zero opens none.
+	FailureIssueThreshold int
+	// IssueTrackers link the issues the commits in a changelog mention.
+	IssueTrackers issues.Trackers
+
+	// MaxBodyBytes caps the size of webhook requests we'll read; DefaultMaxBodyBytes is used when unset.
+	MaxBodyBytes int64
+	// MaxPayloadAge is how old a payload may be before we reject it as a replay; zero disables the check.
+	MaxPayloadAge time.Duration
+	// RequirePayloadTimestamp rejects payloads we can't determine the age of when checking for replays.
+	RequirePayloadTimestamp bool
 
+	// SlackClient and SlackChannel are only set when posting with a bot token.
 	SlackClient  *slack.Client
 	SlackChannel string
+	// EventChannels overrides SlackChannel by the kind of event (build, submit or update); see ChannelFor.
+	EventChannels map[string]string
+	// Rules route events to channels before EventChannels is considered; see ChannelFor.
+	Rules []Rule
+	// Apps, when set, are the only apps we accept webhooks for, and may have their own channel and
+	// repository; see ChannelFor and RepositoryFor.
+	Apps   map[string]App
+	Poster Poster
+	// Store keeps state between webhooks, like the messages to thread under.
+	Store store.Store
+	// Threads is only set when messages for the same commit should be threaded; it is safe to use when nil.
+	Threads *threads.Store
+	// Pairs is only set when builds for both platforms should share a message; it is safe to use when nil.
+	Pairs *pairing.Store
+	// Topic is only set when channel topics should show the latest production release; it is safe to use
+	// when nil.
+	Topic *topic.Setter
+	// Mentions is only set when failure messages should mention whoever started the build; it is safe to
+	// use when nil.
+	Mentions *Mentions
+	// OnCall is only set when a user group should be mentioned on failures; it is safe to use when nil.
+	OnCall *OnCall
+	// EscalationRules decide how loudly builds and submissions are announced; see EscalationFor.
+	EscalationRules []EscalationRule
+	// EscalationChannel, when set, gets a copy of the messages that page the on-call group.
+	EscalationChannel string
+	// Releases is only set when a pinned message should show the latest releases; it is safe to use when
+	// nil.
+	Releases *releases.State
+	// InstallQRCodes is only set when internal distribution builds should get a QR code to install them
+	// with; it is safe to use when nil.
+	InstallQRCodes *install.QRCodes
+
+	// SlackSigningSecret verifies requests Slack sends us, like button presses.
+	SlackSigningSecret string
+	// Promotion is only set when updates can be promoted from Slack; it is safe to use when nil.
+	Promotion *Promotion
+	// RollbackUsers are the Slack user IDs allowed to roll updates back from Slack; when empty, there's no
+	// button to do so.
+	RollbackUsers []string
+
+	// Workers is only set when running as a server; when nil, payloads are processed before responding.
+	Workers *worker.Pool
+
+	// NotifyPlatforms and NotifyStatuses, when set, limit which events are posted; see ShouldNotify.
+	NotifyPlatforms []expo.Platform
+	NotifyStatuses  []expo.Status
+	// EventStatuses overrides NotifyStatuses by the kind of event (build or submit).
+	EventStatuses map[string][]expo.Status
+
+	// Emoji overrides the default platform and status emoji; it is safe to use when nil.
+	Emoji *expo.Emoji
+	// Catalog translates the text of messages into another language; it is safe to use when nil, leaving
+	// them in English.
+	Catalog *i18n.Catalog
+	// Templates renders the text of messages, with Emoji and Catalog; it is safe to use when nil, rendering
+	// the default wording and emoji.
+	Templates *templates.Set
+	// BuildLayout is how build messages lay out their details, either BuildLayoutText or BuildLayoutFields;
+	// text is used when unset.
+	BuildLayout string
+
+	Logger *slog.Logger
+	// Metrics is only set when running as a server; it is safe to use when nil.
+	Metrics *metrics.Metrics
+}
+
+// SplitList splits a comma-separated list, as we use to set multiple values in one variable.
+func SplitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// SlackWorkspace is a channel in another Slack workspace to post to, with a bot token for that workspace.
+type SlackWorkspace struct {
+	Channel string
+	Token   string
+}
+
+// ParseSlackWorkspaces parses channel=token pairs, like C0123456789=xoxb-....
+func ParseSlackWorkspaces(pairs []string) ([]SlackWorkspace, error) {
+	var workspaces []SlackWorkspace
+	for _, pair := range pairs {
+		channel, token, ok := strings.Cut(pair, "=")
+		if !ok || channel == "" || token == "" {
+			// don't echo the pair back, as it holds a token
+			return nil, fmt.Errorf("invalid Slack workspace, expected channel=token")
+		}
+		workspaces = append(workspaces, SlackWorkspace{Channel: channel, Token: token})
+	}
+	return workspaces, nil
+}
+
+// EmojiFromEnv reads emoji overrides from variables like EMOJI_IOS=:ios:, keyed by the lower-cased suffix.
+func EmojiFromEnv() map[string]string {
+	overrides := map[string]string{}
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		if key, ok := strings.CutPrefix(name, "EMOJI_"); ok && value != "" {
+			overrides[strings.ToLower(key)] = value
+		}
+	}
+	return overrides
+}
+
+// loaded is the Config the serverless functions share, built on the first call to LoadFromEnv.
+var loaded struct {
+	once sync.Once
+	cfg  *Config
+	err  error
 }
 
+// LoadFromEnv configures the serverless functions from the environment; see SettingsFromEnv. The Config is
+// built once per process, so that warm invocations share the store's connections and the caches, rather than
+// opening new ones with every request.
 func LoadFromEnv() (*Config, error) {
-	config := &Config{}
-	var slackToken, expoToken string
+	loaded.once.Do(func() {
+		loaded.cfg, loaded.err = loadFromEnv()
+	})
+	return loaded.cfg, loaded.err
+}
+
+func loadFromEnv() (*Config, error) {
+	s, err := SettingsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	// unlike the server, we can't take button presses over Socket Mode, so they have to be signed
+	if s.PromoteFrom != "" && s.SlackSigningSecret == "" {
+		return nil, fmt.Errorf("SLACK_SIGNING_SECRET is required with PROMOTE_FROM, to verify button presses")
+	}
+	if len(s.RollbackUsers) > 0 && s.SlackSigningSecret == "" {
+		return nil, fmt.Errorf("SLACK_SIGNING_SECRET is required with ROLLBACK_USERS, to verify button presses")
+	}
+	return New(s)
+}
+
+// SettingsFromEnv reads the settings from the environment variables named for them. Boolean settings are
+// turned on by setting their variable to anything.
+func SettingsFromEnv() (Settings, error) {
+	s := DefaultSettings()
+	var err error
 	for from, into := range map[string]*string{
-		"SLACK_TOKEN":      &slackToken,
-		"SLACK_CHANNEL":    &config.SlackChannel,
-		"EXPO_HMAC_SECRET": &config.ExpoHMACSecret,
-		"EXPO_TOKEN":       &expoToken,
+		"EXPO_TOKEN":                &s.ExpoToken,
+		"EXPO_APP_ID":               &s.ExpoAppId,
+		"SLACK_TOKEN":               &s.SlackToken,
+		"SLACK_CHANNEL":             &s.SlackChannel,
+		"SLACK_WEBHOOK_URL":         &s.SlackWebhookURL,
+		"SLACK_USERNAME":            &s.SlackUsername,
+		"SLACK_ICON_EMOJI":          &s.SlackIconEmoji,
+		"SLACK_BUILD_CHANNEL":       &s.SlackBuildChannel,
+		"SLACK_SUBMIT_CHANNEL":      &s.SlackSubmitChannel,
+		"SLACK_UPDATE_CHANNEL":      &s.SlackUpdateChannel,
+		"TEAMS_WEBHOOK_URL":         &s.TeamsWebhookURL,
+		"ROUTING_RULES_FILE":        &s.RoutingRulesFile,
+		"DEFAULT_REPOSITORY":        &s.DefaultRepository,
+		"GITHUB_TOKEN":              &s.GitHubToken,
+		"JIRA_URL":                  &s.JiraURL,
+		"LINEAR_WORKSPACE":          &s.LinearWorkspace,
+		"MATTERMOST_URL":            &s.MattermostURL,
+		"MATTERMOST_TOKEN":          &s.MattermostToken,
+		"MATTERMOST_CHANNEL":        &s.MattermostChannel,
+		"TELEGRAM_BOT_TOKEN":        &s.TelegramBotToken,
+		"TELEGRAM_CHAT_ID":          &s.TelegramChatID,
+		"SMTP_ADDR":                 &s.SMTPAddr,
+		"SMTP_USERNAME":             &s.SMTPUsername,
+		"SMTP_PASSWORD":             &s.SMTPPassword,
+		"SMTP_FROM":                 &s.SMTPFrom,
+		"PAGERDUTY_ROUTING_KEY":     &s.PagerDutyRoutingKey,
+		"OPSGENIE_API_KEY":          &s.OpsgenieAPIKey,
+		"OPSGENIE_URL":              &s.OpsgenieURL,
+		"OPSGENIE_DEFAULT_PRIORITY": &s.OpsgenieDefaultPriority,
+		"STATE_STORE":               &s.StateStore,
+		"TOPIC_CHANNEL":             &s.TopicChannel,
+		"ONCALL_GROUP":              &s.OnCallGroup,
+		"ESCALATION_CHANNEL":        &s.EscalationChannel,
+		"RELEASES_CHANNEL":          &s.ReleasesChannel,
+		"SLACK_SIGNING_SECRET":      &s.SlackSigningSecret,
+		"PROMOTE_FROM":              &s.PromoteFrom,
+		"PROMOTE_TO":                &s.PromoteTo,
+		"MESSAGE_TEMPLATES":         &s.MessageTemplates,
+		"MESSAGE_TEMPLATES_FILE":    &s.MessageTemplatesFile,
+		"MESSAGE_TEMPLATES_DIR":     &s.MessageTemplatesDir,
+		"LOCALE":                    &s.Locale,
+		"MESSAGE_CATALOGS":          &s.MessageCatalogs,
+		"BUILD_LAYOUT":              &s.BuildLayout,
+		"LOG_FORMAT":                &s.LogFormat,
 	} {
-		value, set := os.LookupEnv(from)
-		if !set || value == "" {
-			return nil, fmt.Errorf("%s not set", from)
+		if value := os.Getenv(from); value != "" {
+			*into = value
 		}
-		*into = value
 	}
-
-	config.SlackClient = slack.New(slackToken)
-	config.ExpoClient = &expo.Client{Token: expoToken}
-
-	return config, nil
+	for from, into := range map[string]*List{
+		"EXPO_HMAC_SECRET":       &s.ExpoHMACSecrets,
+		"ROUTING_RULES":          &s.RoutingRules,
+		"APP_CHANNELS":           &s.AppChannels,
+		"APP_REPOSITORIES":       &s.AppRepositories,
+		"JIRA_PROJECTS":          &s.JiraProjects,
+		"LINEAR_TEAMS":           &s.LinearTeams,
+		"SLACK_WORKSPACES":       &s.SlackWorkspaces,
+		"EMAIL_TO":               &s.EmailTo,
+		"EMAIL_TO_IOS":           &s.EmailToIOS,
+		"EMAIL_TO_ANDROID":       &s.EmailToAndroid,
+		"OPSGENIE_PRIORITIES":    &s.OpsgeniePriorities,
+		"SLACK_USERS":            &s.SlackUsers,
+		"ONCALL_CHANNELS":        &s.OnCallChannels,
+		"ESCALATION_RULES":       &s.EscalationRules,
+		"PROMOTE_USERS":          &s.PromoteUsers,
+		"ROLLBACK_USERS":         &s.RollbackUsers,
+		"NOTIFY_PLATFORMS":       &s.NotifyPlatforms,
+		"NOTIFY_STATUSES":        &s.NotifyStatuses,
+		"NOTIFY_BUILD_STATUSES":  &s.NotifyBuildStatuses,
+		"NOTIFY_SUBMIT_STATUSES": &s.NotifySubmitStatuses,
+	} {
+		if value := os.Getenv(from); value != "" {
+			*into = SplitList(value)
+		}
+	}
+	for from, into := range map[string]*bool{
+		"GITHUB_COMMIT_STATUSES":      &s.GitHubCommitStatuses,
+		"GITHUB_DEPLOYMENTS":          &s.GitHubDeployments,
+		"GITHUB_PREVIEW_COMMENTS":     &s.GitHubPreviewComments,
+		"THREAD_BY_COMMIT":            &s.ThreadByCommit,
+		"SET_TOPIC":                   &s.SetTopic,
+		"MENTION_FAILURES":            &s.MentionFailures,
+		"PIN_RELEASES":                &s.PinReleases,
+		"INSTALL_QR_CODES":            &s.InstallQRCodes,
+		"UPDATE_CHANNEL_CACHE_SHARED": &s.UpdateChannelCacheShared,
+		"REQUIRE_PAYLOAD_TIMESTAMP":   &s.RequirePayloadTimestamp,
+	} {
+		_, *into = os.LookupEnv(from)
+	}
+	for from, into := range map[string]*int{
+		"CHANGELOG_COMMITS":           &s.ChangelogCommits,
+		"GITHUB_ISSUE_AFTER_FAILURES": &s.GitHubIssueAfterFailures,
+		"UPDATE_CHANNEL_CACHE_SIZE":   &s.UpdateChannelCacheSize,
+		"SEARCH_LIMIT":                &s.SearchLimit,
+	} {
+		if value := os.Getenv(from); value != "" {
+			if *into, err = strconv.Atoi(value); err != nil {
+				return s, fmt.Errorf("failed to parse %s: %v", from, err)
+			}
+		}
+	}
+	for from, into := range map[string]*time.Duration{
+		"UPDATE_CHANNEL_CACHE_TTL": &s.UpdateChannelCacheTTL,
+		"MAX_PAYLOAD_AGE":          &s.MaxPayloadAge,
+	} {
+		if value := os.Getenv(from); value != "" {
+			if *into, err = time.ParseDuration(value); err != nil {
+				return s, fmt.Errorf("failed to parse %s: %v", from, err)
+			}
+		}
+	}
+	if value := os.Getenv("MAX_BODY_BYTES"); value != "" {
+		s.MaxBodyBytes, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return s, fmt.Errorf("failed to parse MAX_BODY_BYTES: %v", err)
+		}
+	}
+	s.Emoji = EmojiFromEnv()
+	return s, nil
 }
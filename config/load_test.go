@@ -0,0 +1,29 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestLoadFromEnvOncePerProcess(t *testing.T) {
+	t.Setenv("EXPO_HMAC_SECRET", "secret")
+	t.Setenv("EXPO_TOKEN", "token")
+	t.Setenv("SLACK_WEBHOOK_URL", "https://hooks.slack.com/services/T000/B000/XXX")
+	t.Setenv("STATE_STORE", "redis://"+miniredis.RunT(t).Addr())
+
+	first, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	second, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if first != second {
+		t.Errorf("expected the config to be built once and shared")
+	}
+	if first.Store == nil || first.Store != second.Store {
+		t.Errorf("expected the Redis client, and the connections it pools, to be shared")
+	}
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/NWACus/expo-slack-webhook/expo"
+)
+
+// markdownSummary renders the event in the common Markdown dialect most chat tools understand, using
+// Unicode rather than Slack's emoji shortcodes and [text](url) rather than Slack's <url|text> links.
+func markdownSummary(e Event) string {
+	lines := []string{fmt.Sprintf("**%s %s**", expo.StatusSymbol(e.Status), e.Title)}
+	var details []string
+	if e.Channel != "" {
+		details = append(details, fmt.Sprintf("Channel `%s`", e.Channel))
+	}
+	if e.Commit != "" {
+		details = append(details, fmt.Sprintf("Commit [%s](%s)", expo.ShortCommit(e.Commit), expo.CommitURL(e.Repository, e.Commit)))
+	}
+	if len(details) > 0 {
+		lines = append(lines, strings.Join(details, " · "))
+	}
+	if e.Commit != "" && e.PreviousCommit != "" {
+		lines = append(lines, fmt.Sprintf("See the [changelog](%s) since [%s](%s).", expo.CompareURL(e.Repository, e.PreviousCommit, e.Commit), expo.ShortCommit(e.PreviousCommit), expo.CommitURL(e.Repository, e.PreviousCommit)))
+	}
+	if e.Error != "" {
+		lines = append(lines, "Error "+e.Error)
+	}
+	if e.DetailsURL != "" {
+		lines = append(lines, fmt.Sprintf("See details [here](%s).", e.DetailsURL))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// MattermostPoster posts into a Mattermost channel using a bot or personal access token. Mattermost
+// understands much of Slack's formatting, but not Block Kit or Slack's link syntax, so the message is
+// rendered from the Event. The post's ID is returned, which Mattermost uses to thread replies.
+type MattermostPoster struct {
+	// URL is the base URL of the Mattermost server, like https://chat.example.com.
+	URL       string
+	Token     string
+	ChannelID string
+	Client    *http.Client
+}
+
+type mattermostPost struct {
+	ChannelID string `json:"channel_id"`
+	Message   string `json:"message"`
+	RootID    string `json:"root_id,omitempty"`
+}
+
+func (p *MattermostPoster) Post(ctx context.Context, msg Message) (string, error) {
+	body, err := json.Marshal(mattermostPost{
+		ChannelID: p.ChannelID,
+		Message:   markdownSummary(msg.Event),
+		RootID:    msg.ThreadTS,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal post: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(p.URL, "/")+"/api/v4/posts", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.Token)
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	if closeErr := resp.Body.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %v", err)
+	}
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%d: %s", resp.StatusCode, string(respBody))
+	}
+	var created struct {
+		Id string `json:"id"`
+	}
+	if err := json.Unmarshal(respBody, &created); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response: %v", err)
+	}
+	return created.Id, nil
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestMattermostPoster(t *testing.T) {
+	api := newRecorder(t)
+	api.status, api.body = http.StatusCreated, `{"id":"8xk4j1g3o7ny5m9w6t2bqe1rpa"}`
+	poster := &MattermostPoster{URL: api.URL + "/", Token: "token", ChannelID: "channel"}
+	id, err := poster.Post(context.Background(), Message{Event: failedBuild, ThreadTS: "root"})
+	if err != nil {
+		t.Fatalf("failed to post: %v", err)
+	}
+	if expected := "8xk4j1g3o7ny5m9w6t2bqe1rpa"; id != expected {
+		t.Errorf("expected the post's ID %q to be returned, got %q", expected, id)
+	}
+
+	requests := api.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("expected one request, got %d", len(requests))
+	}
+	if got, expected := requests[0].Path, "/api/v4/posts"; got != expected {
+		t.Errorf("expected a post to %s, got %s", expected, got)
+	}
+	if got, expected := requests[0].Header.Get("Authorization"), "Bearer token"; got != expected {
+		t.Errorf("expected authorization %q, got %q", expected, got)
+	}
+	post := decode(t, requests[0].Body)
+	for field, expected := range map[string]any{
+		"channel_id": "channel",
+		"root_id":    "root",
+		"message": "**🔴 Android build of Avy 1.0.0 (41) failed.**\n" +
+			"Channel `production` · Commit [499a175](https://github.com/NWACus/avy/commit/499a175e6eedad4c3a68be1e8d4fbc072c99aefd)\n" +
+			"See the [changelog](https://github.com/NWACus/avy/compare/8349b793e0c824f32d4619d7955f0f6b6ce29896...499a175e6eedad4c3a68be1e8d4fbc072c99aefd) since [8349b79](https://github.com/NWACus/avy/commit/8349b793e0c824f32d4619d7955f0f6b6ce29896).\n" +
+			"Error Gradle build failed\n" +
+			"See details [here](https://expo.dev/builds/35425398-97b0-4f02-ac41-beb723090aa2).",
+	} {
+		if post[field] != expected {
+			t.Errorf("expected %s to be %q, got %q", field, expected, post[field])
+		}
+	}
+}
+
+func TestMattermostPosterErrors(t *testing.T) {
+	api := newRecorder(t)
+	api.status, api.body = http.StatusForbidden, `{"message":"You do not have the appropriate permissions."}`
+	poster := &MattermostPoster{URL: api.URL, Token: "token", ChannelID: "channel"}
+	if _, err := poster.Post(context.Background(), Message{Event: failedBuild}); err == nil {
+		t.Errorf("expected an error when the post is refused")
+	}
+	// replies are only threaded when there's a post to thread them under
+	if _, ok := decode(t, api.Requests()[0].Body)["root_id"]; ok {
+		t.Errorf("expected no root_id for a post outside a thread")
+	}
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/slack-go/slack"
+)
+
+// Mentions finds the Slack users to mention in failure messages by their email address. A nil *Mentions is
+// valid and mentions nobody.
+type Mentions struct {
+	Client *slack.Client
+	// Users maps email addresses to Slack user IDs, for people whose Slack account has another address or
+	// who'd rather someone else was told; see ParseSlackUsers.
+	Users map[string]string
+}
+
+// ParseSlackUsers parses email=userId pairs, like jdoe@example.com=U0123456789.
+func ParseSlackUsers(pairs []string) (map[string]string, error) {
+	users := map[string]string{}
+	for _, pair := range pairs {
+		email, user, ok := strings.Cut(pair, "=")
+		if !ok || email == "" || user == "" {
+			return nil, fmt.Errorf("invalid Slack user %q, expected email=userId", pair)
+		}
+		users[strings.ToLower(email)] = user
+	}
+	return users, nil
+}
+
+// Mention returns the mrkdwn mentioning the Slack user with the email address, or an empty string if
+// there's none we know of.
+func (m *Mentions) Mention(ctx context.Context, email string) (string, error) {
+	if m == nil || email == "" {
+		return "", nil
+	}
+	if user, ok := m.Users[strings.ToLower(email)]; ok {
+		return fmt.Sprintf("<@%s>", user), nil
+	}
+	user, err := m.Client.GetUserByEmailContext(ctx, email)
+	if err != nil {
+		if err.Error() == "users_not_found" {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to look up Slack user by email: %v", err)
+	}
+	return fmt.Sprintf("<@%s>", user.ID), nil
+}
+
+// DefaultOnCallChannels are the update channels whose failures the on-call group is mentioned for unless
+// others are configured.
+var DefaultOnCallChannels = []string{"production"}
+
+// OnCall mentions a Slack user group when builds or submissions are paged about; see EscalationFor. A nil
+// *OnCall mentions nobody.
+type OnCall struct {
+	Client *slack.Client
+	// Group is the user group's ID, like S0123456789, or its handle, like @mobile-oncall, which is looked
+	// up the first time it's needed.
+	Group string
+	// Channels are the update channels failures page the group for, unless escalation rules say otherwise.
+	Channels []string
+
+	lock sync.Mutex
+	id   string
+}
+
+// Mention returns the mrkdwn mentioning the group.
+func (o *OnCall) Mention(ctx context.Context) (string, error) {
+	if o == nil {
+		return "", nil
+	}
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.id == "" {
+		handle, isHandle := strings.CutPrefix(o.Group, "@")
+		if !isHandle {
+			o.id = o.Group
+		} else {
+			groups, err := o.Client.GetUserGroupsContext(ctx)
+			if err != nil {
+				return "", fmt.Errorf("failed to list Slack user groups: %v", err)
+			}
+			for _, group := range groups {
+				if group.Handle == handle {
+					o.id = group.ID
+				}
+			}
+			if o.id == "" {
+				return "", fmt.Errorf("no Slack user group has the handle %s", o.Group)
+			}
+		}
+	}
+	return fmt.Sprintf("<!subteam^%s>", o.id), nil
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/NWACus/expo-slack-webhook/expo"
+)
+
+// DefaultOpsgenieURL is Opsgenie's API in the US region; accounts in the EU use https://api.eu.opsgenie.com.
+const DefaultOpsgenieURL = "https://api.opsgenie.com"
+
+// DefaultOpsgeniePriority is the priority of alerts for channels without their own.
+const DefaultOpsgeniePriority = "P3"
+
+// DefaultOpsgeniePriorities raises failures that block a release above ones that only affect testers.
+var DefaultOpsgeniePriorities = map[string]string{
+	"production": "P2",
+	"preview":    "P5",
+}
+
+// OpsgeniePoster creates an Opsgenie alert for each failed build or submission, aliased by its ID so
+// redeliveries don't raise duplicates.
+type OpsgeniePoster struct {
+	APIKey string
+	// URL is the base URL of the Opsgenie API, DefaultOpsgenieURL if empty.
+	URL string
+	// Priorities maps update channels to alert priorities, P1 to P5. Channels not in the map get
+	// DefaultPriority.
+	Priorities      map[string]string
+	DefaultPriority string
+	Client          *http.Client
+}
+
+type opsgenieAlert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description,omitempty"`
+	Priority    string            `json:"priority"`
+	Source      string            `json:"source"`
+	Tags        []string          `json:"tags,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+}
+
+// opsgenieMessageLimit is the longest alert message Opsgenie accepts.
+const opsgenieMessageLimit = 130
+
+func (p *OpsgeniePoster) Post(ctx context.Context, msg Message) (string, error) {
+	e := msg.Event
+	if e.Kind == "update" || !e.Status.Equal(expo.StatusErrored) {
+		return "", nil
+	}
+	message := e.Title
+	if runes := []rune(message); len(runes) > opsgenieMessageLimit {
+		message = string(runes[:opsgenieMessageLimit-1]) + "…"
+	}
+	details := map[string]string{"platform": string(e.Platform)}
+	if e.Channel != "" {
+		details["channel"] = e.Channel
+	}
+	if e.Commit != "" {
+		details["commit"] = e.Commit
+	}
+	if e.DetailsURL != "" {
+		details["url"] = e.DetailsURL
+	}
+	baseURL := p.URL
+	if baseURL == "" {
+		baseURL = DefaultOpsgenieURL
+	}
+	return "", postJSON(ctx, p.Client, strings.TrimSuffix(baseURL, "/")+"/v2/alerts", opsgenieAlert{
+		Message:     message,
+		Alias:       e.Id,
+		Description: e.Error,
+		Priority:    p.priorityFor(e.Channel),
+		Source:      "expo",
+		Tags:        []string{e.Kind, strings.ToLower(string(e.Platform))},
+		Details:     details,
+	}, map[string]string{"Authorization": "GenieKey " + p.APIKey})
+}
+
+func (p *OpsgeniePoster) priorityFor(channel string) string {
+	for candidate, priority := range p.Priorities {
+		if strings.EqualFold(candidate, channel) {
+			return priority
+		}
+	}
+	if p.DefaultPriority != "" {
+		return p.DefaultPriority
+	}
+	return DefaultOpsgeniePriority
+}
+
+// ParseOpsgeniePriorities parses channel=priority pairs, like production=P2, checking each priority is one
+// Opsgenie knows.
+func ParseOpsgeniePriorities(pairs []string) (map[string]string, error) {
+	priorities := map[string]string{}
+	for _, pair := range pairs {
+		channel, priority, ok := strings.Cut(pair, "=")
+		if !ok || channel == "" {
+			return nil, fmt.Errorf("invalid Opsgenie priority %q, expected channel=priority", pair)
+		}
+		if err := ValidateOpsgeniePriority(priority); err != nil {
+			return nil, err
+		}
+		priorities[channel] = strings.ToUpper(priority)
+	}
+	return priorities, nil
+}
+
+// ValidateOpsgeniePriority checks the priority is one of P1 to P5.
+func ValidateOpsgeniePriority(priority string) error {
+	switch strings.ToUpper(priority) {
+	case "P1", "P2", "P3", "P4", "P5":
+		return nil
+	}
+	return fmt.Errorf("invalid Opsgenie priority %q, expected P1 to P5", priority)
+}
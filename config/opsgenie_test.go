@@ -0,0 +1,130 @@
+package config
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/NWACus/expo-slack-webhook/expo"
+)
+
+func TestOpsgeniePoster(t *testing.T) {
+	api := newRecorder(t)
+	poster := &OpsgeniePoster{APIKey: "key", URL: api.URL + "/", Priorities: DefaultOpsgeniePriorities}
+	if _, err := poster.Post(context.Background(), Message{Event: failedBuild}); err != nil {
+		t.Fatalf("failed to post: %v", err)
+	}
+
+	requests := api.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("expected one request, got %d", len(requests))
+	}
+	if got, expected := requests[0].Path, "/v2/alerts"; got != expected {
+		t.Errorf("expected an alert created at %s, got %s", expected, got)
+	}
+	if got, expected := requests[0].Header.Get("Authorization"), "GenieKey key"; got != expected {
+		t.Errorf("expected authorization %q, got %q", expected, got)
+	}
+	alert := decode(t, requests[0].Body)
+	for field, expected := range map[string]any{
+		"message":     failedBuild.Title,
+		"alias":       failedBuild.Id,
+		"description": "Gradle build failed",
+		"priority":    "P2",
+		"source":      "expo",
+	} {
+		if alert[field] != expected {
+			t.Errorf("expected %s to be %v, got %v", field, expected, alert[field])
+		}
+	}
+	if tags, _ := alert["tags"].([]any); len(tags) != 2 || tags[0] != "build" || tags[1] != "android" {
+		t.Errorf("expected the kind and platform as tags, got %v", alert["tags"])
+	}
+	details, _ := alert["details"].(map[string]any)
+	for field, expected := range map[string]string{
+		"platform": "android",
+		"channel":  "production",
+		"commit":   failedBuild.Commit,
+		"url":      failedBuild.DetailsURL,
+	} {
+		if details[field] != expected {
+			t.Errorf("expected details.%s to be %q, got %v", field, expected, details[field])
+		}
+	}
+}
+
+func TestOpsgeniePosterTruncatesMessage(t *testing.T) {
+	api := newRecorder(t)
+	poster := &OpsgeniePoster{APIKey: "key", URL: api.URL}
+	for _, tc := range []struct {
+		name, title, expected string
+	}{
+		{name: "at the limit", title: strings.Repeat("a", 130), expected: strings.Repeat("a", 130)},
+		{name: "past the limit", title: strings.Repeat("a", 131), expected: strings.Repeat("a", 129) + "…"},
+		// the limit is in characters, so a title of emoji isn't cut mid-character
+		{name: "multi-byte", title: strings.Repeat("🔴", 131), expected: strings.Repeat("🔴", 129) + "…"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			e := failedBuild
+			e.Title = tc.title
+			if _, err := poster.Post(context.Background(), Message{Event: e}); err != nil {
+				t.Fatalf("failed to post: %v", err)
+			}
+			requests := api.Requests()
+			message, _ := decode(t, requests[len(requests)-1].Body)["message"].(string)
+			if message != tc.expected {
+				t.Errorf("expected message %q, got %q", tc.expected, message)
+			}
+			if !utf8.ValidString(message) || utf8.RuneCountInString(message) > opsgenieMessageLimit {
+				t.Errorf("expected at most %d characters, got %d", opsgenieMessageLimit, utf8.RuneCountInString(message))
+			}
+		})
+	}
+}
+
+func TestOpsgeniePosterPriority(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		priorities map[string]string
+		fallback   string
+		channel    string
+		expected   string
+	}{
+		{name: "default priorities", priorities: DefaultOpsgeniePriorities, channel: "preview", expected: "P5"},
+		{name: "channel in another case", priorities: map[string]string{"production": "P1"}, channel: "Production", expected: "P1"},
+		{name: "unlisted channel", priorities: map[string]string{"production": "P1"}, channel: "staging", expected: DefaultOpsgeniePriority},
+		{name: "configured default", priorities: map[string]string{"production": "P1"}, fallback: "P4", channel: "staging", expected: "P4"},
+		{name: "no channel", fallback: "P4", expected: "P4"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			api := newRecorder(t)
+			poster := &OpsgeniePoster{APIKey: "key", URL: api.URL, Priorities: tc.priorities, DefaultPriority: tc.fallback}
+			e := failedBuild
+			e.Channel = tc.channel
+			if _, err := poster.Post(context.Background(), Message{Event: e}); err != nil {
+				t.Fatalf("failed to post: %v", err)
+			}
+			if got := decode(t, api.Requests()[0].Body)["priority"]; got != tc.expected {
+				t.Errorf("expected priority %s, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestOpsgeniePosterSkipsOtherEvents(t *testing.T) {
+	api := newRecorder(t)
+	poster := &OpsgeniePoster{APIKey: "key", URL: api.URL}
+	succeeded := failedBuild
+	succeeded.Status = expo.StatusFinished
+	update := failedBuild
+	update.Kind = "update"
+	for _, e := range []Event{succeeded, update} {
+		if _, err := poster.Post(context.Background(), Message{Event: e}); err != nil {
+			t.Fatalf("failed to post: %v", err)
+		}
+	}
+	if requests := api.Requests(); len(requests) != 0 {
+		t.Errorf("expected only failed builds and submissions to raise alerts, got %d", len(requests))
+	}
+}
@@ -0,0 +1,177 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/NWACus/expo-slack-webhook/expo"
+	"github.com/NWACus/expo-slack-webhook/store"
+)
+
+// pagerDutyEventsURL is the PagerDuty Events API v2 endpoint.
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDutyPoster opens a PagerDuty incident for each failed build or submission, deduplicated on its ID,
+// and resolves them once a later one for the same app, channel and platform succeeds.
+type PagerDutyPoster struct {
+	// RoutingKey is the integration key of an Events API v2 integration on the service.
+	RoutingKey string
+	// Store holds the dedup keys of the incidents left open for each app, channel and platform, so that a
+	// success resolves them however many processes later it comes, as it does on serverless.
+	Store  store.Store
+	Client *http.Client
+	// URL is the Events API v2 endpoint, PagerDuty's own if empty.
+	URL string
+
+	// lock serialises reading and writing the open incidents within the process.
+	lock sync.Mutex
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+	Links       []pagerDutyLink   `json:"links,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	Component     string            `json:"component,omitempty"`
+	Group         string            `json:"group,omitempty"`
+	Class         string            `json:"class,omitempty"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+type pagerDutyLink struct {
+	Href string `json:"href"`
+	Text string `json:"text"`
+}
+
+func (p *PagerDutyPoster) Post(ctx context.Context, msg Message) (string, error) {
+	e := msg.Event
+	if e.Kind == "update" {
+		return "", nil
+	}
+	key := pagerDutyKey(e)
+	switch {
+	case e.Status.Equal(expo.StatusErrored):
+		if err := p.trigger(ctx, e); err != nil {
+			return "", err
+		}
+		p.lock.Lock()
+		defer p.lock.Unlock()
+		open, err := p.load(ctx, key)
+		if err != nil {
+			return "", err
+		}
+		return "", p.save(ctx, key, append(open, e.Id))
+	case e.Status.Equal(expo.StatusFinished):
+		p.lock.Lock()
+		defer p.lock.Unlock()
+		open, err := p.load(ctx, key)
+		if err != nil || len(open) == 0 {
+			return "", err
+		}
+		var failed []string
+		var errs []error
+		for _, id := range open {
+			if err := p.resolve(ctx, id); err != nil {
+				failed = append(failed, id)
+				errs = append(errs, fmt.Errorf("failed to resolve incident for %s: %w", id, err))
+			}
+		}
+		// the ones we failed to resolve are tried again on the next success
+		if err := p.save(ctx, key, failed); err != nil {
+			errs = append(errs, err)
+		}
+		return "", errors.Join(errs...)
+	}
+	return "", nil
+}
+
+// pagerDutyKey identifies the stream of events a success resolves the failures of: the same kind of event,
+// for the same app, channel and platform.
+func pagerDutyKey(e Event) string {
+	return "pagerduty/" + strings.Join([]string{e.Kind, e.AppId, e.Channel, strings.ToLower(string(e.Platform))}, "/")
+}
+
+func (p *PagerDutyPoster) load(ctx context.Context, key string) ([]string, error) {
+	raw, ok, err := p.Store.Get(ctx, key)
+	if err != nil || !ok {
+		return nil, err
+	}
+	var open []string
+	if err := json.Unmarshal([]byte(raw), &open); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal open incidents: %v", err)
+	}
+	return open, nil
+}
+
+func (p *PagerDutyPoster) save(ctx context.Context, key string, open []string) error {
+	if len(open) == 0 {
+		return p.Store.Delete(ctx, key)
+	}
+	raw, err := json.Marshal(open)
+	if err != nil {
+		return fmt.Errorf("failed to marshal open incidents: %v", err)
+	}
+	// incidents stay open until something resolves them, however long that takes
+	return p.Store.Set(ctx, key, string(raw), 0)
+}
+
+func (p *PagerDutyPoster) url() string {
+	if p.URL == "" {
+		return pagerDutyEventsURL
+	}
+	return p.URL
+}
+
+func (p *PagerDutyPoster) trigger(ctx context.Context, e Event) error {
+	details := map[string]string{"platform": string(e.Platform)}
+	if e.Channel != "" {
+		details["channel"] = e.Channel
+	}
+	if e.Version != "" {
+		details["version"] = e.Version
+	}
+	if e.Commit != "" {
+		details["commit"] = e.Commit
+	}
+	if e.Error != "" {
+		details["error"] = e.Error
+	}
+	event := pagerDutyEvent{
+		RoutingKey:  p.RoutingKey,
+		EventAction: "trigger",
+		DedupKey:    e.Id,
+		Payload: &pagerDutyPayload{
+			Summary:       e.Title,
+			Source:        "expo",
+			Severity:      "error",
+			Component:     e.AppName,
+			Group:         e.Channel,
+			Class:         e.Kind,
+			CustomDetails: details,
+		},
+	}
+	if e.DetailsURL != "" {
+		event.Links = append(event.Links, pagerDutyLink{Href: e.DetailsURL, Text: "View on Expo"})
+	}
+	return postJSON(ctx, p.Client, p.url(), event, nil)
+}
+
+func (p *PagerDutyPoster) resolve(ctx context.Context, id string) error {
+	return postJSON(ctx, p.Client, p.url(), pagerDutyEvent{
+		RoutingKey:  p.RoutingKey,
+		EventAction: "resolve",
+		DedupKey:    id,
+	}, nil)
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/NWACus/expo-slack-webhook/expo"
+	"github.com/NWACus/expo-slack-webhook/store"
+)
+
+func TestPagerDutyPoster(t *testing.T) {
+	api := newRecorder(t)
+	shared := store.NewMemory()
+	// each Post is made by a poster of its own, as each serverless invocation builds one
+	poster := func() *PagerDutyPoster {
+		return &PagerDutyPoster{RoutingKey: "routing-key", Store: shared, URL: api.URL}
+	}
+	failed := Event{
+		Kind:       "build",
+		Id:         "35425398-97b0-4f02-ac41-beb723090aa2",
+		AppId:      "47e2fd36-5165-4eb4-9a2d-21beec393379",
+		AppName:    "Avy",
+		Platform:   expo.PlatformAndroid,
+		Status:     expo.StatusErrored,
+		Channel:    "production",
+		Version:    "1.0.0 (41)",
+		Title:      "Android build of Avy 1.0.0 (41) failed.",
+		DetailsURL: "https://expo.dev/builds/35425398-97b0-4f02-ac41-beb723090aa2",
+		Error:      "Gradle build failed",
+	}
+	ctx := context.Background()
+
+	if _, err := poster().Post(ctx, Message{Event: failed}); err != nil {
+		t.Fatalf("failed to post failure: %v", err)
+	}
+	// a success on another channel leaves the incident open
+	other := failed
+	other.Id, other.Status, other.Channel = "other", expo.StatusFinished, "preview"
+	if _, err := poster().Post(ctx, Message{Event: other}); err != nil {
+		t.Fatalf("failed to post success: %v", err)
+	}
+	succeeded := failed
+	succeeded.Id, succeeded.Status = "a6b1c0a4-3a1e-4c54-9f57-0b5d0f6f2f1e", expo.StatusFinished
+	if _, err := poster().Post(ctx, Message{Event: succeeded}); err != nil {
+		t.Fatalf("failed to post success: %v", err)
+	}
+	// updates and later successes have nothing to resolve
+	for _, e := range []Event{{Kind: "update", Status: expo.StatusFinished}, succeeded} {
+		if _, err := poster().Post(ctx, Message{Event: e}); err != nil {
+			t.Fatalf("failed to post: %v", err)
+		}
+	}
+
+	requests := api.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("expected a trigger and a resolve, got %d requests", len(requests))
+	}
+	trigger := decode(t, requests[0].Body)
+	for field, expected := range map[string]any{
+		"routing_key":  "routing-key",
+		"event_action": "trigger",
+		"dedup_key":    failed.Id,
+	} {
+		if trigger[field] != expected {
+			t.Errorf("expected %s to be %v, got %v", field, expected, trigger[field])
+		}
+	}
+	payload, _ := trigger["payload"].(map[string]any)
+	for field, expected := range map[string]any{
+		"summary":   failed.Title,
+		"source":    "expo",
+		"severity":  "error",
+		"component": "Avy",
+		"group":     "production",
+		"class":     "build",
+	} {
+		if payload[field] != expected {
+			t.Errorf("expected payload.%s to be %v, got %v", field, expected, payload[field])
+		}
+	}
+	details, _ := payload["custom_details"].(map[string]any)
+	if details["error"] != "Gradle build failed" || details["version"] != "1.0.0 (41)" {
+		t.Errorf("expected the error and version in the details, got %v", details)
+	}
+	resolve := decode(t, requests[1].Body)
+	if resolve["event_action"] != "resolve" || resolve["dedup_key"] != failed.Id {
+		t.Errorf("expected the incident to be resolved, got %s", requests[1].Body)
+	}
+	if _, ok, _ := shared.Get(ctx, pagerDutyKey(failed)); ok {
+		t.Errorf("expected no incidents to be left open")
+	}
+}
+
+func TestPagerDutyPosterRetriesResolve(t *testing.T) {
+	api := newRecorder(t)
+	poster := &PagerDutyPoster{RoutingKey: "routing-key", Store: store.NewMemory(), URL: api.URL}
+	failed := Event{Kind: "submit", Id: "812c84ca", AppId: "app", Platform: expo.PlatformIOS, Status: expo.StatusErrored}
+	succeeded := failed
+	succeeded.Id, succeeded.Status = "4be0f7a1", expo.StatusFinished
+	ctx := context.Background()
+
+	if _, err := poster.Post(ctx, Message{Event: failed}); err != nil {
+		t.Fatalf("failed to post failure: %v", err)
+	}
+	api.status = http.StatusServiceUnavailable
+	if _, err := poster.Post(ctx, Message{Event: succeeded}); err == nil {
+		t.Fatalf("expected an error when resolving fails")
+	}
+	api.status = http.StatusAccepted
+	if _, err := poster.Post(ctx, Message{Event: succeeded}); err != nil {
+		t.Fatalf("failed to post success: %v", err)
+	}
+	requests := api.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("expected a trigger and two resolves, got %d requests", len(requests))
+	}
+	if resolve := decode(t, requests[2].Body); resolve["event_action"] != "resolve" || resolve["dedup_key"] != failed.Id {
+		t.Errorf("expected the resolve to be retried, got %s", requests[2].Body)
+	}
+}
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"context"
+	"errors"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackutilsx"
+
+	"github.com/NWACus/expo-slack-webhook/expo"
+)
+
+// Message is a notification for a Poster to deliver.
+type Message struct {
+	Blocks []slack.Block
+	// Text is a plain-text summary of the message, which Slack shows in notifications and to screen readers
+	// in place of the blocks.
+	Text string
+	// ThreadTS, when set, posts the message as a reply in the thread of the message with that timestamp.
+	ThreadTS string
+	// Channel, when set, overrides the channel a bot token posts to.
+	Channel string
+	// Event describes what the message is about, for destinations that don't render Slack blocks.
+	Event Event
+	// Unchanged, when updating a message, means the event it's about has the same status as when it was
+	// last posted, so destinations that can't edit what they posted aren't sent it again; see MultiPoster.
+	Unchanged bool
+}
+
+// Event summarises a build, submission or update in plain terms.
+type Event struct {
+	// Kind is one of build, submit or update, matching the endpoint the webhook came in on.
+	Kind     string
+	Id       string
+	AppId    string
+	AppName  string
+	Platform expo.Platform
+	Status   expo.Status
+	// Channel is the Expo update channel the build was made for, or the branch an update was published to.
+	Channel string
+	// Branch is the branch an update was published to; it is empty for builds and submissions.
+	Branch  string
+	Version string
+	Commit  string
+	// Repository is the GitHub repository commits are in, as owner/name.
+	Repository string
+	// PreviousCommit is the commit of the previous build, submission or update, when we found one.
+	PreviousCommit string
+	// Title is a plain-text, one-line description of the event.
+	Title      string
+	DetailsURL string
+	// Error is set when the build or submission failed.
+	Error string
+}
+
+// Poster delivers a message built from Slack blocks, so handlers don't need to know how we're
+// connected to Slack. The timestamp of the posted message is returned when the destination exposes it.
+type Poster interface {
+	Post(ctx context.Context, msg Message) (string, error)
+}
+
+// Updater is implemented by Posters that can replace the content of a message they posted, given the
+// timestamp they returned for it.
+type Updater interface {
+	Update(ctx context.Context, ts string, msg Message) error
+}
+
+// MultiPoster delivers each message to several destinations, continuing past any that fail. Channels and
+// thread timestamps only make sense for the Slack workspace they came from, so only the first destination
+// routes and threads messages, and only its timestamp is returned.
+type MultiPoster []Poster
+
+// NewPoster combines the destinations into one Poster.
+func NewPoster(posters ...Poster) Poster {
+	if len(posters) == 1 {
+		return posters[0]
+	}
+	return MultiPoster(posters)
+}
+
+func (p MultiPoster) Post(ctx context.Context, msg Message) (string, error) {
+	var ts string
+	var errs []error
+	for i, poster := range p {
+		if i > 0 {
+			msg.ThreadTS = ""
+			msg.Channel = ""
+		}
+		posted, err := poster.Post(ctx, msg)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if i == 0 {
+			ts = posted
+		}
+	}
+	return ts, errors.Join(errs...)
+}
+
+// Update replaces the message with the timestamp in the first destination, which is the one timestamps
+// come from. The other destinations can't edit what they posted, so they are sent the message anew, unless
+// it's Unchanged: a retried submission is only sent again when its status changes, while the build of the
+// other platform folded into a message is always sent, as they haven't heard of it.
+func (p MultiPoster) Update(ctx context.Context, ts string, msg Message) error {
+	var errs []error
+	for i, poster := range p {
+		if i == 0 {
+			if updater, ok := poster.(Updater); ok {
+				if err := updater.Update(ctx, ts, msg); err != nil {
+					errs = append(errs, err)
+				}
+				continue
+			}
+		}
+		if msg.Unchanged {
+			continue
+		}
+		msg.ThreadTS = ""
+		msg.Channel = ""
+		if _, err := poster.Post(ctx, msg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ChannelPoster posts into a channel using a bot token.
+type ChannelPoster struct {
+	Client  *slack.Client
+	Channel string
+
+	// Username and IconEmoji, when set, replace the app's name and avatar on posted messages, which helps
+	// tell apart several webhooks posting to one channel. Slack requires the chat:write.customize scope.
+	Username  string
+	IconEmoji string
+}
+
+func (p *ChannelPoster) Post(ctx context.Context, msg Message) (string, error) {
+	options := []slack.MsgOption{slack.MsgOptionText(msg.Text, true), slack.MsgOptionBlocks(msg.Blocks...), slack.MsgOptionDisableLinkUnfurl(), slack.MsgOptionDisableMediaUnfurl()}
+	if msg.ThreadTS != "" {
+		options = append(options, slack.MsgOptionTS(msg.ThreadTS))
+	}
+	if p.Username != "" {
+		options = append(options, slack.MsgOptionUsername(p.Username))
+	}
+	if p.IconEmoji != "" {
+		options = append(options, slack.MsgOptionIconEmoji(p.IconEmoji))
+	}
+	channel := p.Channel
+	if msg.Channel != "" {
+		channel = msg.Channel
+	}
+	_, ts, err := p.Client.PostMessageContext(ctx, channel, options...)
+	return ts, err
+}
+
+func (p *ChannelPoster) Update(ctx context.Context, ts string, msg Message) error {
+	channel := p.Channel
+	if msg.Channel != "" {
+		channel = msg.Channel
+	}
+	_, _, _, err := p.Client.UpdateMessageContext(ctx, channel, ts, slack.MsgOptionText(msg.Text, true), slack.MsgOptionBlocks(msg.Blocks...))
+	return err
+}
+
+// WebhookPoster posts to a Slack incoming webhook, which is bound to a channel when it is created. Slack
+// doesn't tell us the timestamp of messages posted this way, so they can't be threaded under.
+type WebhookPoster struct {
+	URL string
+}
+
+func (p *WebhookPoster) Post(ctx context.Context, msg Message) (string, error) {
+	return "", slack.PostWebhookContext(ctx, p.URL, &slack.WebhookMessage{
+		Text:            slackutilsx.EscapeMessage(msg.Text),
+		Blocks:          &slack.Blocks{BlockSet: msg.Blocks},
+		ThreadTimestamp: msg.ThreadTS,
+	})
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"context"
+	"testing"
+)
+
+// fakePoster records what it's sent; fakeUpdater can edit what it posted, too.
+type fakePoster struct {
+	posted  []Message
+	updated []string
+}
+
+func (p *fakePoster) Post(_ context.Context, msg Message) (string, error) {
+	p.posted = append(p.posted, msg)
+	return "1.000000", nil
+}
+
+type fakeUpdater struct{ fakePoster }
+
+func (p *fakeUpdater) Update(_ context.Context, ts string, _ Message) error {
+	p.updated = append(p.updated, ts)
+	return nil
+}
+
+func TestMultiPosterUpdate(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		unchanged bool
+		resent    int
+	}{
+		{name: "changed", unchanged: false, resent: 1},
+		{name: "unchanged", unchanged: true, resent: 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			slack, teams := &fakeUpdater{}, &fakePoster{}
+			poster := NewPoster(slack, teams).(Updater)
+			msg := Message{Text: "iOS submission succeeded.", Channel: "C123", ThreadTS: "0.5", Unchanged: tc.unchanged}
+			if err := poster.Update(context.Background(), "1.000000", msg); err != nil {
+				t.Fatalf("failed to update: %v", err)
+			}
+			if len(slack.updated) != 1 || len(slack.posted) != 0 {
+				t.Errorf("expected the first destination to edit its message, got %d edits and %d posts", len(slack.updated), len(slack.posted))
+			}
+			if len(teams.posted) != tc.resent {
+				t.Fatalf("expected the other destination to be sent the message %d times, got %d", tc.resent, len(teams.posted))
+			}
+			if tc.resent > 0 && (teams.posted[0].Channel != "" || teams.posted[0].ThreadTS != "") {
+				t.Errorf("expected Slack's channel and thread not to be passed on, got %+v", teams.posted[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+)
+
+// DefaultPromotionBranch is the branch updates are promoted to unless another is configured.
+const DefaultPromotionBranch = "production"
+
+// Promotion lets people republish the update groups published on one branch to another, by pressing a
+// button on their messages in Slack.
+type Promotion struct {
+	From string
+	To   string
+	// Users are the Slack user IDs allowed to promote updates; nobody else can.
+	Users []string
+}
+
+// NewPromotion validates the promotion options, returning nil when promotion isn't configured.
+func NewPromotion(from, to string, users []string) (*Promotion, error) {
+	if from == "" {
+		return nil, nil
+	}
+	if to == "" {
+		to = DefaultPromotionBranch
+	}
+	if from == to {
+		return nil, fmt.Errorf("can't promote updates from %s to itself", from)
+	}
+	if len(users) == 0 {
+		return nil, fmt.Errorf("promoting updates requires a list of Slack users allowed to do so")
+	}
+	return &Promotion{From: from, To: to, Users: users}, nil
+}
+
+// Offered determines whether messages for updates on the branch get a button to promote them. It is safe
+// to call on a nil *Promotion.
+func (p *Promotion) Offered(branch string) bool {
+	return p != nil && p.From == branch
+}
+
+// Allowed determines whether the Slack user may promote updates.
+func (p *Promotion) Allowed(user string) bool {
+	return p != nil && slices.Contains(p.Users, user)
+}
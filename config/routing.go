@@ -0,0 +1,35 @@
+package config
+
+import "context"
+
+type channelKey struct{}
+
+// WithChannel overrides the Slack channel events handled under the context are posted to, as for the
+// channel query parameter on webhook URLs. An empty channel leaves routing as configured.
+func WithChannel(ctx context.Context, channel string) context.Context {
+	if channel == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, channelKey{}, channel)
+}
+
+// ChannelFor determines the Slack channel to post the event to: the channel set on the context with
+// WithChannel, the channel of the first routing rule it matches, the channel configured for its app or
+// its kind of event, or SlackChannel otherwise.
+func (c *Config) ChannelFor(ctx context.Context, e Event) string {
+	if channel, ok := ctx.Value(channelKey{}).(string); ok {
+		return channel
+	}
+	for _, rule := range c.Rules {
+		if rule.Matches(e) {
+			return rule.Channel
+		}
+	}
+	if app := c.Apps[e.AppId]; app.Channel != "" {
+		return app.Channel
+	}
+	if channel := c.EventChannels[e.Kind]; channel != "" {
+		return channel
+	}
+	return c.SlackChannel
+}
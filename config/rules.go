@@ -0,0 +1,121 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// Rule routes events matching all of its conditions to a Slack channel. Rules are written like
+//
+//	channel=production && status=errored -> #alerts
+//
+// where each condition compares a field of the event with = or !=. Values may use * and ? as wildcards and
+// are compared ignoring case. The fields are kind (build, submit or update), app (the Expo app ID), channel,
+// branch, platform and status.
+type Rule struct {
+	Conditions []Condition
+	Channel    string
+}
+
+// Condition is one comparison in a Rule.
+type Condition struct {
+	Field   string
+	Pattern string
+	Negated bool
+}
+
+// ruleFields are the fields rules can match on.
+var ruleFields = map[string]func(e Event) string{
+	"kind":     func(e Event) string { return e.Kind },
+	"app":      func(e Event) string { return e.AppId },
+	"channel":  func(e Event) string { return e.Channel },
+	"branch":   func(e Event) string { return e.Branch },
+	"platform": func(e Event) string { return string(e.Platform) },
+	"status":   func(e Event) string { return string(e.Status) },
+}
+
+// ParseRule parses a rule from its textual form; see Rule.
+func ParseRule(text string) (Rule, error) {
+	conditions, channel, ok := strings.Cut(text, "->")
+	if !ok {
+		return Rule{}, fmt.Errorf("invalid rule %q: missing -> channel", text)
+	}
+	rule := Rule{Channel: strings.TrimSpace(channel)}
+	if rule.Channel == "" {
+		return Rule{}, fmt.Errorf("invalid rule %q: missing channel after ->", text)
+	}
+	for _, part := range strings.Split(conditions, "&&") {
+		part = strings.TrimSpace(part)
+		var condition Condition
+		field, pattern, ok := strings.Cut(part, "!=")
+		if ok {
+			condition.Negated = true
+		} else if field, pattern, ok = strings.Cut(part, "="); !ok {
+			return Rule{}, fmt.Errorf("invalid rule %q: condition %q must be field=value or field!=value", text, part)
+		}
+		condition.Field = strings.ToLower(strings.TrimSpace(field))
+		condition.Pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if _, known := ruleFields[condition.Field]; !known {
+			return Rule{}, fmt.Errorf("invalid rule %q: unknown field %q", text, condition.Field)
+		}
+		if _, err := path.Match(condition.Pattern, ""); err != nil {
+			return Rule{}, fmt.Errorf("invalid rule %q: bad pattern %q: %v", text, condition.Pattern, err)
+		}
+		rule.Conditions = append(rule.Conditions, condition)
+	}
+	return rule, nil
+}
+
+// ParseRules parses each rule in turn.
+func ParseRules(texts []string) ([]Rule, error) {
+	var rules []Rule
+	for _, text := range texts {
+		rule, err := ParseRule(text)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// LoadRules reads rules from a file, one per line. Blank lines and lines starting with # are ignored.
+func LoadRules(file string) ([]Rule, error) {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %v", err)
+	}
+	var texts []string
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		texts = append(texts, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %v", err)
+	}
+	rules, err := ParseRules(texts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rules file %s: %v", file, err)
+	}
+	return rules, nil
+}
+
+// Matches determines whether the event meets all of the rule's conditions.
+func (r Rule) Matches(e Event) bool {
+	for _, condition := range r.Conditions {
+		// patterns were checked when parsing, so errors can't happen here
+		matched, _ := path.Match(condition.Pattern, strings.ToLower(ruleFields[condition.Field](e)))
+		if matched == condition.Negated {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,528 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+	"gopkg.in/yaml.v3"
+
+	"github.com/NWACus/expo-slack-webhook/expo"
+	"github.com/NWACus/expo-slack-webhook/github"
+	"github.com/NWACus/expo-slack-webhook/i18n"
+	"github.com/NWACus/expo-slack-webhook/install"
+	"github.com/NWACus/expo-slack-webhook/issues"
+	"github.com/NWACus/expo-slack-webhook/releases"
+	"github.com/NWACus/expo-slack-webhook/store"
+	"github.com/NWACus/expo-slack-webhook/templates"
+	"github.com/NWACus/expo-slack-webhook/threads"
+	"github.com/NWACus/expo-slack-webhook/topic"
+)
+
+// List holds settings that take several values. In the server's config file, they can be a list or a single
+// comma-separated string, like the flag and environment variable.
+type List []string
+
+func (l *List) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*l = SplitList(value.Value)
+		return nil
+	}
+	var items []string
+	if err := value.Decode(&items); err != nil {
+		return err
+	}
+	*l = items
+	return nil
+}
+
+// Settings are what a Config is made from, however they're given: the server reads them from flags, the
+// environment and its config file, and the serverless functions from the environment alone. They're named
+// here by the server's flags, which the environment variables are the upper-case versions of.
+type Settings struct {
+	ExpoHMACSecrets List   `yaml:"hmac-secret"`
+	ExpoToken       string `yaml:"expo-token"`
+	ExpoAppId       string `yaml:"expo-app-id"`
+	SlackToken      string `yaml:"slack-token"`
+	SlackChannel    string `yaml:"slack-channel"`
+	SlackWebhookURL string `yaml:"slack-webhook-url"`
+	SlackUsername   string `yaml:"slack-username"`
+	SlackIconEmoji  string `yaml:"slack-icon-emoji"`
+	TeamsWebhookURL string `yaml:"teams-webhook-url"`
+
+	// SlackBuildChannel, SlackSubmitChannel and SlackUpdateChannel override SlackChannel for each kind of
+	// event.
+	SlackBuildChannel  string `yaml:"slack-build-channel"`
+	SlackSubmitChannel string `yaml:"slack-submit-channel"`
+	SlackUpdateChannel string `yaml:"slack-update-channel"`
+
+	// RoutingRules are evaluated before the rules in RoutingRulesFile; see Rule for their syntax.
+	RoutingRules     List   `yaml:"routing-rules"`
+	RoutingRulesFile string `yaml:"routing-rules-file"`
+
+	// AppChannels and AppRepositories are appId=channel and appId=owner/name pairs; when either is set, only
+	// the apps listed are accepted.
+	AppChannels     List `yaml:"app-channels"`
+	AppRepositories List `yaml:"app-repositories"`
+	// DefaultRepository is the owner/name repository for apps with none configured or linked in Expo.
+	DefaultRepository string `yaml:"default-repository"`
+	// ChangelogCommits is how many of the commits since the previous build to list, read from GitHub with
+	// GitHubToken, if set.
+	ChangelogCommits int    `yaml:"changelog-commits"`
+	GitHubToken      string `yaml:"github-token"`
+	// GitHubCommitStatuses sets a status on the commit each build was made from, with GitHubToken.
+	GitHubCommitStatuses bool `yaml:"github-commit-statuses"`
+	// GitHubDeployments records each build as a deployment to the environment named for its channel, with
+	// GitHubToken.
+	GitHubDeployments bool `yaml:"github-deployments"`
+	// GitHubPreviewComments keeps a comment on the pull request each preview build was made from up to date,
+	// with GitHubToken.
+	GitHubPreviewComments bool `yaml:"github-preview-comments"`
+	// GitHubIssueAfterFailures is how many builds in a row must fail on a channel for a platform before an
+	// issue is opened about them, with GitHubToken.
+	GitHubIssueAfterFailures int `yaml:"github-issue-after-failures"`
+	// JiraURL and JiraProjects link the Jira issues commits in the changelog mention.
+	JiraURL      string `yaml:"jira-url"`
+	JiraProjects List   `yaml:"jira-projects"`
+	// LinearWorkspace and LinearTeams link the Linear issues commits in the changelog mention.
+	LinearWorkspace string `yaml:"linear-workspace"`
+	LinearTeams     List   `yaml:"linear-teams"`
+
+	// SlackWorkspaces are channel=token pairs for channels in other workspaces to post to as well.
+	SlackWorkspaces List `yaml:"slack-workspaces"`
+
+	MattermostURL     string `yaml:"mattermost-url"`
+	MattermostToken   string `yaml:"mattermost-token"`
+	MattermostChannel string `yaml:"mattermost-channel"`
+
+	TelegramBotToken string `yaml:"telegram-bot-token"`
+	TelegramChatID   string `yaml:"telegram-chat-id"`
+
+	SMTPAddr       string `yaml:"smtp-addr"`
+	SMTPUsername   string `yaml:"smtp-username"`
+	SMTPPassword   string `yaml:"smtp-password"`
+	SMTPFrom       string `yaml:"smtp-from"`
+	EmailTo        List   `yaml:"email-to"`
+	EmailToIOS     List   `yaml:"email-to-ios"`
+	EmailToAndroid List   `yaml:"email-to-android"`
+
+	PagerDutyRoutingKey string `yaml:"pagerduty-routing-key"`
+
+	OpsgenieAPIKey          string `yaml:"opsgenie-api-key"`
+	OpsgenieURL             string `yaml:"opsgenie-url"`
+	OpsgeniePriorities      List   `yaml:"opsgenie-priorities"`
+	OpsgenieDefaultPriority string `yaml:"opsgenie-default-priority"`
+
+	// StateStore is where state like the messages to thread under is kept; see store.Open.
+	StateStore     string `yaml:"state-store"`
+	ThreadByCommit bool   `yaml:"thread-by-commit"`
+	// SetTopic keeps the topic of the channel releases are posted to, or of TopicChannel, showing the latest
+	// production version.
+	SetTopic     bool   `yaml:"set-topic"`
+	TopicChannel string `yaml:"topic-channel"`
+	// MentionFailures mentions whoever started a failed build, found in Slack by their email address unless
+	// it's listed in SlackUsers as email=userId.
+	MentionFailures bool `yaml:"mention-failures"`
+	SlackUsers      List `yaml:"slack-users"`
+	// OnCallGroup is a Slack user group, by ID or @handle, to mention on failures on OnCallChannels.
+	OnCallGroup    string `yaml:"oncall-group"`
+	OnCallChannels List   `yaml:"oncall-channels"`
+	// EscalationRules decide how loudly events are announced, like routing rules with quiet, post or page
+	// in place of the channel; messages that page are copied to EscalationChannel.
+	EscalationRules   List   `yaml:"escalation-rules"`
+	EscalationChannel string `yaml:"escalation-channel"`
+	// PinReleases keeps a pinned message showing the latest releases in the channels they're posted to, or
+	// in ReleasesChannel.
+	PinReleases     bool   `yaml:"pin-releases"`
+	ReleasesChannel string `yaml:"releases-channel"`
+	// InstallQRCodes replies to the messages for internal distribution builds with a QR code to install them.
+	InstallQRCodes bool `yaml:"install-qr-codes"`
+
+	// SlackSigningSecret verifies requests from Slack, like the button to promote updates from PromoteFrom
+	// to PromoteTo, which only PromoteUsers may press.
+	SlackSigningSecret string `yaml:"slack-signing-secret"`
+	PromoteFrom        string `yaml:"promote-from"`
+	PromoteTo          string `yaml:"promote-to"`
+	PromoteUsers       List   `yaml:"promote-users"`
+	// RollbackUsers may press the button to roll back updates, which is only shown when some are listed.
+	RollbackUsers List `yaml:"rollback-users"`
+
+	NotifyPlatforms List `yaml:"notify-platforms"`
+	NotifyStatuses  List `yaml:"notify-statuses"`
+	// NotifyBuildStatuses and NotifySubmitStatuses replace NotifyStatuses for their endpoint.
+	NotifyBuildStatuses  List `yaml:"notify-build-statuses"`
+	NotifySubmitStatuses List `yaml:"notify-submit-statuses"`
+
+	UpdateChannelCacheTTL  time.Duration `yaml:"update-channel-cache-ttl"`
+	UpdateChannelCacheSize int           `yaml:"update-channel-cache-size"`
+	// UpdateChannelCacheShared also caches update channels in the state store.
+	UpdateChannelCacheShared bool `yaml:"update-channel-cache-shared"`
+	// SearchLimit caps how many builds, submissions or update groups are paged through to find the previous
+	// one.
+	SearchLimit int `yaml:"search-limit"`
+
+	MaxBodyBytes            int64         `yaml:"max-body-bytes"`
+	MaxPayloadAge           time.Duration `yaml:"max-payload-age"`
+	RequirePayloadTimestamp bool          `yaml:"require-payload-timestamp"`
+
+	// Emoji overrides platform and status emoji by name; see EmojiFromEnv.
+	Emoji map[string]string `yaml:"emoji"`
+	// MessageTemplates, MessageTemplatesFile and MessageTemplatesDir replace the default message templates;
+	// see the templates package.
+	MessageTemplates     string `yaml:"message-templates"`
+	MessageTemplatesFile string `yaml:"message-templates-file"`
+	MessageTemplatesDir  string `yaml:"message-templates-dir"`
+	// Locale and MessageCatalogs translate messages; see the i18n package.
+	Locale          string `yaml:"locale"`
+	MessageCatalogs string `yaml:"message-catalogs"`
+	// BuildLayout is how build messages lay out their details; see BuildLayoutFields.
+	BuildLayout string `yaml:"build-layout"`
+
+	LogFormat string `yaml:"log-format"`
+}
+
+func DefaultSettings() Settings {
+	return Settings{
+		UpdateChannelCacheTTL:  DefaultUpdateChannelCacheTTL,
+		UpdateChannelCacheSize: DefaultUpdateChannelCacheSize,
+		SearchLimit:            expo.DefaultSearchLimit,
+		MaxBodyBytes:           DefaultMaxBodyBytes,
+		OnCallChannels:         DefaultOnCallChannels,
+		BuildLayout:            BuildLayoutText,
+		LogFormat:              LogFormatJSON,
+	}
+}
+
+// hasOtherDestination determines whether somewhere other than Slack is configured, in which case Slack
+// is optional.
+func (s *Settings) hasOtherDestination() bool {
+	return len(s.SlackWorkspaces) > 0 || s.TeamsWebhookURL != "" || s.MattermostURL != "" || s.TelegramBotToken != ""
+}
+
+// rules parses the routing rules given inline and in the rules file.
+func (s *Settings) rules() ([]Rule, error) {
+	rules, err := ParseRules(s.RoutingRules)
+	if err != nil {
+		return nil, err
+	}
+	if s.RoutingRulesFile != "" {
+		fromFile, err := LoadRules(s.RoutingRulesFile)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, fromFile...)
+	}
+	return rules, nil
+}
+
+// Validate checks that the settings make sense together. Values that need parsing, like routing rules, are
+// checked by New as it parses them.
+func (s *Settings) Validate() error {
+	if s.SlackWebhookURL != "" {
+		if s.SlackToken != "" || s.SlackChannel != "" {
+			return fmt.Errorf("slack-webhook-url is mutually exclusive with slack-token and slack-channel")
+		}
+		if s.ThreadByCommit {
+			return fmt.Errorf("thread-by-commit requires slack-token, as incoming webhooks can't be threaded under")
+		}
+		if s.SlackUsername != "" || s.SlackIconEmoji != "" {
+			return fmt.Errorf("slack-username and slack-icon-emoji require slack-token, as incoming webhooks post as the app")
+		}
+	} else if s.SlackToken != "" || s.SlackChannel != "" || !s.hasOtherDestination() {
+		if s.SlackToken == "" {
+			return fmt.Errorf("slack-token is required")
+		}
+		if s.SlackChannel == "" {
+			return fmt.Errorf("slack-channel is required")
+		}
+	}
+	if (s.SlackBuildChannel != "" || s.SlackSubmitChannel != "" || s.SlackUpdateChannel != "") && s.SlackToken == "" {
+		return fmt.Errorf("slack-build-channel, slack-submit-channel and slack-update-channel require slack-token, as incoming webhooks are bound to one channel")
+	}
+	if (len(s.RoutingRules) > 0 || s.RoutingRulesFile != "") && s.SlackToken == "" {
+		return fmt.Errorf("routing rules require slack-token, as incoming webhooks are bound to one channel")
+	}
+	if len(s.AppChannels) > 0 && s.SlackToken == "" {
+		return fmt.Errorf("app-channels requires slack-token, as incoming webhooks are bound to one channel")
+	}
+	if s.DefaultRepository != "" && strings.Count(s.DefaultRepository, "/") != 1 {
+		return fmt.Errorf("invalid default-repository %q, expected owner/name", s.DefaultRepository)
+	}
+	if (s.MattermostURL != "" || s.MattermostToken != "" || s.MattermostChannel != "") && (s.MattermostURL == "" || s.MattermostToken == "" || s.MattermostChannel == "") {
+		return fmt.Errorf("mattermost-url, mattermost-token and mattermost-channel must be set together")
+	}
+	if (s.TelegramBotToken == "") != (s.TelegramChatID == "") {
+		return fmt.Errorf("telegram-bot-token and telegram-chat-id must be set together")
+	}
+	if s.SMTPAddr != "" && s.SMTPFrom == "" {
+		return fmt.Errorf("smtp-from is required with smtp-addr")
+	}
+	if s.SMTPAddr == "" && len(s.EmailTo)+len(s.EmailToIOS)+len(s.EmailToAndroid) > 0 {
+		return fmt.Errorf("email recipients require smtp-addr")
+	}
+	if s.ThreadByCommit && s.SlackToken == "" {
+		return fmt.Errorf("thread-by-commit requires slack-token, as only Slack messages can be threaded under")
+	}
+	if (s.SetTopic || s.TopicChannel != "") && s.SlackToken == "" {
+		return fmt.Errorf("set-topic and topic-channel require slack-token, as topics are set through the API")
+	}
+	if s.MentionFailures && s.SlackToken == "" {
+		return fmt.Errorf("mention-failures requires slack-token, to look up Slack users")
+	}
+	if s.OnCallGroup != "" && s.SlackToken == "" {
+		return fmt.Errorf("oncall-group requires slack-token, as only Slack can mention user groups")
+	}
+	if s.EscalationChannel != "" && s.SlackToken == "" {
+		return fmt.Errorf("escalation-channel requires slack-token, as incoming webhooks are bound to one channel")
+	}
+	if (s.PinReleases || s.ReleasesChannel != "") && s.SlackToken == "" {
+		return fmt.Errorf("pin-releases and releases-channel require slack-token, as the pinned message is edited")
+	}
+	if s.InstallQRCodes && s.SlackToken == "" {
+		return fmt.Errorf("install-qr-codes requires slack-token, as incoming webhooks can't upload files")
+	}
+	if len(s.ExpoHMACSecrets) == 0 {
+		return fmt.Errorf("hmac-secret is required")
+	}
+	if s.ExpoToken == "" {
+		return fmt.Errorf("expo-token is required")
+	}
+	if s.MaxBodyBytes < 1 {
+		return fmt.Errorf("max-body-bytes must be at least 1")
+	}
+	if s.SearchLimit < 1 {
+		return fmt.Errorf("search-limit must be at least 1")
+	}
+	if s.ChangelogCommits < 0 {
+		return fmt.Errorf("changelog-commits must be at least 0")
+	}
+	if s.GitHubCommitStatuses && s.GitHubToken == "" {
+		return fmt.Errorf("github-commit-statuses requires github-token, as statuses can't be set anonymously")
+	}
+	if s.GitHubDeployments && s.GitHubToken == "" {
+		return fmt.Errorf("github-deployments requires github-token, as deployments can't be created anonymously")
+	}
+	if s.GitHubPreviewComments && s.GitHubToken == "" {
+		return fmt.Errorf("github-preview-comments requires github-token, as pull requests can't be commented on anonymously")
+	}
+	if s.GitHubIssueAfterFailures < 0 {
+		return fmt.Errorf("github-issue-after-failures must be at least 0")
+	}
+	if s.GitHubIssueAfterFailures > 0 && s.GitHubToken == "" {
+		return fmt.Errorf("github-issue-after-failures requires github-token, as issues can't be opened anonymously")
+	}
+	if s.LogFormat != LogFormatText && s.LogFormat != LogFormatJSON {
+		return fmt.Errorf("log-format must be %s or %s", LogFormatText, LogFormatJSON)
+	}
+	return nil
+}
+
+// New makes the Config the settings describe, parsing and opening what they refer to, like the state store.
+// The settings should be validated first. Setting DEBUG enables debug-level logs.
+func New(s Settings) (*Config, error) {
+	_, debug := os.LookupEnv("DEBUG")
+	logger, err := NewLogger(os.Stderr, s.LogFormat, debug)
+	if err != nil {
+		return nil, err
+	}
+	emoji, err := expo.NewEmoji(s.Emoji)
+	if err != nil {
+		return nil, err
+	}
+	catalog, err := i18n.Load(s.MessageCatalogs, s.Locale)
+	if err != nil {
+		return nil, err
+	}
+	messageTemplates, err := templates.Load(emoji, catalog, s.MessageTemplatesFile, s.MessageTemplatesDir, s.MessageTemplates)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateBuildLayout(s.BuildLayout); err != nil {
+		return nil, err
+	}
+	cfg := &Config{
+		ExpoHMACSecrets: s.ExpoHMACSecrets,
+		ExpoClient: &expo.Client{
+			Token:              s.ExpoToken,
+			Logger:             logger,
+			UpdateChannelCache: expo.NewCache[*expo.UpdateChannel](s.UpdateChannelCacheTTL, s.UpdateChannelCacheSize),
+			AppCache:           expo.NewCache[*expo.App](DefaultAppCacheTTL, DefaultAppCacheSize),
+			SearchLimit:        s.SearchLimit,
+		},
+
+		MaxBodyBytes:            s.MaxBodyBytes,
+		MaxPayloadAge:           s.MaxPayloadAge,
+		RequirePayloadTimestamp: s.RequirePayloadTimestamp,
+
+		Emoji:       emoji,
+		Catalog:     catalog,
+		Templates:   messageTemplates,
+		BuildLayout: s.BuildLayout,
+		Logger:      logger,
+	}
+	cfg.Apps, err = ParseApps(s.AppChannels, s.AppRepositories)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Store, err = store.Open(s.StateStore)
+	if err != nil {
+		return nil, err
+	}
+	if s.UpdateChannelCacheShared {
+		cfg.ExpoClient.UpdateChannelCache.Shared(cfg.Store, UpdateChannelCachePrefix)
+	}
+	// Slack comes first, as it's the destination threads are tracked for
+	var posters []Poster
+	if s.SlackWebhookURL != "" {
+		posters = append(posters, &WebhookPoster{URL: s.SlackWebhookURL})
+	} else if s.SlackToken != "" {
+		cfg.SlackClient = slack.New(s.SlackToken)
+		cfg.SlackChannel = s.SlackChannel
+		cfg.EventChannels = map[string]string{
+			"build":  s.SlackBuildChannel,
+			"submit": s.SlackSubmitChannel,
+			"update": s.SlackUpdateChannel,
+		}
+		cfg.Rules, err = s.rules()
+		if err != nil {
+			return nil, err
+		}
+		posters = append(posters, &ChannelPoster{
+			Client:    cfg.SlackClient,
+			Channel:   cfg.SlackChannel,
+			Username:  s.SlackUsername,
+			IconEmoji: s.SlackIconEmoji,
+		})
+	}
+	workspaces, err := ParseSlackWorkspaces(s.SlackWorkspaces)
+	if err != nil {
+		return nil, err
+	}
+	for _, workspace := range workspaces {
+		posters = append(posters, &ChannelPoster{
+			Client:    slack.New(workspace.Token),
+			Channel:   workspace.Channel,
+			Username:  s.SlackUsername,
+			IconEmoji: s.SlackIconEmoji,
+		})
+	}
+	if s.TeamsWebhookURL != "" {
+		posters = append(posters, &TeamsPoster{URL: s.TeamsWebhookURL})
+	}
+	if s.MattermostURL != "" {
+		posters = append(posters, &MattermostPoster{URL: s.MattermostURL, Token: s.MattermostToken, ChannelID: s.MattermostChannel})
+	}
+	if s.TelegramBotToken != "" {
+		posters = append(posters, &TelegramPoster{Token: s.TelegramBotToken, ChatID: s.TelegramChatID})
+	}
+	if s.SMTPAddr != "" {
+		posters = append(posters, &EmailPoster{
+			Addr:     s.SMTPAddr,
+			Username: s.SMTPUsername,
+			Password: s.SMTPPassword,
+			From:     s.SMTPFrom,
+			To:       s.EmailTo,
+			PlatformTo: map[expo.Platform][]string{
+				expo.PlatformIOS:     s.EmailToIOS,
+				expo.PlatformAndroid: s.EmailToAndroid,
+			},
+		})
+	}
+	if s.PagerDutyRoutingKey != "" {
+		posters = append(posters, &PagerDutyPoster{RoutingKey: s.PagerDutyRoutingKey, Store: cfg.Store})
+	}
+	if s.OpsgenieAPIKey != "" {
+		priorities := DefaultOpsgeniePriorities
+		if len(s.OpsgeniePriorities) > 0 {
+			priorities, err = ParseOpsgeniePriorities(s.OpsgeniePriorities)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if s.OpsgenieDefaultPriority != "" {
+			if err := ValidateOpsgeniePriority(s.OpsgenieDefaultPriority); err != nil {
+				return nil, err
+			}
+		}
+		posters = append(posters, &OpsgeniePoster{
+			APIKey:          s.OpsgenieAPIKey,
+			URL:             s.OpsgenieURL,
+			Priorities:      priorities,
+			DefaultPriority: s.OpsgenieDefaultPriority,
+		})
+	}
+	cfg.Poster = NewPoster(posters...)
+	for _, platform := range s.NotifyPlatforms {
+		cfg.NotifyPlatforms = append(cfg.NotifyPlatforms, expo.Platform(platform))
+	}
+	for _, status := range s.NotifyStatuses {
+		cfg.NotifyStatuses = append(cfg.NotifyStatuses, expo.Status(status))
+	}
+	cfg.EventStatuses = map[string][]expo.Status{}
+	for _, status := range s.NotifyBuildStatuses {
+		cfg.EventStatuses["build"] = append(cfg.EventStatuses["build"], expo.Status(status))
+	}
+	for _, status := range s.NotifySubmitStatuses {
+		cfg.EventStatuses["submit"] = append(cfg.EventStatuses["submit"], expo.Status(status))
+	}
+	if s.ThreadByCommit {
+		cfg.Threads = threads.NewStore(cfg.Store, DefaultThreadTTL)
+	}
+	if s.SetTopic || s.TopicChannel != "" {
+		cfg.Topic = topic.NewSetter(cfg.SlackClient, s.TopicChannel, cfg.Store)
+	}
+	if s.MentionFailures {
+		users, err := ParseSlackUsers(s.SlackUsers)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Mentions = &Mentions{Client: cfg.SlackClient, Users: users}
+	}
+	if s.OnCallGroup != "" {
+		cfg.OnCall = &OnCall{Client: cfg.SlackClient, Group: s.OnCallGroup, Channels: s.OnCallChannels}
+	}
+	cfg.EscalationRules, err = ParseEscalationRules(s.EscalationRules)
+	if err != nil {
+		return nil, err
+	}
+	cfg.EscalationChannel = s.EscalationChannel
+	if s.PinReleases || s.ReleasesChannel != "" {
+		cfg.Releases = releases.NewState(cfg.SlackClient, s.ReleasesChannel, cfg.Store)
+	}
+	if s.InstallQRCodes {
+		cfg.InstallQRCodes = install.NewQRCodes(cfg.SlackClient)
+	}
+	cfg.SlackSigningSecret = s.SlackSigningSecret
+	cfg.Promotion, err = NewPromotion(s.PromoteFrom, s.PromoteTo, s.PromoteUsers)
+	if err != nil {
+		return nil, err
+	}
+	cfg.RollbackUsers = s.RollbackUsers
+	cfg.ExpoAppId = s.ExpoAppId
+	cfg.DefaultRepository = s.DefaultRepository
+	cfg.ChangelogCommits = s.ChangelogCommits
+	cfg.CommitStatuses = s.GitHubCommitStatuses
+	cfg.Deployments = s.GitHubDeployments
+	cfg.PreviewComments = s.GitHubPreviewComments
+	cfg.FailureIssueThreshold = s.GitHubIssueAfterFailures
+	if s.ChangelogCommits > 0 || s.GitHubCommitStatuses || s.GitHubDeployments || s.GitHubPreviewComments || s.GitHubIssueAfterFailures > 0 {
+		cfg.GitHub = &github.Client{Token: s.GitHubToken, Logger: logger}
+	}
+	if s.JiraURL != "" {
+		jira, err := issues.NewJira(s.JiraURL, s.JiraProjects)
+		if err != nil {
+			return nil, err
+		}
+		cfg.IssueTrackers = append(cfg.IssueTrackers, jira)
+	}
+	if s.LinearWorkspace != "" {
+		linear, err := issues.NewLinear(s.LinearWorkspace, s.LinearTeams)
+		if err != nil {
+			return nil, err
+		}
+		cfg.IssueTrackers = append(cfg.IssueTrackers, linear)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/NWACus/expo-slack-webhook/expo"
+)
+
+// TeamsPoster posts an Adaptive Card to a Microsoft Teams incoming webhook or workflow. Teams can't render
+// Slack blocks, so the card is built from the message's Event.
+type TeamsPoster struct {
+	URL    string
+	Client *http.Client
+}
+
+type teamsMessage struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+type teamsAttachment struct {
+	ContentType string       `json:"contentType"`
+	Content     adaptiveCard `json:"content"`
+}
+
+type adaptiveCard struct {
+	Schema  string       `json:"$schema"`
+	Type    string       `json:"type"`
+	Version string       `json:"version"`
+	Body    []any        `json:"body"`
+	Actions []cardAction `json:"actions,omitempty"`
+}
+
+type cardText struct {
+	Type   string `json:"type"`
+	Text   string `json:"text"`
+	Wrap   bool   `json:"wrap"`
+	Weight string `json:"weight,omitempty"`
+	Size   string `json:"size,omitempty"`
+	Color  string `json:"color,omitempty"`
+}
+
+type cardFactSet struct {
+	Type  string     `json:"type"`
+	Facts []cardFact `json:"facts"`
+}
+
+type cardFact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+type cardAction struct {
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+func (p *TeamsPoster) Post(ctx context.Context, msg Message) (string, error) {
+	return "", postJSON(ctx, p.Client, p.URL, teamsMessage{
+		Type: "message",
+		Attachments: []teamsAttachment{{
+			ContentType: "application/vnd.microsoft.card.adaptive",
+			Content:     teamsCard(msg.Event),
+		}},
+	}, nil)
+}
+
+func teamsCard(e Event) adaptiveCard {
+	card := adaptiveCard{
+		Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+		Type:    "AdaptiveCard",
+		Version: "1.4",
+		Body: []any{
+			cardText{Type: "TextBlock", Text: expo.StatusSymbol(e.Status) + " " + e.Title, Wrap: true, Weight: "Bolder", Size: "Medium"},
+		},
+	}
+
+	var facts []cardFact
+	for _, fact := range []cardFact{
+		{Title: "Platform", Value: platformFact(e.Platform)},
+		{Title: "Version", Value: e.Version},
+		{Title: "Channel", Value: e.Channel},
+		{Title: "Commit", Value: markdownCommit(e.Repository, e.Commit)},
+		{Title: "Previous commit", Value: markdownCommit(e.Repository, e.PreviousCommit)},
+	} {
+		if fact.Value != "" {
+			facts = append(facts, fact)
+		}
+	}
+	if len(facts) > 0 {
+		card.Body = append(card.Body, cardFactSet{Type: "FactSet", Facts: facts})
+	}
+	if e.Error != "" {
+		card.Body = append(card.Body, cardText{Type: "TextBlock", Text: "Error " + e.Error, Wrap: true, Color: "Attention"})
+	}
+
+	if e.DetailsURL != "" {
+		card.Actions = append(card.Actions, cardAction{Type: "Action.OpenUrl", Title: "View on Expo", URL: e.DetailsURL})
+	}
+	if e.Commit != "" && e.PreviousCommit != "" {
+		card.Actions = append(card.Actions, cardAction{Type: "Action.OpenUrl", Title: "Changelog", URL: expo.CompareURL(e.Repository, e.PreviousCommit, e.Commit)})
+	}
+	return card
+}
+
+// platformFact names the platform, if the event is for just one.
+func platformFact(platform expo.Platform) string {
+	if platform == "" {
+		return ""
+	}
+	return expo.PlatformDisplay(nil, platform)
+}
+
+func markdownCommit(repository, commit string) string {
+	if commit == "" {
+		return ""
+	}
+	return fmt.Sprintf("[%s](%s)", expo.ShortCommit(commit), expo.CommitURL(repository, commit))
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/NWACus/expo-slack-webhook/expo"
+)
+
+func TestTeamsPoster(t *testing.T) {
+	api := newRecorder(t)
+	poster := &TeamsPoster{URL: api.URL + "/webhook"}
+	if _, err := poster.Post(context.Background(), Message{Event: failedBuild}); err != nil {
+		t.Fatalf("failed to post: %v", err)
+	}
+
+	requests := api.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("expected one request, got %d", len(requests))
+	}
+	if got, expected := requests[0].Path, "/webhook"; got != expected {
+		t.Errorf("expected the card to be posted to %s, got %s", expected, got)
+	}
+	var posted teamsMessage
+	if err := json.Unmarshal(requests[0].Body, &posted); err != nil {
+		t.Fatalf("failed to unmarshal card: %v", err)
+	}
+	if posted.Type != "message" || len(posted.Attachments) != 1 {
+		t.Fatalf("expected a message with one attachment, got %s", requests[0].Body)
+	}
+	if got, expected := posted.Attachments[0].ContentType, "application/vnd.microsoft.card.adaptive"; got != expected {
+		t.Errorf("expected content type %q, got %q", expected, got)
+	}
+
+	card := decode(t, requests[0].Body)["attachments"].([]any)[0].(map[string]any)["content"].(map[string]any)
+	body, _ := card["body"].([]any)
+	if len(body) != 3 {
+		t.Fatalf("expected a title, facts and the error in the card, got %v", body)
+	}
+	if title := body[0].(map[string]any); title["text"] != "🔴 "+failedBuild.Title || title["weight"] != "Bolder" {
+		t.Errorf("expected the title with the status, got %v", title)
+	}
+	facts := map[string]any{}
+	for _, fact := range body[1].(map[string]any)["facts"].([]any) {
+		facts[fact.(map[string]any)["title"].(string)] = fact.(map[string]any)["value"]
+	}
+	for title, expected := range map[string]string{
+		"Platform":        "Android",
+		"Version":         "1.0.0 (41)",
+		"Channel":         "production",
+		"Commit":          "[499a175](https://github.com/NWACus/avy/commit/499a175e6eedad4c3a68be1e8d4fbc072c99aefd)",
+		"Previous commit": "[8349b79](https://github.com/NWACus/avy/commit/8349b793e0c824f32d4619d7955f0f6b6ce29896)",
+	} {
+		if facts[title] != expected {
+			t.Errorf("expected fact %s to be %q, got %v", title, expected, facts[title])
+		}
+	}
+	if errored := body[2].(map[string]any); errored["text"] != "Error Gradle build failed" || errored["color"] != "Attention" {
+		t.Errorf("expected the error to be highlighted, got %v", errored)
+	}
+	var urls []string
+	for _, action := range card["actions"].([]any) {
+		urls = append(urls, action.(map[string]any)["url"].(string))
+	}
+	expected := []string{
+		failedBuild.DetailsURL,
+		"https://github.com/NWACus/avy/compare/8349b793e0c824f32d4619d7955f0f6b6ce29896...499a175e6eedad4c3a68be1e8d4fbc072c99aefd",
+	}
+	if len(urls) != len(expected) || urls[0] != expected[0] || urls[1] != expected[1] {
+		t.Errorf("expected actions linking %v, got %v", expected, urls)
+	}
+}
+
+func TestTeamsPosterOmitsMissingFacts(t *testing.T) {
+	api := newRecorder(t)
+	poster := &TeamsPoster{URL: api.URL}
+	// an update for both platforms, without a commit or a previous one
+	e := Event{Kind: "update", Status: expo.StatusFinished, Channel: "preview", Title: "Update to preview published."}
+	if _, err := poster.Post(context.Background(), Message{Event: e}); err != nil {
+		t.Fatalf("failed to post: %v", err)
+	}
+	card := decode(t, api.Requests()[0].Body)["attachments"].([]any)[0].(map[string]any)["content"].(map[string]any)
+	body, _ := card["body"].([]any)
+	if len(body) != 2 {
+		t.Fatalf("expected a title and facts, got %v", body)
+	}
+	if facts := body[1].(map[string]any)["facts"].([]any); len(facts) != 1 {
+		t.Errorf("expected only the channel, got %v", facts)
+	}
+	if _, ok := card["actions"]; ok {
+		t.Errorf("expected no actions, got %v", card["actions"])
+	}
+}
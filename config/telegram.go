@@ -0,0 +1,92 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/NWACus/expo-slack-webhook/expo"
+)
+
+// DefaultTelegramURL is the Telegram Bot API.
+const DefaultTelegramURL = "https://api.telegram.org"
+
+// TelegramPoster sends messages to a Telegram chat through a bot, formatted with MarkdownV2.
+type TelegramPoster struct {
+	Token string
+	// ChatID is the numeric ID of the chat or an @channelusername.
+	ChatID string
+	// URL is the base URL of the Bot API, DefaultTelegramURL if empty.
+	URL    string
+	Client *http.Client
+}
+
+type telegramMessage struct {
+	ChatID                string `json:"chat_id"`
+	Text                  string `json:"text"`
+	ParseMode             string `json:"parse_mode"`
+	DisableWebPagePreview bool   `json:"disable_web_page_preview"`
+}
+
+func (p *TelegramPoster) Post(ctx context.Context, msg Message) (string, error) {
+	baseURL := p.URL
+	if baseURL == "" {
+		baseURL = DefaultTelegramURL
+	}
+	return "", postJSON(ctx, p.Client, strings.TrimSuffix(baseURL, "/")+"/bot"+p.Token+"/sendMessage", telegramMessage{
+		ChatID:                p.ChatID,
+		Text:                  telegramText(msg.Event),
+		ParseMode:             "MarkdownV2",
+		DisableWebPagePreview: true,
+	}, nil)
+}
+
+func telegramText(e Event) string {
+	lines := []string{fmt.Sprintf("*%s*", telegramEscape(expo.StatusSymbol(e.Status)+" "+e.Title))}
+	var details []string
+	if e.Channel != "" {
+		details = append(details, "Channel `"+telegramEscapeCode(e.Channel)+"`")
+	}
+	if e.Commit != "" {
+		details = append(details, "Commit "+telegramLink(expo.ShortCommit(e.Commit), expo.CommitURL(e.Repository, e.Commit)))
+	}
+	if len(details) > 0 {
+		lines = append(lines, strings.Join(details, " · "))
+	}
+	if e.Commit != "" && e.PreviousCommit != "" {
+		lines = append(lines, fmt.Sprintf("See the %s since %s%s",
+			telegramLink("changelog", expo.CompareURL(e.Repository, e.PreviousCommit, e.Commit)),
+			telegramLink(expo.ShortCommit(e.PreviousCommit), expo.CommitURL(e.Repository, e.PreviousCommit)),
+			telegramEscape("."),
+		))
+	}
+	if e.Error != "" {
+		lines = append(lines, telegramEscape("Error "+e.Error))
+	}
+	if e.DetailsURL != "" {
+		lines = append(lines, "See details "+telegramLink("here", e.DetailsURL)+telegramEscape("."))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// telegramEscaper escapes the characters MarkdownV2 reserves in ordinary text.
+var telegramEscaper = strings.NewReplacer(
+	`\`, `\\`, `_`, `\_`, `*`, `\*`, `[`, `\[`, `]`, `\]`, `(`, `\(`, `)`, `\)`, `~`, `\~`, "`", "\\`",
+	`>`, `\>`, `#`, `\#`, `+`, `\+`, `-`, `\-`, `=`, `\=`, `|`, `\|`, `{`, `\{`, `}`, `\}`, `.`, `\.`, `!`, `\!`,
+)
+
+func telegramEscape(text string) string {
+	return telegramEscaper.Replace(text)
+}
+
+// telegramEscapeCode escapes text inside a code span, where only backticks and backslashes are special.
+func telegramEscapeCode(text string) string {
+	return strings.NewReplacer(`\`, `\\`, "`", "\\`").Replace(text)
+}
+
+// telegramLink formats a MarkdownV2 link, where only closing parentheses and backslashes in the URL need
+// to be escaped.
+func telegramLink(text, url string) string {
+	return "[" + telegramEscape(text) + "](" + strings.NewReplacer(`\`, `\\`, `)`, `\)`).Replace(url) + ")"
+}
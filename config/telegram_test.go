@@ -0,0 +1,46 @@
+package config
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTelegramPoster(t *testing.T) {
+	api := newRecorder(t)
+	poster := &TelegramPoster{Token: "123:abc", ChatID: "@releases", URL: api.URL}
+	e := failedBuild
+	// the error comes from the build's logs, so it can hold anything MarkdownV2 reserves
+	e.Error = "Task :app:bundleRelease failed (exit code 1)."
+	if _, err := poster.Post(context.Background(), Message{Event: e}); err != nil {
+		t.Fatalf("failed to post: %v", err)
+	}
+
+	requests := api.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("expected one request, got %d", len(requests))
+	}
+	if got, expected := requests[0].Path, "/bot123:abc/sendMessage"; got != expected {
+		t.Errorf("expected a request to %s, got %s", expected, got)
+	}
+	message := decode(t, requests[0].Body)
+	for field, expected := range map[string]any{
+		"chat_id":                  "@releases",
+		"parse_mode":               "MarkdownV2",
+		"disable_web_page_preview": true,
+		"text": "*🔴 Android build of Avy 1\\.0\\.0 \\(41\\) failed\\.*\n" +
+			"Channel `production` · Commit [499a175](https://github.com/NWACus/avy/commit/499a175e6eedad4c3a68be1e8d4fbc072c99aefd)\n" +
+			"See the [changelog](https://github.com/NWACus/avy/compare/8349b793e0c824f32d4619d7955f0f6b6ce29896...499a175e6eedad4c3a68be1e8d4fbc072c99aefd) since [8349b79](https://github.com/NWACus/avy/commit/8349b793e0c824f32d4619d7955f0f6b6ce29896)\\.\n" +
+			"Error Task :app:bundleRelease failed \\(exit code 1\\)\\.\n" +
+			"See details [here](https://expo.dev/builds/35425398-97b0-4f02-ac41-beb723090aa2)\\.",
+	} {
+		if message[field] != expected {
+			t.Errorf("expected %s to be %q, got %q", field, expected, message[field])
+		}
+	}
+}
+
+func TestTelegramLink(t *testing.T) {
+	if got, expected := telegramLink("run_1", `https://example.com/a_(b)\c`), `[run\_1](https://example.com/a_(b\)\\c)`; got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
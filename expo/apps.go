@@ -0,0 +1,52 @@
+package expo
+
+import (
+	"context"
+	"fmt"
+)
+
+type appVariables struct {
+	AppId string `json:"appId"`
+}
+
+const appOperation = "AppByIdQuery"
+const appQuery = "query AppByIdQuery($appId: String!) {\n  app {\n    byId(appId: $appId) {\n      id\n      name\n      slug\n      fullName\n      iconUrl\n      ownerAccount {\n        id\n        name\n        __typename\n      }\n      githubRepository {\n        githubRepositoryUrl\n        __typename\n      }\n      __typename\n    }\n    __typename\n  }\n}"
+
+type appResponse struct {
+	Data struct {
+		App struct {
+			ById App `json:"byId"`
+		} `json:"app"`
+	} `json:"data"`
+}
+
+// FetchApp looks up the app, from the client's AppCache if it was fetched recently.
+func (c *Client) FetchApp(ctx context.Context, projectId string) (*App, error) {
+	logger := c.logger().With("app_id", projectId)
+	cached, ok, err := c.AppCache.Get(ctx, projectId)
+	if err != nil {
+		logger.Warn("failed to read app cache", "error", err)
+	}
+	if ok {
+		logger.Info("using cached app", "app_name", cached.Name)
+		return cached, nil
+	}
+	logger.Info("fetching app")
+	query := graphQLQuery[appVariables]{
+		OperationName: appOperation,
+		Query:         appQuery,
+		Variables: appVariables{
+			AppId: projectId,
+		},
+	}
+
+	var parsed appResponse
+	if err := execute(ctx, c, query, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to fetch app: %w", err)
+	}
+	logger.Info("fetched app", "app_name", parsed.Data.App.ById.Name)
+	if err := c.AppCache.Set(ctx, projectId, &parsed.Data.App.ById); err != nil {
+		logger.Warn("failed to cache app", "error", err)
+	}
+	return &parsed.Data.App.ById, nil
+}
@@ -0,0 +1,68 @@
+package expo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// artifactOperation labels artifact size lookups in the Expo request metrics.
+const artifactOperation = "BuildArtifactSize"
+
+// FetchArtifactSize finds the size of a build's artifact, in bytes, from the headers for its URL, without
+// downloading it.
+func (c *Client) FetchArtifactSize(ctx context.Context, url string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	start := time.Now()
+	resp, err := c.httpClient().Do(req)
+	c.Metrics.ObserveExpoRequest(artifactOperation, time.Since(start))
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch artifact: %w", err)
+	}
+	if err := resp.Body.Close(); err != nil {
+		c.logger().Error("failed to close response body", "error", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("failed to fetch artifact: %d", resp.StatusCode)
+	}
+	if resp.ContentLength < 0 {
+		return 0, fmt.Errorf("artifact size unknown")
+	}
+	return resp.ContentLength, nil
+}
+
+// sizeRegression is how much bigger than the previous build's an artifact can get before we flag it.
+const sizeRegression = 0.1
+
+// SizeChange compares the size of a build's app with that of the build before it, in bytes.
+type SizeChange struct {
+	// Kind is the kind of app, like IPA, APK or AAB, from its URL.
+	Kind               string
+	Size, PreviousSize int64
+}
+
+// NewSizeChange compares the sizes of a build's app, at the URL, and the previous build's.
+func NewSizeChange(url string, size, previousSize int64) *SizeChange {
+	kind := strings.ToUpper(strings.TrimPrefix(path.Ext(strings.SplitN(url, "?", 2)[0]), "."))
+	if kind == "" {
+		kind = "App"
+	}
+	return &SizeChange{Kind: kind, Size: size, PreviousSize: previousSize}
+}
+
+// Delta is how many bytes bigger the app got, which is negative if it shrank.
+func (s SizeChange) Delta() int64 {
+	return s.Size - s.PreviousSize
+}
+
+// Regression reports whether the app grew by enough that someone should look into why.
+func (s SizeChange) Regression() bool {
+	return s.PreviousSize > 0 && float64(s.Delta()) > float64(s.PreviousSize)*sizeRegression
+}
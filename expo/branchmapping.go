@@ -0,0 +1,68 @@
+package expo
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// branchMapping is how a channel decides which of its branches a client gets updates from. Rules are tried in
+// order, and the first whose logic matches the client picks the branch.
+type branchMapping struct {
+	Version int                 `json:"version"`
+	Data    []branchMappingRule `json:"data"`
+}
+
+type branchMappingRule struct {
+	BranchId string `json:"branchId"`
+	// BranchMappingLogic is the string "true" for a rule matching every client, or a statement like the
+	// hash_lt comparison of a rollout.
+	BranchMappingLogic json.RawMessage `json:"branchMappingLogic"`
+}
+
+type branchMappingStatement struct {
+	ClientKey string  `json:"clientKey"`
+	Operator  string  `json:"branchMappingOperator"`
+	Operand   float64 `json:"operand"`
+}
+
+// BranchesByAudience lists the IDs of the branches the channel maps to, those serving the most clients first.
+// During a rollout, most clients stay on the branch being rolled out from until the rollout passes half of
+// them. Channels without a mapping list no branches. Rules we can't estimate the audience of, like those comparing runtime versions, count as serving
+// nobody, so their branches come last.
+func (c *UpdateChannel) BranchesByAudience() ([]string, error) {
+	if c.BranchMapping == "" {
+		return nil, nil
+	}
+	var mapping branchMapping
+	if err := json.Unmarshal([]byte(c.BranchMapping), &mapping); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal branch mapping: %v", err)
+	}
+	type audience struct {
+		branchId string
+		share    float64
+	}
+	var audiences []audience
+	remaining := 1.0
+	for _, rule := range mapping.Data {
+		share := 0.0
+		var always string
+		var statement branchMappingStatement
+		switch {
+		case json.Unmarshal(rule.BranchMappingLogic, &always) == nil && always == "true":
+			share = remaining
+		case json.Unmarshal(rule.BranchMappingLogic, &statement) == nil && statement.Operator == "hash_lt":
+			share = min(statement.Operand, remaining)
+		}
+		remaining -= share
+		audiences = append(audiences, audience{branchId: rule.BranchId, share: share})
+	}
+	sort.SliceStable(audiences, func(i, j int) bool {
+		return audiences[i].share > audiences[j].share
+	})
+	ids := make([]string, len(audiences))
+	for i, a := range audiences {
+		ids[i] = a.branchId
+	}
+	return ids, nil
+}
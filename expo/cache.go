@@ -0,0 +1,135 @@
+package expo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/NWACus/expo-slack-webhook/store"
+)
+
+// Cache holds a bounded number of values for a limited time. It is safe for concurrent use, and a nil
+// *Cache is valid and holds nothing.
+type Cache[V any] struct {
+	ttl  time.Duration
+	size int
+
+	// shared, when set, also holds values as JSON under keys with the prefix, so that processes sharing a
+	// store, like serverless invocations, share the cache.
+	shared store.Store
+	prefix string
+
+	lock    sync.Mutex
+	entries map[string]cacheEntry[V]
+}
+
+type cacheEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+// NewCache creates a cache holding up to size values, each for the TTL. A non-positive TTL or size
+// disables caching.
+func NewCache[V any](ttl time.Duration, size int) *Cache[V] {
+	if ttl <= 0 || size <= 0 {
+		return nil
+	}
+	return &Cache[V]{ttl: ttl, size: size, entries: map[string]cacheEntry[V]{}}
+}
+
+// Shared also keeps the cache's values in the store, under keys with the prefix, for processes that don't
+// last long enough to benefit from a cache of their own. It returns the cache for chaining.
+func (c *Cache[V]) Shared(s store.Store, prefix string) *Cache[V] {
+	if c != nil {
+		c.shared, c.prefix = s, prefix
+	}
+	return c
+}
+
+// Get returns the value for the key, looking in the shared store when the process hasn't cached it. An error
+// is only returned for a failure to read the shared store, which is otherwise a miss.
+func (c *Cache[V]) Get(ctx context.Context, key string) (V, bool, error) {
+	var zero V
+	if c == nil {
+		return zero, false, nil
+	}
+	if value, ok := c.get(key); ok {
+		return value, true, nil
+	}
+	if c.shared == nil {
+		return zero, false, nil
+	}
+	raw, ok, err := c.shared.Get(ctx, c.prefix+key)
+	if err != nil || !ok {
+		return zero, false, err
+	}
+	var value V
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		return zero, false, fmt.Errorf("failed to unmarshal cached %s: %v", key, err)
+	}
+	c.set(key, value)
+	return value, true, nil
+}
+
+// Set caches the value for the key, in the shared store too if there is one. An error is only returned for
+// a failure to write to the shared store.
+func (c *Cache[V]) Set(ctx context.Context, key string, value V) error {
+	if c == nil {
+		return nil
+	}
+	c.set(key, value)
+	if c.shared == nil {
+		return nil
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %v", key, err)
+	}
+	return c.shared.Set(ctx, c.prefix+key, string(raw), c.ttl)
+}
+
+func (c *Cache[V]) get(key string) (V, bool) {
+	var zero V
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return zero, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return zero, false
+	}
+	return entry.value, true
+}
+
+func (c *Cache[V]) set(key string, value V) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := time.Now()
+	if _, exists := c.entries[key]; !exists && len(c.entries) >= c.size {
+		c.evict(now)
+	}
+	c.entries[key] = cacheEntry[V]{value: value, expires: now.Add(c.ttl)}
+}
+
+// evict makes room for a new entry by dropping everything that has expired or, failing that, the entry
+// closest to expiring. Callers must hold the lock.
+func (c *Cache[V]) evict(now time.Time) {
+	var oldest string
+	var oldestExpires time.Time
+	for key, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, key)
+			continue
+		}
+		if oldest == "" || entry.expires.Before(oldestExpires) {
+			oldest, oldestExpires = key, entry.expires
+		}
+	}
+	if len(c.entries) >= c.size {
+		delete(c.entries, oldest)
+	}
+}
@@ -0,0 +1,124 @@
+package expo_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NWACus/expo-slack-webhook/expo"
+	"github.com/NWACus/expo-slack-webhook/expo/expotest"
+	"github.com/NWACus/expo-slack-webhook/store"
+)
+
+const appId = "47e2fd36-5165-4eb4-9a2d-21beec393379"
+
+func TestFetchAppUsesCache(t *testing.T) {
+	server := expotest.NewServer()
+	defer server.Close()
+	client := server.Client()
+	client.AppCache = expo.NewCache[*expo.App](time.Minute, 10)
+
+	for range 2 {
+		app, err := client.FetchApp(context.Background(), appId)
+		if err != nil {
+			t.Fatalf("failed to fetch app: %v", err)
+		}
+		if app.Name != "avalanche-forecast" {
+			t.Errorf("expected avalanche-forecast, got %q", app.Name)
+		}
+	}
+	if got := len(server.Operations()); got != 1 {
+		t.Errorf("expected the second lookup to be cached, got %d requests: %v", got, server.Operations())
+	}
+}
+
+func TestFetchUpdateChannelSharesCache(t *testing.T) {
+	server := expotest.NewServer()
+	defer server.Close()
+	shared := store.NewMemory()
+	ctx := context.Background()
+
+	// each client stands in for a serverless invocation, with a cache of its own
+	for range 2 {
+		client := server.Client()
+		client.UpdateChannelCache = expo.NewCache[*expo.UpdateChannel](time.Minute, 10).Shared(shared, "update-channel/")
+		channel, err := client.FetchUpdateChannel(ctx, appId, "preview")
+		if err != nil {
+			t.Fatalf("failed to fetch update channel: %v", err)
+		}
+		if channel.Name != "preview" {
+			t.Errorf("expected preview, got %q", channel.Name)
+		}
+	}
+	if got := len(server.Operations()); got != 1 {
+		t.Errorf("expected the second lookup to use the store, got %d requests: %v", got, server.Operations())
+	}
+	if _, ok, _ := shared.Get(ctx, "update-channel/"+appId+"/preview"); !ok {
+		t.Errorf("expected the update channel to be stored under the prefix")
+	}
+}
+
+func TestCacheExpires(t *testing.T) {
+	cache := expo.NewCache[string](50*time.Millisecond, 10)
+	ctx := context.Background()
+	if err := cache.Set(ctx, "key", "value"); err != nil {
+		t.Fatalf("failed to set: %v", err)
+	}
+	if value, ok, _ := cache.Get(ctx, "key"); !ok || value != "value" {
+		t.Fatalf("expected value, got %q, ok=%v", value, ok)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, ok, _ := cache.Get(ctx, "key"); ok {
+		t.Errorf("expected key to expire")
+	}
+}
+
+func TestCacheEvicts(t *testing.T) {
+	cache := expo.NewCache[string](time.Minute, 2)
+	ctx := context.Background()
+	for _, key := range []string{"first", "second", "third"} {
+		if err := cache.Set(ctx, key, key); err != nil {
+			t.Fatalf("failed to set %s: %v", key, err)
+		}
+		// entries set in the same instant would expire together, leaving which is evicted to chance
+		time.Sleep(time.Millisecond)
+	}
+	if _, ok, _ := cache.Get(ctx, "first"); ok {
+		t.Errorf("expected the entry closest to expiring to be evicted")
+	}
+	for _, key := range []string{"second", "third"} {
+		if _, ok, _ := cache.Get(ctx, key); !ok {
+			t.Errorf("expected %s to be kept", key)
+		}
+	}
+
+	// replacing an entry doesn't need room for another
+	if err := cache.Set(ctx, "third", "again"); err != nil {
+		t.Fatalf("failed to set: %v", err)
+	}
+	if _, ok, _ := cache.Get(ctx, "second"); !ok {
+		t.Errorf("expected second to be kept when third is replaced")
+	}
+}
+
+func TestCacheDisabled(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		ttl  time.Duration
+		size int
+	}{
+		{name: "no TTL", ttl: 0, size: 10},
+		{name: "no size", ttl: time.Minute, size: 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cache := expo.NewCache[string](tc.ttl, tc.size).Shared(store.NewMemory(), "prefix/")
+			ctx := context.Background()
+			if err := cache.Set(ctx, "key", "value"); err != nil {
+				t.Fatalf("failed to set: %v", err)
+			}
+			if _, ok, _ := cache.Get(ctx, "key"); ok {
+				t.Errorf("expected a disabled cache to hold nothing")
+			}
+		})
+	}
+}
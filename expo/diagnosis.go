@@ -0,0 +1,78 @@
+package expo
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Diagnosis is the likely cause of a failure and how to fix it, from what the failure has in common with
+// others we've seen. Cause and Fix are in English, ready to be translated.
+type Diagnosis struct {
+	Cause string
+	Fix   string
+	// DocsUrl explains the fix further.
+	DocsUrl string
+}
+
+// signature is how a kind of failure shows up in an error or a build log.
+type signature struct {
+	pattern *regexp.Regexp
+	Diagnosis
+}
+
+// signatures are tried in order, so the more specific come first; an expired profile also fails code signing,
+// for instance.
+var signatures = []signature{
+	{
+		pattern: regexp.MustCompile(`(?i)provisioning profile.*(expired|is no longer valid)|profile has expired`),
+		Diagnosis: Diagnosis{
+			Cause:   "the provisioning profile has expired",
+			Fix:     "run `eas credentials` to generate a new one, then rebuild",
+			DocsUrl: "https://docs.expo.dev/app-signing/app-credentials/",
+		},
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)code ?sign(ing)? (error|failed)|no signing certificate|no (valid )?code signing identit|requires a provisioning profile|doesn't match the entitlements|certificate has been revoked`),
+		Diagnosis: Diagnosis{
+			Cause:   "fastlane couldn't sign the app",
+			Fix:     "check the distribution certificate and provisioning profile with `eas credentials`, and that the profile covers the app's capabilities",
+			DocsUrl: "https://docs.expo.dev/app-signing/managed-credentials/",
+		},
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)OutOfMemoryError|Java heap space|GC overhead limit exceeded|Metaspace|Gradle build daemon disappeared`),
+		Diagnosis: Diagnosis{
+			Cause:   "Gradle ran out of memory",
+			Fix:     "raise `org.gradle.jvmargs` in gradle.properties, or build on a larger `resourceClass` in eas.json",
+			DocsUrl: "https://docs.expo.dev/build-reference/infrastructure/",
+		},
+	},
+	{
+		pattern: regexp.MustCompile(`(?i)Install dependencies build phase|npm ERR!|npm error|ERESOLVE|EINTEGRITY|yarn install .*failed|error Couldn't find package`),
+		Diagnosis: Diagnosis{
+			Cause:   "installing dependencies failed",
+			Fix:     "check that the lockfile is committed and in sync with package.json, and that private registries are reachable from EAS",
+			DocsUrl: "https://docs.expo.dev/build-reference/troubleshooting/",
+		},
+	},
+}
+
+// Diagnose looks for a known cause of the failure in its error and the end of its log, which can be nil. It
+// returns nil when the failure doesn't look like any we know of. Expo's own documentation link for the error,
+// if it has one, is preferred to ours.
+func Diagnose(e Error, logs []string) *Diagnosis {
+	if !e.Failed() {
+		return nil
+	}
+	text := e.ErrorCode + "\n" + e.Message + "\n" + strings.Join(logs, "\n")
+	for _, s := range signatures {
+		if s.pattern.MatchString(text) {
+			diagnosis := s.Diagnosis
+			if e.DocsUrl != "" {
+				diagnosis.DocsUrl = e.DocsUrl
+			}
+			return &diagnosis
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package expo
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Emoji overrides the Slack emoji used for platforms, statuses and distributions. Not every workspace has the
+// custom emoji we use by default installed, so each can be replaced by name: a platform like "ios", a status
+// like "errored" or a distribution like "internal". A nil *Emoji uses the defaults.
+type Emoji struct {
+	platforms     map[Platform]string
+	statuses      map[Status]string
+	distributions map[string]string
+}
+
+var (
+	knownPlatforms     = []Platform{PlatformAndroid, PlatformIOS, PlatformWeb}
+	knownStatuses      = []Status{StatusFinished, StatusCancelled, StatusErrored}
+	knownDistributions = []string{DistributionStore, DistributionInternal, DistributionSimulator}
+)
+
+// NewEmoji validates the overrides, keyed by platform, status or distribution name.
+func NewEmoji(overrides map[string]string) (*Emoji, error) {
+	e := &Emoji{platforms: map[Platform]string{}, statuses: map[Status]string{}, distributions: map[string]string{}}
+	for _, name := range slices.Sorted(maps.Keys(overrides)) {
+		key := strings.ToLower(name)
+		switch {
+		case slices.Contains(knownPlatforms, Platform(key)):
+			e.platforms[Platform(key)] = overrides[name]
+		case slices.Contains(knownStatuses, Status(key)):
+			e.statuses[Status(key)] = overrides[name]
+		case slices.Contains(knownDistributions, key):
+			e.distributions[key] = overrides[name]
+		default:
+			return nil, fmt.Errorf("unknown platform, status or distribution %q for emoji override", name)
+		}
+	}
+	return e, nil
+}
+
+// Platform is the emoji for the platform, falling back to PlatformEmoji.
+func (e *Emoji) Platform(platform Platform) string {
+	if e != nil {
+		if emoji, ok := e.platforms[platform]; ok {
+			return emoji
+		}
+	}
+	return PlatformEmoji(platform)
+}
+
+// Status is the emoji for the status, falling back to StatusEmoji.
+func (e *Emoji) Status(status Status) string {
+	if e != nil {
+		if emoji, ok := e.statuses[status]; ok {
+			return emoji
+		}
+	}
+	return StatusEmoji(status)
+}
+
+// Distribution is the emoji for how a build is distributed, falling back to DistributionEmoji.
+func (e *Emoji) Distribution(distribution string) string {
+	if e != nil {
+		if emoji, ok := e.distributions[strings.ToLower(distribution)]; ok {
+			return emoji
+		}
+	}
+	return DistributionEmoji(distribution)
+}
@@ -0,0 +1,44 @@
+package expo_test
+
+import (
+	"testing"
+
+	"github.com/NWACus/expo-slack-webhook/expo"
+)
+
+func TestEmojiOverrides(t *testing.T) {
+	emoji, err := expo.NewEmoji(map[string]string{
+		"iOS":      ":iphone:",
+		"errored":  ":x:",
+		"Internal": ":wrench:",
+	})
+	if err != nil {
+		t.Fatalf("failed to parse overrides: %v", err)
+	}
+	for _, tc := range []struct {
+		name          string
+		got, expected string
+	}{
+		{name: "overridden platform", got: emoji.Platform(expo.PlatformIOS), expected: ":iphone:"},
+		{name: "default platform", got: emoji.Platform(expo.PlatformAndroid), expected: ":android:"},
+		{name: "overridden status", got: emoji.Status(expo.StatusErrored), expected: ":x:"},
+		{name: "default status", got: emoji.Status(expo.StatusFinished), expected: ":large_green_circle:"},
+		{name: "overridden distribution", got: emoji.Distribution("INTERNAL"), expected: ":wrench:"},
+		{name: "default distribution", got: emoji.Distribution(expo.DistributionSimulator), expected: ":computer:"},
+	} {
+		if tc.got != tc.expected {
+			t.Errorf("%s: expected %q, got %q", tc.name, tc.expected, tc.got)
+		}
+	}
+
+	var defaults *expo.Emoji
+	if got, expected := defaults.Status(expo.StatusErrored), ":red_circle:"; got != expected {
+		t.Errorf("expected a nil *Emoji to use the default %q, got %q", expected, got)
+	}
+}
+
+func TestEmojiRejectsUnknownNames(t *testing.T) {
+	if _, err := expo.NewEmoji(map[string]string{"windows": ":window:"}); err == nil {
+		t.Errorf("expected an error for an unknown name")
+	}
+}
@@ -0,0 +1,114 @@
+// Package expotest serves canned Expo GraphQL responses, so that code using an expo.Client can be run
+// without credentials or access to api.expo.dev.
+package expotest
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/NWACus/expo-slack-webhook/expo"
+)
+
+// fixtures hold a response for each operation the expo package sends, named for the operation. They
+// describe the same app, build, submission and updates as the sample payloads in test/.
+//
+//go:embed testdata/*.json
+var fixtures embed.FS
+
+// Server is a fake Expo GraphQL API, responding to each request with the response registered for its
+// operationName.
+type Server struct {
+	*httptest.Server
+
+	lock       sync.Mutex
+	responses  map[string][]byte
+	handlers   map[string]func(variables json.RawMessage) []byte
+	operations []string
+}
+
+// NewServer starts a server responding with the default fixtures. Callers must Close it.
+func NewServer() *Server {
+	s := &Server{responses: map[string][]byte{}, handlers: map[string]func(json.RawMessage) []byte{}}
+	entries, err := fixtures.ReadDir("testdata")
+	if err != nil {
+		panic(fmt.Sprintf("failed to read fixtures: %v", err))
+	}
+	for _, entry := range entries {
+		raw, err := fixtures.ReadFile(path.Join("testdata", entry.Name()))
+		if err != nil {
+			panic(fmt.Sprintf("failed to read fixture %s: %v", entry.Name(), err))
+		}
+		s.responses[strings.TrimSuffix(entry.Name(), ".json")] = raw
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Respond replaces the response for an operation.
+func (s *Server) Respond(operation string, body []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.responses[operation] = body
+}
+
+// RespondFunc responds to an operation with whatever respond returns for the request's variables, like a
+// page of results for its offset, in place of a fixed response.
+func (s *Server) RespondFunc(operation string, respond func(variables json.RawMessage) []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.handlers[operation] = respond
+}
+
+// Operations lists the operations requested so far, in order.
+func (s *Server) Operations() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string(nil), s.operations...)
+}
+
+// Client returns an expo.Client that talks to this server.
+func (s *Server) Client() *expo.Client {
+	return &expo.Client{
+		Token:      "expotest",
+		BaseURL:    s.URL,
+		HTTPClient: s.Server.Client(),
+	}
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read request: %v", err), http.StatusInternalServerError)
+		return
+	}
+	var query struct {
+		OperationName string          `json:"operationName"`
+		Variables     json.RawMessage `json:"variables"`
+	}
+	if err := json.Unmarshal(body, &query); err != nil {
+		http.Error(w, fmt.Sprintf("failed to unmarshal request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	s.lock.Lock()
+	s.operations = append(s.operations, query.OperationName)
+	response, ok := s.responses[query.OperationName]
+	handler := s.handlers[query.OperationName]
+	s.lock.Unlock()
+	if handler != nil {
+		response, ok = handler(query.Variables), true
+	}
+	if !ok {
+		http.Error(w, fmt.Sprintf("no response registered for operation %q", query.OperationName), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(response)
+}
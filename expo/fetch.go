@@ -1,13 +1,146 @@
 package expo
 
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/NWACus/expo-slack-webhook/metrics"
+)
+
 type Client struct {
-	Token string
+	Token   string
+	Logger  *slog.Logger
+	Metrics *metrics.Metrics
+
+	// UpdateChannelCache, when set, holds recently fetched update channels, as they rarely change.
+	UpdateChannelCache *Cache[*UpdateChannel]
+	// AppCache, when set, holds recently fetched apps, which we look up for every message to link to them.
+	AppCache *Cache[*App]
+
+	// SearchLimit caps how many builds, submissions or update groups we page back through looking for an
+	// earlier one, defaulting to DefaultSearchLimit.
+	SearchLimit int
+
+	// BaseURL and HTTPClient default to Expo's GraphQL API and http.DefaultClient; they're overridden to
+	// talk to a fake Expo, like the one in expotest.
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+func (c *Client) logger() *slog.Logger {
+	if c.Logger == nil {
+		return slog.Default()
+	}
+	return c.Logger
+}
+
+// MaxSearched is how many builds, submissions or update groups to page back through, from SearchLimit.
+func (c *Client) MaxSearched() int {
+	if c.SearchLimit < 1 {
+		return DefaultSearchLimit
+	}
+	return c.SearchLimit
+}
+
+func (c *Client) baseURL() string {
+	if c.BaseURL == "" {
+		return expoAPIURL
+	}
+	return c.BaseURL
 }
 
-const expoAPIURL = "https://api.expo.dev/graphql"
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return c.HTTPClient
+}
+
+const (
+	expoAPIURL = "https://api.expo.dev/graphql"
+	// DefaultSearchLimit is how many builds, submissions or update groups we page back through when
+	// SearchLimit isn't set.
+	DefaultSearchLimit = 100
+)
 
 type graphQLQuery[V any] struct {
 	OperationName string `json:"operationName"`
 	Query         string `json:"query"`
 	Variables     V      `json:"variables"`
 }
+
+// graphQLErrors are the errors a GraphQL response may have, in place of or alongside its data.
+type graphQLErrors []struct {
+	Message string `json:"message"`
+}
+
+func (e graphQLErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	var messages []string
+	for _, err := range e {
+		messages = append(messages, err.Message)
+	}
+	return errors.New(strings.Join(messages, "; "))
+}
+
+// execute sends the query to the Expo GraphQL API and unmarshals the response into out.
+func execute[V any](ctx context.Context, c *Client, query graphQLQuery[V], out any) error {
+	payload, err := json.Marshal(query)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL(), bytes.NewBuffer(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("accept", "application/graphql-response+json")
+	req.Header.Add("accept", "application/graphql+json")
+	req.Header.Add("accept", "application/json")
+	req.Header.Add("authorization", "bearer "+c.Token)
+	req.Header.Add("content-type", "application/json")
+
+	start := time.Now()
+	resp, err := c.httpClient().Do(req)
+	c.Metrics.ObserveExpoRequest(query.OperationName, time.Since(start))
+	if err != nil {
+		return err
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		c.logger().Error("failed to read response", "error", err)
+	}
+	if err := resp.Body.Close(); err != nil {
+		c.logger().Error("failed to close response body", "error", err)
+	}
+	// GraphQL reports errors in the body, with a 200 as often as not, so we look for them first
+	var failed struct {
+		Errors graphQLErrors `json:"errors"`
+	}
+	_ = json.Unmarshal(body, &failed)
+	if resp.StatusCode != http.StatusOK {
+		if err := failed.Errors.err(); err != nil {
+			return fmt.Errorf("%d: %w", resp.StatusCode, err)
+		}
+		return fmt.Errorf("%d: %s", resp.StatusCode, string(body))
+	}
+	c.logger().Debug("response body", "operation", query.OperationName, "body", string(body))
+	if err := failed.Errors.err(); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %v", err)
+	}
+	return nil
+}
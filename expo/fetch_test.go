@@ -0,0 +1,23 @@
+package expo_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/NWACus/expo-slack-webhook/expo/expotest"
+)
+
+func TestExecuteReturnsGraphQLErrors(t *testing.T) {
+	server := expotest.NewServer()
+	defer server.Close()
+	server.Respond("AppByIdQuery", []byte(`{"data":null,"errors":[{"message":"Could not find app"},{"message":"Unauthorized"}]}`))
+
+	app, err := server.Client().FetchApp(context.Background(), "missing")
+	if err == nil {
+		t.Fatalf("expected an error, got app %+v", app)
+	}
+	if got, want := err.Error(), "Could not find app; Unauthorized"; !strings.Contains(got, want) {
+		t.Errorf("expected error to contain %q, got %q", want, got)
+	}
+}
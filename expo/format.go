@@ -1,6 +1,12 @@
 package expo
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NWACus/expo-slack-webhook/i18n"
+)
 
 func PlatformEmoji(platform Platform) string {
 	switch platform {
@@ -8,18 +14,23 @@ func PlatformEmoji(platform Platform) string {
 		return ":android:"
 	case PlatformIOS:
 		return ":apple_logo:"
+	case PlatformWeb:
+		return ":globe_with_meridians:"
 	}
 	return ":grey_question:"
 }
 
-func PlatformDisplay(platform Platform) string {
+// PlatformDisplay names the platform, translated by the catalog.
+func PlatformDisplay(catalog *i18n.Catalog, platform Platform) string {
 	switch platform {
 	case PlatformAndroid:
-		return "Android"
+		return catalog.T("Android")
 	case PlatformIOS:
-		return "iOS"
+		return catalog.T("iOS")
+	case PlatformWeb:
+		return catalog.T("Web")
 	}
-	return "Unknown platform "
+	return catalog.T("Unknown platform ")
 }
 
 func StatusEmoji(status Status) string {
@@ -34,22 +45,164 @@ func StatusEmoji(status Status) string {
 	return ":black_circle:"
 }
 
-func StatusDisplay(status Status) string {
+const (
+	DistributionStore     = "store"
+	DistributionInternal  = "internal"
+	DistributionSimulator = "simulator"
+)
+
+// DistributionEmoji marks builds that aren't distributed through the stores, so that they stand out from
+// those that are. The webhook sends distributions in lower case, but the GraphQL API in upper case.
+func DistributionEmoji(distribution string) string {
+	switch strings.ToLower(distribution) {
+	case DistributionInternal:
+		return ":test_tube:"
+	case DistributionSimulator:
+		return ":computer:"
+	}
+	return ""
+}
+
+// StatusDisplay describes the status as a verb, translated by the catalog.
+func StatusDisplay(catalog *i18n.Catalog, status Status) string {
+	switch status {
+	case StatusFinished:
+		return catalog.T("succeeded")
+	case StatusCancelled:
+		return catalog.T("cancelled")
+	case StatusErrored:
+		return catalog.T("errored")
+	}
+	return catalog.T("in an unknown state")
+}
+
+// StatusSymbol is the Unicode equivalent of StatusEmoji, for destinations other than Slack.
+func StatusSymbol(status Status) string {
 	switch status {
 	case StatusFinished:
-		return "succeeded"
+		return "🟢"
 	case StatusCancelled:
-		return "cancelled"
+		return "🟡"
 	case StatusErrored:
-		return "errored"
+		return "🔴"
 	}
-	return "in an unknown state"
+	return "⚫"
 }
 
-func FormatTitle(emoji, name string, platform Platform, status Status) string {
-	return fmt.Sprintf(`%s %s %s | %s %s %s.`, emoji, PlatformEmoji(platform), StatusEmoji(status), PlatformDisplay(platform), name, StatusDisplay(status))
+func FormatTitle(table *Emoji, catalog *i18n.Catalog, emoji, name string, platform Platform, status Status) string {
+	return fmt.Sprintf(`%s %s %s | %s`, emoji, table.Platform(platform), table.Status(status), catalog.T("%s %s %s.", PlatformDisplay(catalog, platform), name, StatusDisplay(catalog, status)))
+}
+
+// FormatActor names whoever started a build or submission, marking robots, like the GitHub app building on
+// pushes, so that they aren't taken for people. It is empty when there's no one to name.
+func FormatActor(catalog *i18n.Catalog, actor Actor) string {
+	switch {
+	case actor.DisplayName == "":
+		return ""
+	case actor.IsManagedByGitHubApp:
+		return ":robot_face: " + catalog.T("%s (GitHub app)", actor.DisplayName)
+	case actor.IsRobot():
+		return ":robot_face: " + catalog.T("%s (robot)", actor.DisplayName)
+	}
+	return actor.DisplayName
+}
+
+// FormatBuildVersion is the app version and build number, linking to the commit in the repository (as
+// owner/name) and to the update channel in the project.
+func FormatBuildVersion(repository string, project Project, build BuildVersionMetadata) string {
+	version := FormatVersion(build)
+	if build.GitCommitHash != "" {
+		version += fmt.Sprintf(` [<%s|%s>]`, CommitURL(repository, build.GitCommitHash), ShortCommit(build.GitCommitHash))
+	}
+	return version + fmt.Sprintf(` @<%s|%s>`, ProjectURL(project, "channels", build.Channel), build.Channel)
+}
+
+// Project identifies an app's project on expo.dev by the account owning it and its slug.
+type Project struct {
+	Account string
+	Slug    string
+}
+
+// DefaultProject is the project linked to when an app's can't be looked up.
+var DefaultProject = Project{Account: "nwac", Slug: "avalanche-forecast"}
+
+// ProjectURL links to a page of the project on expo.dev, like one of its builds, given the kind of page
+// (builds, submissions, updates or channels) and the ID or name of what it's for.
+func ProjectURL(project Project, kind, id string) string {
+	return fmt.Sprintf("https://expo.dev/accounts/%s/projects/%s/%s/%s", project.Account, project.Slug, kind, id)
 }
 
-func FormatBuildVersion(build BuildVersionMetadata) string {
-	return fmt.Sprintf(`%s (%s) [<https://github.com/NWACus/avy/commit/%s|%s>] @<https://expo.dev/accounts/nwac/projects/avalanche-forecast/channels/%s|%s>`, build.AppVersion, build.AppBuildVersion, build.GitCommitHash, build.GitCommitHash[0:7], build.Channel, build.Channel)
-}
\ No newline at end of file
+// FormatVersion is the app version and build number, without any links.
+func FormatVersion(build BuildVersionMetadata) string {
+	return fmt.Sprintf(`%s (%s)`, build.AppVersion, build.AppBuildVersion)
+}
+
+// DefaultRepository is the GitHub repository, as owner/name, that commits are linked to when no other is
+// configured or linked to the app in Expo.
+const DefaultRepository = "NWACus/avy"
+
+// CommitURL links to a commit in the GitHub repository, given as owner/name.
+func CommitURL(repository, hash string) string {
+	return fmt.Sprintf("https://github.com/%s/commit/%s", repository, hash)
+}
+
+// CompareURL links to the changes between two commits in the GitHub repository, given as owner/name.
+func CompareURL(repository, from, to string) string {
+	return fmt.Sprintf("https://github.com/%s/compare/%s...%s", repository, from, to)
+}
+
+// ShortCommit abbreviates a commit hash the way GitHub does.
+func ShortCommit(hash string) string {
+	if len(hash) < 7 {
+		return hash
+	}
+	return hash[0:7]
+}
+
+// CommitSummary is the first line of a commit message, which by convention summarises the change.
+func CommitSummary(message string) string {
+	summary, _, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	return strings.TrimSpace(summary)
+}
+
+// FormatTimestamp describes when something happened as a Slack date token, so that readers see it in their
+// own timezone, followed by how long ago it was: "on Jan 2 at 3:04 PM (3 hours ago)".
+func FormatTimestamp(catalog *i18n.Catalog, t time.Time) string {
+	return catalog.T("on %s (%s ago)", SlackDate(t), FormatDuration(catalog, time.Since(t)))
+}
+
+// SlackDate is a Slack date token for the time, which Slack shows in the reader's timezone. Clients that
+// can't render it show the time in UTC instead.
+func SlackDate(t time.Time) string {
+	return fmt.Sprintf("<!date^%d^{date_short_pretty} at {time}|%s>", t.Unix(), t.UTC().Format("2006-01-02 15:04 MST"))
+}
+
+// FormatDuration describes a duration roughly, in its largest whole unit, like "3 hours".
+func FormatDuration(catalog *i18n.Catalog, d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return catalog.Plural(int(d.Seconds()), "%d second", "%d seconds")
+	case d < time.Hour:
+		return catalog.Plural(int(d.Minutes()), "%d minute", "%d minutes")
+	case d < 24*time.Hour:
+		return catalog.Plural(int(d.Hours()), "%d hour", "%d hours")
+	case d < 30*24*time.Hour:
+		return catalog.Plural(int(d.Hours()/24), "%d day", "%d days")
+	case d < 365*24*time.Hour:
+		return catalog.Plural(int(d.Hours()/(30*24)), "%d month", "%d months")
+	default:
+		return catalog.Plural(int(d.Hours()/(365*24)), "%d year", "%d years")
+	}
+}
+
+// FormatSize describes a number of bytes in megabytes, or kilobytes for less than one, like "4.2 MB". The
+// sign is dropped, so that deltas can be worded as growing or shrinking.
+func FormatSize(bytes int64) string {
+	if bytes < 0 {
+		bytes = -bytes
+	}
+	if bytes < 1000*1000 {
+		return fmt.Sprintf("%d KB", (bytes+500)/1000)
+	}
+	return fmt.Sprintf("%.1f MB", float64(bytes)/(1000*1000))
+}
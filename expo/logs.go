@@ -0,0 +1,75 @@
+package expo
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// logOperation labels log downloads in the Expo request metrics, alongside the GraphQL operations.
+	logOperation = "BuildLogFile"
+	// maxLogLine is the longest log line we read; longer lines, like minified stack traces, are cut short.
+	maxLogLine = 64 * 1024
+)
+
+// logEntry is a line of a build log, which EAS writes as JSON. Lines that aren't JSON are kept as they are.
+type logEntry struct {
+	Msg string `json:"msg"`
+}
+
+// FetchLogTail downloads a build log, one of a build's LogFiles, and returns the last lines of its messages.
+// The log URLs are signed, so they're fetched without our token.
+func (c *Client) FetchLogTail(ctx context.Context, url string, lines int) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	start := time.Now()
+	resp, err := c.httpClient().Do(req)
+	c.Metrics.ObserveExpoRequest(logOperation, time.Since(start))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch build log: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			c.logger().Error("failed to close response body", "error", err)
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("failed to fetch build log: %d: %s", resp.StatusCode, string(body))
+	}
+
+	var tail []string
+	reader := bufio.NewReaderSize(resp.Body, maxLogLine)
+	for {
+		line, isPrefix, err := reader.ReadLine()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read build log: %v", err)
+		}
+		// the line is only ours until the next read, after which we skip the rest of a line too long to buffer
+		text := string(line)
+		for skipping := isPrefix; skipping && err == nil; {
+			_, skipping, err = reader.ReadLine()
+		}
+		var entry logEntry
+		if err := json.Unmarshal([]byte(text), &entry); err == nil && entry.Msg != "" {
+			text = entry.Msg
+		}
+		tail = append(tail, strings.Split(strings.TrimRight(text, "\n"), "\n")...)
+		if len(tail) > lines {
+			tail = tail[len(tail)-lines:]
+		}
+	}
+	return tail, nil
+}
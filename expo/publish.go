@@ -0,0 +1,166 @@
+package expo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type updateGroupVariables struct {
+	GroupId string `json:"groupId"`
+}
+
+const updateGroupOperation = "ViewUpdatesByGroup"
+const updateGroupQuery = "query ViewUpdatesByGroup($groupId: ID!) {\n  updatesByGroup(group: $groupId) {\n    id\n    group\n    message\n    createdAt\n    runtimeVersion\n    platform\n    manifestFragment\n    isRollBackToEmbedded\n    gitCommitHash\n    codeSigningInfo {\n      keyid\n      sig\n      alg\n      __typename\n    }\n    rolloutPercentage\n    rolloutControlUpdate {\n      id\n      __typename\n    }\n    branch {\n      id\n      name\n      __typename\n    }\n    __typename\n  }\n}"
+
+type updateGroupResponse struct {
+	Data struct {
+		UpdatesByGroup []Update `json:"updatesByGroup"`
+	} `json:"data"`
+}
+
+// FetchUpdateGroup fetches the updates published together in the group, one for each platform.
+func (c *Client) FetchUpdateGroup(ctx context.Context, groupId string) ([]Update, error) {
+	logger := c.logger().With("group", groupId)
+	logger.Info("fetching update group")
+	query := graphQLQuery[updateGroupVariables]{
+		OperationName: updateGroupOperation,
+		Query:         updateGroupQuery,
+		Variables: updateGroupVariables{
+			GroupId: groupId,
+		},
+	}
+
+	var parsed updateGroupResponse
+	if err := execute(ctx, c, query, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to fetch update group: %w", err)
+	}
+	logger.Info("fetched update group", "count", len(parsed.Data.UpdatesByGroup))
+	return parsed.Data.UpdatesByGroup, nil
+}
+
+type updateBranchVariables struct {
+	AppId string `json:"appId"`
+	Name  string `json:"name"`
+}
+
+const updateBranchOperation = "ViewBranch"
+const updateBranchQuery = "query ViewBranch($appId: String!, $name: String!) {\n  app {\n    byId(appId: $appId) {\n      id\n      updateBranchByName(name: $name) {\n        id\n        name\n        __typename\n      }\n      __typename\n    }\n    __typename\n  }\n}"
+
+type updateBranchResponse struct {
+	Data struct {
+		App struct {
+			ById struct {
+				UpdateBranchByName *BranchFragment `json:"updateBranchByName"`
+			} `json:"byId"`
+		} `json:"app"`
+	} `json:"data"`
+}
+
+// FetchUpdateBranch looks up a branch by name, which is how we find the ID to publish to.
+func (c *Client) FetchUpdateBranch(ctx context.Context, projectId, name string) (*BranchFragment, error) {
+	logger := c.logger().With("app_id", projectId, "branch", name)
+	logger.Info("fetching update branch")
+	query := graphQLQuery[updateBranchVariables]{
+		OperationName: updateBranchOperation,
+		Query:         updateBranchQuery,
+		Variables: updateBranchVariables{
+			AppId: projectId,
+			Name:  name,
+		},
+	}
+
+	var parsed updateBranchResponse
+	if err := execute(ctx, c, query, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to fetch update branch: %w", err)
+	}
+	if parsed.Data.App.ById.UpdateBranchByName == nil {
+		return nil, fmt.Errorf("no update branch named %q", name)
+	}
+	logger.Info("fetched update branch", "branch_id", parsed.Data.App.ById.UpdateBranchByName.Id)
+	return parsed.Data.App.ById.UpdateBranchByName, nil
+}
+
+// publishUpdateGroupInput mirrors PublishUpdateGroupInput, holding the manifest fragment or rollback flag
+// for each platform in the group.
+type publishUpdateGroupInput struct {
+	BranchId                    string                     `json:"branchId"`
+	RuntimeVersion              string                     `json:"runtimeVersion"`
+	Message                     string                     `json:"message"`
+	GitCommitHash               string                     `json:"gitCommitHash,omitempty"`
+	UpdateInfoGroup             map[string]json.RawMessage `json:"updateInfoGroup,omitempty"`
+	RollBackToEmbeddedInfoGroup map[string]bool            `json:"rollBackToEmbeddedInfoGroup,omitempty"`
+}
+
+type publishVariables struct {
+	Inputs []publishUpdateGroupInput `json:"publishUpdateGroupsInput"`
+}
+
+const publishOperation = "UpdatePublishMutation"
+const publishMutation = "mutation UpdatePublishMutation($publishUpdateGroupsInput: [PublishUpdateGroupInput!]!) {\n  updateBranch {\n    publishUpdateGroups(publishUpdateGroupsInput: $publishUpdateGroupsInput) {\n      id\n      group\n      runtimeVersion\n      platform\n      isRollBackToEmbedded\n      gitCommitHash\n      createdAt\n      __typename\n    }\n    __typename\n  }\n}"
+
+type publishResponse struct {
+	Data struct {
+		UpdateBranch struct {
+			PublishUpdateGroups []Update `json:"publishUpdateGroups"`
+		} `json:"updateBranch"`
+	} `json:"data"`
+}
+
+// RepublishUpdateGroup publishes the updates again on another branch, reusing their assets, like
+// `eas update:republish`. Expo needs a group for each runtime version, so updates built for different
+// runtimes come back in separate groups.
+func (c *Client) RepublishUpdateGroup(ctx context.Context, updates []Update, branchId, message string) ([]Update, error) {
+	if len(updates) == 0 {
+		return nil, fmt.Errorf("no updates to republish")
+	}
+	logger := c.logger().With("group", updates[0].Group, "branch_id", branchId)
+
+	var inputs []publishUpdateGroupInput
+	byRuntime := map[string]int{}
+	for _, update := range updates {
+		i, ok := byRuntime[update.RuntimeVersion]
+		if !ok {
+			i = len(inputs)
+			byRuntime[update.RuntimeVersion] = i
+			inputs = append(inputs, publishUpdateGroupInput{
+				BranchId:       branchId,
+				RuntimeVersion: update.RuntimeVersion,
+				Message:        message,
+				GitCommitHash:  update.GitCommitHash,
+			})
+		}
+		platform := strings.ToLower(string(update.Platform))
+		if update.IsRollBackToEmbedded {
+			if inputs[i].RollBackToEmbeddedInfoGroup == nil {
+				inputs[i].RollBackToEmbeddedInfoGroup = map[string]bool{}
+			}
+			inputs[i].RollBackToEmbeddedInfoGroup[platform] = true
+			continue
+		}
+		if update.ManifestFragment == "" {
+			return nil, fmt.Errorf("update %s has no manifest to republish", update.Id)
+		}
+		if inputs[i].UpdateInfoGroup == nil {
+			inputs[i].UpdateInfoGroup = map[string]json.RawMessage{}
+		}
+		inputs[i].UpdateInfoGroup[platform] = json.RawMessage(update.ManifestFragment)
+	}
+
+	logger.Info("republishing update group", "groups", len(inputs))
+	query := graphQLQuery[publishVariables]{
+		OperationName: publishOperation,
+		Query:         publishMutation,
+		Variables: publishVariables{
+			Inputs: inputs,
+		},
+	}
+
+	var parsed publishResponse
+	if err := execute(ctx, c, query, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to republish update group: %w", err)
+	}
+	logger.Info("republished update group", "count", len(parsed.Data.UpdateBranch.PublishUpdateGroups))
+	return parsed.Data.UpdateBranch.PublishUpdateGroups, nil
+}
@@ -1,14 +1,9 @@
 package expo
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
-	"log"
-	"net/http"
-	"os"
+	"strings"
 )
 
 type submissionVariables struct {
@@ -16,7 +11,10 @@ type submissionVariables struct {
 }
 
 const submissionOperation = "SubmissionByIdQuery"
-const submissionQuery = "query SubmissionByIdQuery($id: ID!) {\n  submissions {\n    byId(submissionId: $id) {\n      ...SubmissionFragment\n      __typename\n    }\n    __typename\n  }\n}\n\nfragment SubmissionFragment on Submission {\n  id\n  status\n  createdAt\n  updatedAt\n  platform\n  priority\n  app {\n    id\n    name\n    icon {\n      url\n      __typename\n    }\n    fullName\n    __typename\n  }\n  initiatingActor {\n    __typename\n    firstName\n    displayName\n    ... on UserActor {\n      username\n      fullName\n      profilePhoto\n      __typename\n    }\n  }\n  logFiles\n  error {\n    errorCode\n    message\n    __typename\n  }\n  submittedBuild {\n    ...Build\n    __typename\n  }\n  canRetry\n  childSubmission {\n    id\n    __typename\n  }\n  __typename\n}\n\nfragment Build on Build {\n  __typename\n  id\n  platform\n  status\n  app {\n    id\n    fullName\n    slug\n    name\n    iconUrl\n    githubRepository {\n      githubRepositoryUrl\n      __typename\n    }\n    ownerAccount {\n      name\n      __typename\n    }\n    __typename\n  }\n  artifacts {\n    applicationArchiveUrl\n    buildArtifactsUrl\n    xcodeBuildLogsUrl\n    __typename\n  }\n  distribution\n  logFiles\n  metrics {\n    buildWaitTime\n    buildQueueTime\n    buildDuration\n    __typename\n  }\n  initiatingActor {\n    id\n    displayName\n    ... on UserActor {\n      username\n      fullName\n      profilePhoto\n      __typename\n    }\n    ... on User {\n      primaryAccount {\n        profileImageUrl\n        __typename\n      }\n      __typename\n    }\n    ... on Robot {\n      isManagedByGitHubApp\n      __typename\n    }\n    __typename\n  }\n  createdAt\n  enqueuedAt\n  provisioningStartedAt\n  workerStartedAt\n  completedAt\n  updatedAt\n  expirationDate\n  sdkVersion\n  runtime {\n    ...RuntimeBasicInfo\n    __typename\n  }\n  channel\n  updateChannel {\n    id\n    name\n    __typename\n  }\n  fingerprint {\n    ...FingerprintData\n    __typename\n  }\n  buildProfile\n  appVersion\n  appBuildVersion\n  gitCommitHash\n  gitCommitMessage\n  isGitWorkingTreeDirty\n  message\n  resourceClassDisplayName\n  gitRef\n  projectRootDirectory\n  projectMetadataFileUrl\n  childBuild {\n    id\n    buildMode\n    __typename\n  }\n  priority\n  queuePosition\n  initialQueuePosition\n  estimatedWaitTimeLeftSeconds\n  submissions {\n    id\n    status\n    canRetry\n    __typename\n  }\n  canRetry\n  retryDisabledReason\n  maxRetryTimeMinutes\n  buildMode\n  customWorkflowName\n  isWaived\n  developmentClient\n  selectedImage\n  customNodeVersion\n  isForIosSimulator\n  resolvedEnvironment\n  cliVersion\n}\n\nfragment RuntimeBasicInfo on Runtime {\n  __typename\n  id\n  version\n  isFingerprint\n}\n\nfragment FingerprintData on Fingerprint {\n  __typename\n  id\n  hash\n  debugInfoUrl\n  createdAt\n}"
+const submissionQuery = "query SubmissionByIdQuery($id: ID!) {\n  submissions {\n    byId(submissionId: $id) {\n      ...SubmissionFragment\n      __typename\n    }\n    __typename\n  }\n}\n\n" + submissionFragments
+
+// submissionFragments are shared by the queries for a single submission and for a list of them.
+const submissionFragments = "fragment SubmissionFragment on Submission {\n  id\n  status\n  createdAt\n  updatedAt\n  platform\n  priority\n  app {\n    id\n    name\n    slug\n    iconUrl\n    icon {\n      url\n      __typename\n    }\n    fullName\n    ownerAccount {\n      name\n      __typename\n    }\n    githubRepository {\n      githubRepositoryUrl\n      __typename\n    }\n    __typename\n  }\n  initiatingActor {\n    __typename\n    id\n    firstName\n    displayName\n    ... on UserActor {\n      username\n      fullName\n      profilePhoto\n      __typename\n    }\n    ... on Robot {\n      isManagedByGitHubApp\n      __typename\n    }\n  }\n  logFiles\n  error {\n    errorCode\n    message\n    __typename\n  }\n  submittedBuild {\n    ...Build\n    __typename\n  }\n  canRetry\n  childSubmission {\n    id\n    __typename\n  }\n  __typename\n}\n\nfragment Build on Build {\n  __typename\n  id\n  platform\n  status\n  app {\n    id\n    fullName\n    slug\n    name\n    iconUrl\n    githubRepository {\n      githubRepositoryUrl\n      __typename\n    }\n    ownerAccount {\n      name\n      __typename\n    }\n    __typename\n  }\n  artifacts {\n    applicationArchiveUrl\n    buildArtifactsUrl\n    xcodeBuildLogsUrl\n    __typename\n  }\n  distribution\n  logFiles\n  metrics {\n    buildWaitTime\n    buildQueueTime\n    buildDuration\n    __typename\n  }\n  initiatingActor {\n    id\n    displayName\n    ... on UserActor {\n      username\n      fullName\n      profilePhoto\n      __typename\n    }\n    ... on User {\n      primaryAccount {\n        profileImageUrl\n        __typename\n      }\n      __typename\n    }\n    ... on Robot {\n      isManagedByGitHubApp\n      __typename\n    }\n    __typename\n  }\n  createdAt\n  enqueuedAt\n  provisioningStartedAt\n  workerStartedAt\n  completedAt\n  updatedAt\n  expirationDate\n  sdkVersion\n  runtime {\n    ...RuntimeBasicInfo\n    __typename\n  }\n  channel\n  updateChannel {\n    id\n    name\n    __typename\n  }\n  fingerprint {\n    ...FingerprintData\n    __typename\n  }\n  buildProfile\n  appVersion\n  appBuildVersion\n  gitCommitHash\n  gitCommitMessage\n  isGitWorkingTreeDirty\n  message\n  resourceClassDisplayName\n  gitRef\n  projectRootDirectory\n  projectMetadataFileUrl\n  childBuild {\n    id\n    buildMode\n    __typename\n  }\n  priority\n  queuePosition\n  initialQueuePosition\n  estimatedWaitTimeLeftSeconds\n  submissions {\n    id\n    status\n    canRetry\n    __typename\n  }\n  canRetry\n  retryDisabledReason\n  maxRetryTimeMinutes\n  buildMode\n  customWorkflowName\n  isWaived\n  developmentClient\n  selectedImage\n  customNodeVersion\n  isForIosSimulator\n  resolvedEnvironment\n  cliVersion\n}\n\nfragment RuntimeBasicInfo on Runtime {\n  __typename\n  id\n  version\n  isFingerprint\n}\n\nfragment FingerprintData on Fingerprint {\n  __typename\n  id\n  hash\n  debugInfoUrl\n  createdAt\n}"
 
 type submissionResponse struct {
 	Data struct {
@@ -27,7 +25,7 @@ type submissionResponse struct {
 }
 
 func (c *Client) FetchSubmission(ctx context.Context, id string) (*Submission, error) {
-	log.Printf("Fetching submission %s", id)
+	c.logger().Info("fetching submission", "submission_id", id)
 	query := graphQLQuery[submissionVariables]{
 		OperationName: submissionOperation,
 		Query:         submissionQuery,
@@ -36,43 +34,53 @@ func (c *Client) FetchSubmission(ctx context.Context, id string) (*Submission, e
 		},
 	}
 
-	payload, err := json.Marshal(query)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	var parsed submissionResponse
+	if err := execute(ctx, c, query, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to fetch submission: %w", err)
 	}
+	c.logger().Info("fetched submission", "submission_id", parsed.Data.Submissions.ById.Id, "build_id", parsed.Data.Submissions.ById.SubmittedBuild.Id, "app_version", parsed.Data.Submissions.ById.SubmittedBuild.AppVersion, "app_build_version", parsed.Data.Submissions.ById.SubmittedBuild.AppBuildVersion)
+	return &parsed.Data.Submissions.ById, nil
+}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", expoAPIURL, bytes.NewBuffer(payload))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
-	}
-	req.Header.Set("accept", "application/graphql-response+json")
-	req.Header.Add("accept", "application/graphql+json")
-	req.Header.Add("accept", "application/json")
-	req.Header.Add("authorization", "bearer "+c.Token)
-	req.Header.Add("content-type", "application/json")
+type submissionsVariables struct {
+	AppId    string `json:"appId"`
+	Platform string `json:"platform"`
+	Limit    int    `json:"limit"`
+	Offset   int    `json:"offset"`
+}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch submissions: %v", err)
-	}
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("failed to read response: %v", err)
-	}
-	if err := resp.Body.Close(); err != nil {
-		log.Printf("failed to close response body: %v", err)
-	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to fetch submissions: %d: %s", resp.StatusCode, string(body))
-	}
-	if _, debug := os.LookupEnv("DEBUG"); debug {
-		log.Printf("response body: %s", string(body))
+const submissionsOperation = "GetAllSubmissionsForApp"
+
+const submissionsQuery = "query GetAllSubmissionsForApp($appId: String!, $offset: Int!, $limit: Int!, $status: SubmissionStatus, $platform: AppPlatform) {\n  app {\n    byId(appId: $appId) {\n      id\n      submissions(filter: {platform: $platform, status: $status}, offset: $offset, limit: $limit) {\n        id\n        ...SubmissionFragment\n        __typename\n      }\n      __typename\n    }\n    __typename\n  }\n}\n\n" + submissionFragments
+
+type submissionsResponse struct {
+	Data struct {
+		App struct {
+			ById struct {
+				Submissions []Submission `json:"submissions"`
+			} `json:"byId"`
+		} `json:"app"`
+	} `json:"data"`
+}
+
+func (c *Client) FetchSubmissions(ctx context.Context, appId string, platform Platform, limit, offset int) ([]Submission, error) {
+	logger := c.logger().With("app_id", appId, "platform", platform)
+	logger.Info("fetching submissions", "offset", offset, "limit", limit)
+	query := graphQLQuery[submissionsVariables]{
+		OperationName: submissionsOperation,
+		Query:         submissionsQuery,
+		Variables: submissionsVariables{
+			AppId:    appId,
+			Platform: strings.ToUpper(string(platform)),
+			Limit:    limit,
+			Offset:   offset,
+		},
 	}
 
-	var parsed submissionResponse
-	if err := json.Unmarshal(body, &parsed); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %v", err)
+	var parsed submissionsResponse
+	if err := execute(ctx, c, query, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to fetch submissions: %w", err)
 	}
-	log.Printf("Fetched submissions %s, for build %s", parsed.Data.Submissions.ById.Id, FormatBuildVersion(parsed.Data.Submissions.ById.SubmittedBuild.BuildVersionMetadata))
-	return &parsed.Data.Submissions.ById, nil
+	logger.Info("fetched submissions", "count", len(parsed.Data.App.ById.Submissions))
+	return parsed.Data.App.ById.Submissions, nil
 }
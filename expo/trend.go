@@ -0,0 +1,58 @@
+package expo
+
+import "time"
+
+const (
+	// minTrendBuilds is how many earlier builds we need to have run to compare a build's duration with them.
+	minTrendBuilds = 3
+	// trendAnomaly is how many times slower or faster than the average a build has to be to stand out.
+	trendAnomaly = 1.5
+)
+
+// DurationTrend compares how long a build ran for with the average of recent builds.
+type DurationTrend struct {
+	Duration time.Duration
+	Average  time.Duration
+	// Builds is how many builds the average is of.
+	Builds int
+}
+
+// NewDurationTrend compares the duration with the average of the earlier ones, or returns nil if there are too
+// few of them to go by.
+func NewDurationTrend(duration time.Duration, earlier []time.Duration) *DurationTrend {
+	if len(earlier) < minTrendBuilds {
+		return nil
+	}
+	var total time.Duration
+	for _, d := range earlier {
+		total += d
+	}
+	return &DurationTrend{Duration: duration, Average: total / time.Duration(len(earlier)), Builds: len(earlier)}
+}
+
+// Ratio is how many times longer than the average the build took, which is less than one if it was quicker.
+func (t DurationTrend) Ratio() float64 {
+	if t.Average <= 0 {
+		return 1
+	}
+	return float64(t.Duration) / float64(t.Average)
+}
+
+// Factor is how many times slower or faster than the average the build was, whichever it was, so that a build
+// taking half as long as the average is twice as fast.
+func (t DurationTrend) Factor() float64 {
+	if ratio := t.Ratio(); ratio < 1 && ratio > 0 {
+		return 1 / ratio
+	}
+	return t.Ratio()
+}
+
+// Slower reports whether the build took unusually long, compared to the average.
+func (t DurationTrend) Slower() bool {
+	return t.Ratio() >= trendAnomaly
+}
+
+// Faster reports whether the build was unusually quick, compared to the average.
+func (t DurationTrend) Faster() bool {
+	return t.Ratio() <= 1/trendAnomaly
+}
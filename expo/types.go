@@ -1,12 +1,17 @@
 package expo
 
-import "strings"
+import (
+	"net/url"
+	"strings"
+	"time"
+)
 
 type Platform string
 
 const (
 	PlatformAndroid Platform = "android"
 	PlatformIOS     Platform = "ios"
+	PlatformWeb     Platform = "web"
 )
 
 func (p Platform) Equal(other Platform) bool {
@@ -28,6 +33,7 @@ func (p Status) Equal(other Status) bool {
 type Error struct {
 	Message   string `json:"message"`
 	ErrorCode string `json:"errorCode"`
+	DocsUrl   string `json:"docsUrl"`
 }
 
 func (e Error) Failed() bool {
@@ -44,21 +50,101 @@ type Build struct {
 	Platform  Platform `json:"platform"`
 	Error     Error    `json:"error"`
 	CreatedAt string   `json:"createdAt"`
+	// Distribution is how the build is installed, like store or internal, and BuildProfile is the eas.json
+	// profile that produced it.
+	Distribution string `json:"distribution"`
+	BuildProfile string `json:"buildProfile"`
+	// InitiatingActor is whoever started the build.
+	InitiatingActor Actor `json:"initiatingActor"`
+	// Metrics are only reported for builds that ran.
+	Metrics *BuildMetrics `json:"metrics"`
+	// LogFiles are signed URLs for the build's logs, which FetchLogTail reads.
+	LogFiles []string `json:"logFiles"`
+	// RuntimeVersion is the runtime the build runs updates for, which is the fingerprint of its native code
+	// for projects using the fingerprint policy.
+	RuntimeVersion string `json:"runtimeVersion"`
+	// Artifacts are only uploaded by builds that finished.
+	Artifacts *BuildArtifacts `json:"artifacts"`
+	// GitRef is the ref the build was made from, like refs/heads/main, when Expo knows it.
+	GitRef string `json:"gitRef"`
 
 	BuildVersionMetadata `json:",inline"`
 }
 
+// BuildArtifacts are what a build produced.
+type BuildArtifacts struct {
+	// BuildUrl is the app to install, like an IPA, APK or AAB.
+	BuildUrl string `json:"buildUrl"`
+	// ApplicationArchiveUrl is set instead for builds uploading an archive of the app.
+	ApplicationArchiveUrl string `json:"applicationArchiveUrl"`
+}
+
+// App is the URL of the app the build produced, or empty if it produced none.
+func (a *BuildArtifacts) App() string {
+	if a == nil {
+		return ""
+	}
+	if a.BuildUrl != "" {
+		return a.BuildUrl
+	}
+	return a.ApplicationArchiveUrl
+}
+
+// BuildMetrics are how long a build spent on each stage, in milliseconds, as Expo reports them.
+type BuildMetrics struct {
+	BuildWaitTime  int64 `json:"buildWaitTime"`
+	BuildQueueTime int64 `json:"buildQueueTime"`
+	BuildDuration  int64 `json:"buildDuration"`
+}
+
+// Wait is how long the build waited before it was queued, like for a concurrency slot.
+func (m BuildMetrics) Wait() time.Duration {
+	return time.Duration(m.BuildWaitTime) * time.Millisecond
+}
+
+// Queue is how long the build was queued for before a worker picked it up.
+func (m BuildMetrics) Queue() time.Duration {
+	return time.Duration(m.BuildQueueTime) * time.Millisecond
+}
+
+// Duration is how long the build ran for on its worker.
+func (m BuildMetrics) Duration() time.Duration {
+	return time.Duration(m.BuildDuration) * time.Millisecond
+}
+
+// Actor is a user or robot acting in Expo. Email is only known for users, and IsManagedByGitHubApp only for
+// robots.
+type Actor struct {
+	// Type is the GraphQL type of the actor, like User, SSOUser or Robot.
+	Type                 string `json:"__typename"`
+	Id                   string `json:"id"`
+	DisplayName          string `json:"displayName"`
+	Email                string `json:"email"`
+	IsManagedByGitHubApp bool   `json:"isManagedByGitHubApp"`
+}
+
+// IsRobot reports whether the actor is a robot rather than a person, like the GitHub app building on pushes.
+func (a Actor) IsRobot() bool {
+	return a.Type == "Robot"
+}
+
 type BuildVersionMetadata struct {
-	Channel         string `json:"channel"`
-	AppVersion      string `json:"appVersion"`
-	AppBuildVersion string `json:"appBuildVersion"`
-	GitCommitHash   string `json:"gitCommitHash"`
+	Channel          string `json:"channel"`
+	AppVersion       string `json:"appVersion"`
+	AppBuildVersion  string `json:"appBuildVersion"`
+	GitCommitHash    string `json:"gitCommitHash"`
+	GitCommitMessage string `json:"gitCommitMessage"`
+	// IsGitWorkingTreeDirty builds had uncommitted changes, so they may not match their commit.
+	IsGitWorkingTreeDirty bool `json:"isGitWorkingTreeDirty"`
 }
 
 type UpdateChannel struct {
 	Id             string         `json:"id"`
 	Name           string         `json:"name"`
 	UpdateBranches []UpdateBranch `json:"updateBranches"`
+	// BranchMapping is the JSON describing which clients get updates from which of the branches, which
+	// BranchesByAudience reads.
+	BranchMapping string `json:"branchMapping"`
 }
 
 type UpdateBranch struct {
@@ -74,6 +160,38 @@ type Update struct {
 	GitCommitHash string         `json:"gitCommitHash"`
 	Branch        BranchFragment `json:"branch"`
 	CreatedAt     string         `json:"createdAt"`
+	// IsRollBackToEmbedded updates send clients back to the update embedded in their build, so they
+	// don't have a commit of their own.
+	IsRollBackToEmbedded bool `json:"isRollBackToEmbedded"`
+
+	RuntimeVersion string `json:"runtimeVersion"`
+	Message        string `json:"message"`
+	// ManifestFragment is the JSON describing the update's assets, which republishing it reuses.
+	ManifestFragment string `json:"manifestFragment"`
+	// RolloutPercentage is the share of users an update being rolled out gradually reaches, and nil once it
+	// reaches everyone. Meanwhile, everyone else gets the RolloutControlUpdate.
+	RolloutPercentage    *int             `json:"rolloutPercentage"`
+	RolloutControlUpdate *UpdateReference `json:"rolloutControlUpdate"`
+	// CodeSigningInfo is nil for updates published without code signing.
+	CodeSigningInfo *CodeSigningInfo `json:"codeSigningInfo"`
+}
+
+// CodeSigningInfo is the signature an update was published with, which apps configured for code signing
+// check before loading it.
+type CodeSigningInfo struct {
+	KeyId string `json:"keyid"`
+	Sig   string `json:"sig"`
+	Alg   string `json:"alg"`
+}
+
+// Signed reports whether the update was published with code signing.
+func (u Update) Signed() bool {
+	return u.CodeSigningInfo != nil && u.CodeSigningInfo.Sig != ""
+}
+
+// UpdateReference identifies an update.
+type UpdateReference struct {
+	Id string `json:"id"`
 }
 
 type BranchFragment struct {
@@ -82,12 +200,58 @@ type BranchFragment struct {
 }
 
 type Submission struct {
-	Id string `json:"id"`
-	App App `json:"app"`
-	SubmittedBuild Build `json:"submittedBuild"`
+	Id             string   `json:"id"`
+	Status         Status   `json:"status"`
+	Platform       Platform `json:"platform"`
+	CreatedAt      string   `json:"createdAt"`
+	App            App      `json:"app"`
+	SubmittedBuild Build    `json:"submittedBuild"`
+	// InitiatingActor is whoever started the submission.
+	InitiatingActor Actor `json:"initiatingActor"`
 }
 
 type App struct {
-	Id string `json:"id"`
+	Id      string `json:"id"`
+	Name    string `json:"name"`
+	IconUrl string `json:"iconUrl"`
+	// Slug and OwnerAccount locate the app's project on expo.dev.
+	Slug         string  `json:"slug"`
+	OwnerAccount Account `json:"ownerAccount"`
+	// GithubRepository is the repository linked to the app in Expo, if any.
+	GithubRepository *GithubRepository `json:"githubRepository"`
+}
+
+// GithubRepository is a GitHub repository linked to an Expo app.
+type GithubRepository struct {
+	GithubRepositoryUrl string `json:"githubRepositoryUrl"`
+}
+
+// Repository is the GitHub repository linked to the app in Expo, as owner/name, or empty when the app or its
+// repository isn't known.
+func (a *App) Repository() string {
+	if a == nil || a.GithubRepository == nil {
+		return ""
+	}
+	parsed, err := url.Parse(a.GithubRepository.GithubRepositoryUrl)
+	if err != nil || parsed.Host != "github.com" {
+		return ""
+	}
+	repository := strings.TrimSuffix(strings.Trim(parsed.Path, "/"), ".git")
+	if strings.Count(repository, "/") != 1 {
+		return ""
+	}
+	return repository
+}
+
+// Account is the Expo account, personal or an organization's, that owns an app.
+type Account struct {
 	Name string `json:"name"`
-}
\ No newline at end of file
+}
+
+// Project is the app's project on expo.dev, or DefaultProject when the app or where it lives isn't known.
+func (a *App) Project() Project {
+	if a == nil || a.OwnerAccount.Name == "" || a.Slug == "" {
+		return DefaultProject
+	}
+	return Project{Account: a.OwnerAccount.Name, Slug: a.Slug}
+}
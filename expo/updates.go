@@ -1,14 +1,9 @@
 package expo
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
-	"log"
-	"net/http"
-	"os"
+	"time"
 )
 
 type updateChannelVariables struct {
@@ -30,7 +25,17 @@ type updateChannelResponse struct {
 }
 
 func (c *Client) FetchUpdateChannel(ctx context.Context, projectId, channel string) (*UpdateChannel, error) {
-	log.Printf("Fetching update channel %s for app %s", channel, projectId)
+	logger := c.logger().With("app_id", projectId, "channel", channel)
+	cacheKey := projectId + "/" + channel
+	cached, ok, err := c.UpdateChannelCache.Get(ctx, cacheKey)
+	if err != nil {
+		logger.Warn("failed to read update channel cache", "error", err)
+	}
+	if ok {
+		logger.Info("using cached update channel", "channel_id", cached.Id)
+		return cached, nil
+	}
+	logger.Info("fetching update channel")
 	query := graphQLQuery[updateChannelVariables]{
 		OperationName: updateChannelOperation,
 		Query:         updateChannelQuery,
@@ -40,44 +45,14 @@ func (c *Client) FetchUpdateChannel(ctx context.Context, projectId, channel stri
 		},
 	}
 
-	payload, err := json.Marshal(query)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal payload: %w", err)
-	}
-
-	req, err := http.NewRequestWithContext(ctx, "POST", expoAPIURL, bytes.NewBuffer(payload))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
-	}
-	req.Header.Set("accept", "application/graphql-response+json")
-	req.Header.Add("accept", "application/graphql+json")
-	req.Header.Add("accept", "application/json")
-	req.Header.Add("authorization", "bearer "+c.Token)
-	req.Header.Add("content-type", "application/json")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch update channel: %v", err)
-	}
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("failed to read response: %v", err)
-	}
-	if err := resp.Body.Close(); err != nil {
-		log.Printf("failed to close response body: %v", err)
-	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to fetch update channel: %d: %s", resp.StatusCode, string(body))
-	}
-	if _, debug := os.LookupEnv("DEBUG"); debug {
-		log.Printf("response body: %s", string(body))
-	}
-
 	var parsed updateChannelResponse
-	if err := json.Unmarshal(body, &parsed); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %v", err)
+	if err := execute(ctx, c, query, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to fetch update channel: %w", err)
+	}
+	logger.Info("resolved update channel", "channel_id", parsed.Data.App.ById.UpdateChannelByName.Id)
+	if err := c.UpdateChannelCache.Set(ctx, cacheKey, &parsed.Data.App.ById.UpdateChannelByName); err != nil {
+		logger.Warn("failed to cache update channel", "error", err)
 	}
-	log.Printf("Resolved update channel %s for app %s to %s", channel, projectId, parsed.Data.App.ById.UpdateChannelByName.Id)
 	return &parsed.Data.App.ById.UpdateChannelByName, nil
 }
 
@@ -104,7 +79,8 @@ type updateResponse struct {
 }
 
 func (c *Client) FetchUpdates(ctx context.Context, projectId, branch string, limit, offset int) ([][]Update, error) {
-	log.Printf("Fetching %d+%d updates for branch %s for app %s", offset, limit, branch, projectId)
+	logger := c.logger().With("app_id", projectId, "branch", branch)
+	logger.Info("fetching update groups", "offset", offset, "limit", limit)
 	query := graphQLQuery[updateVariables]{
 		OperationName: updateOperation,
 		Query:         updateQuery,
@@ -116,43 +92,56 @@ func (c *Client) FetchUpdates(ctx context.Context, projectId, branch string, lim
 		},
 	}
 
-	payload, err := json.Marshal(query)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal payload: %w", err)
-	}
-
-	req, err := http.NewRequestWithContext(ctx, "POST", expoAPIURL, bytes.NewBuffer(payload))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
-	}
-	req.Header.Set("accept", "application/graphql-response+json")
-	req.Header.Add("accept", "application/graphql+json")
-	req.Header.Add("accept", "application/json")
-	req.Header.Add("authorization", "bearer "+c.Token)
-	req.Header.Add("content-type", "application/json")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch update channel: %v", err)
-	}
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("failed to read response: %v", err)
-	}
-	if err := resp.Body.Close(); err != nil {
-		log.Printf("failed to close response body: %v", err)
-	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to fetch update channel: %d: %s", resp.StatusCode, string(body))
-	}
-	if _, debug := os.LookupEnv("DEBUG"); debug {
-		log.Printf("response body: %s", string(body))
-	}
-
 	var parsed updateResponse
-	if err := json.Unmarshal(body, &parsed); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %v", err)
+	if err := execute(ctx, c, query, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to fetch updates: %w", err)
 	}
-	log.Printf("Fetched %d update groups for branch %s for app %s", len(parsed.Data.App.ById.UpdateBranchByName.UpdateGroups), branch, projectId)
+	logger.Info("fetched update groups", "count", len(parsed.Data.App.ById.UpdateBranchByName.UpdateGroups))
 	return parsed.Data.App.ById.UpdateBranchByName.UpdateGroups, nil
 }
+
+// updatePageSize is the number of update groups we fetch from Expo at once.
+const updatePageSize = 10
+
+// FetchPreviousUpdate finds the update for the platform that was published on the branch before the
+// given time, skipping the update with the given id. Nil is returned if there's none in the most recent
+// update groups, up to the client's MaxSearched.
+func (c *Client) FetchPreviousUpdate(ctx context.Context, projectId, branch string, platform Platform, id string, before time.Time) (*Update, error) {
+	// the previous update may not be in the most recent page of update groups, so we walk back until we find it
+	for offset := 0; offset < c.MaxSearched(); offset += updatePageSize {
+		updates, err := c.FetchUpdates(ctx, projectId, branch, updatePageSize, offset)
+		if err != nil {
+			return nil, err
+		}
+		previous, err := PreviousUpdate(platform, id, before, updates)
+		if err != nil || previous != nil {
+			return previous, err
+		}
+		if len(updates) < updatePageSize {
+			return nil, nil
+		}
+	}
+	c.logger().Warn("did not find a previous update in the most recent update groups", "branch", branch, "platform", platform, "searched", c.MaxSearched())
+	return nil, nil
+}
+
+// PreviousUpdate finds the most recent update for the platform that was published no later than the given
+// time, skipping the update with the given id. Update groups are expected newest first, as Expo lists them.
+func PreviousUpdate(platform Platform, id string, before time.Time, updates [][]Update) (*Update, error) {
+	for i := 0; i < len(updates); i++ {
+		for j := 0; j < len(updates[i]); j++ {
+			if !updates[i][j].Platform.Equal(platform) || updates[i][j].Id == id {
+				continue
+			}
+			updateCreatedAt, err := time.Parse(time.RFC3339, updates[i][j].CreatedAt)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse createdAt for update %s: %v", updates[i][j].Id, err)
+			}
+			if updateCreatedAt.After(before) {
+				continue
+			}
+			return &updates[i][j], nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,98 @@
+package expo_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/NWACus/expo-slack-webhook/expo"
+	"github.com/NWACus/expo-slack-webhook/expo/expotest"
+)
+
+// updateGroups serves a branch with the number of update groups, newest first, the group at each index
+// having been published index minutes before now, for iOS and Android. It records the offsets requested.
+func updateGroups(server *expotest.Server, groups int, now time.Time, offsets *[]int) {
+	server.RespondFunc("ViewUpdateGroupsOnBranch", func(raw json.RawMessage) []byte {
+		var variables struct {
+			Limit  int `json:"limit"`
+			Offset int `json:"offset"`
+		}
+		if err := json.Unmarshal(raw, &variables); err != nil {
+			panic(fmt.Sprintf("failed to unmarshal variables: %v", err))
+		}
+		*offsets = append(*offsets, variables.Offset)
+		page := [][]expo.Update{}
+		for i := variables.Offset; i < groups && i < variables.Offset+variables.Limit; i++ {
+			createdAt := now.Add(-time.Duration(i) * time.Minute).Format(time.RFC3339)
+			page = append(page, []expo.Update{
+				{Id: fmt.Sprintf("ios-%d", i), Group: fmt.Sprintf("group-%d", i), Platform: expo.PlatformIOS, CreatedAt: createdAt},
+				{Id: fmt.Sprintf("android-%d", i), Group: fmt.Sprintf("group-%d", i), Platform: expo.PlatformAndroid, CreatedAt: createdAt},
+			})
+		}
+		var response struct {
+			Data struct {
+				App struct {
+					ById struct {
+						UpdateBranchByName struct {
+							UpdateGroups [][]expo.Update `json:"updateGroups"`
+						} `json:"updateBranchByName"`
+					} `json:"byId"`
+				} `json:"app"`
+			} `json:"data"`
+		}
+		response.Data.App.ById.UpdateBranchByName.UpdateGroups = page
+		raw, err := json.Marshal(response)
+		if err != nil {
+			panic(fmt.Sprintf("failed to marshal updates: %v", err))
+		}
+		return raw
+	})
+}
+
+func TestFetchPreviousUpdate(t *testing.T) {
+	now := time.Date(2025, 3, 12, 15, 49, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name string
+		// groups is how many update groups the branch has; current is the index of the one we're
+		// notified about, so that those before it were published since.
+		groups, current int
+		searchLimit     int
+		expected        string
+		offsets         []int
+	}{
+		{name: "previous group", groups: 5, current: 0, expected: "ios-1", offsets: []int{0}},
+		{name: "skips updates published since", groups: 5, current: 3, expected: "ios-4", offsets: []int{0}},
+		{name: "pages back", groups: 30, current: 12, expected: "ios-13", offsets: []int{0, 10}},
+		{name: "previous on the next page", groups: 30, current: 9, expected: "ios-10", offsets: []int{0, 10}},
+		{name: "first update", groups: 15, current: 14, offsets: []int{0, 10}},
+		{name: "stops at the search limit", groups: 100, current: 25, searchLimit: 20, offsets: []int{0, 10}},
+		{name: "searches up to the limit", groups: 100, current: 25, searchLimit: 30, expected: "ios-26", offsets: []int{0, 10, 20}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server := expotest.NewServer()
+			defer server.Close()
+			var offsets []int
+			updateGroups(server, tc.groups, now, &offsets)
+			client := server.Client()
+			client.SearchLimit = tc.searchLimit
+
+			before := now.Add(-time.Duration(tc.current) * time.Minute)
+			previous, err := client.FetchPreviousUpdate(context.Background(), appId, "preview", expo.PlatformIOS, fmt.Sprintf("ios-%d", tc.current), before)
+			if err != nil {
+				t.Fatalf("failed to fetch previous update: %v", err)
+			}
+			var got string
+			if previous != nil {
+				got = previous.Id
+			}
+			if got != tc.expected {
+				t.Errorf("expected previous update %q, got %q", tc.expected, got)
+			}
+			if fmt.Sprint(offsets) != fmt.Sprint(tc.offsets) {
+				t.Errorf("expected offsets %v to be requested, got %v", tc.offsets, offsets)
+			}
+		})
+	}
+}
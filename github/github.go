@@ -0,0 +1,400 @@
+// Package github looks up the commits between builds in GitHub, and the pull requests they were merged in,
+// so that messages can say what changed without a trip to the compare page, reports builds on the commits and
+// pull requests they were made from, and files issues about builds that keep failing.
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// githubAPIURL is GitHub's REST API.
+const githubAPIURL = "https://api.github.com"
+
+// Client talks to GitHub's REST API. A nil *Client is valid and looks nothing up.
+type Client struct {
+	// Token authenticates requests, which is needed for private repositories and raises the rate limit; public
+	// repositories can be read without one.
+	Token  string
+	Logger *slog.Logger
+
+	// BaseURL and HTTPClient default to GitHub's API and http.DefaultClient.
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+func (c *Client) logger() *slog.Logger {
+	if c.Logger == nil {
+		return slog.Default()
+	}
+	return c.Logger
+}
+
+func (c *Client) baseURL() string {
+	if c.BaseURL == "" {
+		return githubAPIURL
+	}
+	return c.BaseURL
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return c.HTTPClient
+}
+
+// Commit is a commit as we show it in a changelog.
+type Commit struct {
+	SHA     string
+	Message string
+	URL     string
+	// PullRequest is the pull request the commit was merged in, if it was and we looked it up.
+	PullRequest *PullRequest
+}
+
+// PullRequest is a pull request, whose title usually says more about a change than its commits.
+type PullRequest struct {
+	Number int
+	Title  string
+	URL    string
+}
+
+// Changelog is the commits made between two others, newest first.
+type Changelog struct {
+	Commits []Commit
+	// More is how many commits were made besides those listed.
+	More int
+	// Messages are those of all of the commits GitHub listed, not just the ones we did.
+	Messages []string
+}
+
+// Texts are what was written about the changes: the commit messages, and the titles of the pull requests
+// they were merged in, for finding the issues they mention. A nil *Changelog has none.
+func (c *Changelog) Texts() []string {
+	if c == nil {
+		return nil
+	}
+	texts := append([]string{}, c.Messages...)
+	for _, commit := range c.Commits {
+		if commit.PullRequest != nil {
+			texts = append(texts, commit.PullRequest.Title)
+		}
+	}
+	return texts
+}
+
+type compareResponse struct {
+	TotalCommits int `json:"total_commits"`
+	Commits      []struct {
+		SHA     string `json:"sha"`
+		HTMLURL string `json:"html_url"`
+		Commit  struct {
+			Message string `json:"message"`
+		} `json:"commit"`
+	} `json:"commits"`
+}
+
+// Compare lists up to limit of the most recent commits made after base up to and including head, in the
+// repository given as owner/name, with the messages of the rest. Nil is returned when there were none.
+func (c *Client) Compare(ctx context.Context, repository, base, head string, limit int) (*Changelog, error) {
+	if c == nil {
+		return nil, nil
+	}
+	var compared compareResponse
+	if err := c.get(ctx, fmt.Sprintf("/repos/%s/compare/%s...%s", repository, base, head), &compared); err != nil {
+		return nil, fmt.Errorf("failed to compare commits: %w", err)
+	}
+	if len(compared.Commits) == 0 {
+		return nil, nil
+	}
+	// GitHub lists commits oldest first
+	changelog := &Changelog{}
+	for i := len(compared.Commits) - 1; i >= 0 && len(changelog.Commits) < limit; i-- {
+		commit := compared.Commits[i]
+		changelog.Commits = append(changelog.Commits, Commit{SHA: commit.SHA, Message: commit.Commit.Message, URL: commit.HTMLURL})
+	}
+	for _, commit := range compared.Commits {
+		changelog.Messages = append(changelog.Messages, commit.Commit.Message)
+	}
+	changelog.More = max(compared.TotalCommits, len(compared.Commits)) - len(changelog.Commits)
+	return changelog, nil
+}
+
+type pullsResponse []struct {
+	Number   int     `json:"number"`
+	Title    string  `json:"title"`
+	HTMLURL  string  `json:"html_url"`
+	MergedAt *string `json:"merged_at"`
+}
+
+// PullRequests finds the merged pull request each of the changelog's commits came from, and drops commits
+// from a pull request already listed, so that each is listed once. Commits not merged from a pull request are
+// kept as they are, as are those we couldn't look up, whose errors are returned together.
+func (c *Client) PullRequests(ctx context.Context, repository string, changelog *Changelog) error {
+	if c == nil || changelog == nil {
+		return nil
+	}
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, len(changelog.Commits))
+	)
+	for i := range changelog.Commits {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			commit := &changelog.Commits[i]
+			var pulls pullsResponse
+			if err := c.get(ctx, fmt.Sprintf("/repos/%s/commits/%s/pulls", repository, commit.SHA), &pulls); err != nil {
+				errs[i] = fmt.Errorf("failed to find pull request for %s: %w", commit.SHA, err)
+				return
+			}
+			for _, pull := range pulls {
+				if pull.MergedAt != nil {
+					commit.PullRequest = &PullRequest{Number: pull.Number, Title: pull.Title, URL: pull.HTMLURL}
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	listed := map[int]bool{}
+	commits := changelog.Commits[:0]
+	for _, commit := range changelog.Commits {
+		if commit.PullRequest != nil {
+			if listed[commit.PullRequest.Number] {
+				continue
+			}
+			listed[commit.PullRequest.Number] = true
+		}
+		commits = append(commits, commit)
+	}
+	changelog.Commits = commits
+	return errors.Join(errs...)
+}
+
+// OpenPullRequest finds the open pull request from the branch to the repository given as owner/name, from
+// the repository itself rather than a fork. Nil is returned when there's none.
+func (c *Client) OpenPullRequest(ctx context.Context, repository, branch string) (*PullRequest, error) {
+	if c == nil {
+		return nil, nil
+	}
+	owner, _, _ := strings.Cut(repository, "/")
+	var pulls pullsResponse
+	if err := c.get(ctx, fmt.Sprintf("/repos/%s/pulls?state=open&head=%s", repository, url.QueryEscape(owner+":"+branch)), &pulls); err != nil {
+		return nil, fmt.Errorf("failed to find pull request for %s: %w", branch, err)
+	}
+	if len(pulls) == 0 {
+		return nil, nil
+	}
+	return &PullRequest{Number: pulls[0].Number, Title: pulls[0].Title, URL: pulls[0].HTMLURL}, nil
+}
+
+// State is the state of a commit status.
+type State string
+
+const (
+	StatePending State = "pending"
+	StateSuccess State = "success"
+	StateFailure State = "failure"
+	StateError   State = "error"
+)
+
+// Status is a commit status, shown next to the commit and on the pull requests it's in. Statuses are told apart
+// by their Context, so setting one with the same Context replaces it.
+type Status struct {
+	State       State  `json:"state"`
+	TargetURL   string `json:"target_url,omitempty"`
+	Description string `json:"description,omitempty"`
+	Context     string `json:"context"`
+}
+
+// SetStatus sets a status on the commit, in the repository given as owner/name. This needs a token with write
+// access to the repository's commit statuses.
+func (c *Client) SetStatus(ctx context.Context, repository, sha string, status Status) error {
+	if c == nil {
+		return nil
+	}
+	if err := c.send(ctx, "POST", fmt.Sprintf("/repos/%s/statuses/%s", repository, sha), status, nil); err != nil {
+		return fmt.Errorf("failed to set commit status: %w", err)
+	}
+	return nil
+}
+
+// Deployment is a commit deployed to an environment, and how that went.
+type Deployment struct {
+	Ref         string
+	Environment string
+	// Production marks the environment as the one users see.
+	Production  bool
+	Description string
+	State       State
+	// URL links to the details of the deployment.
+	URL string
+}
+
+type deploymentRequest struct {
+	Ref                   string   `json:"ref"`
+	Environment           string   `json:"environment"`
+	Description           string   `json:"description,omitempty"`
+	ProductionEnvironment bool     `json:"production_environment"`
+	AutoMerge             bool     `json:"auto_merge"`
+	RequiredContexts      []string `json:"required_contexts"`
+}
+
+type deploymentStatusRequest struct {
+	State       State  `json:"state"`
+	LogURL      string `json:"log_url,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Deploy records the deployment in the repository given as owner/name, then its state, so that it shows in the
+// repository's environments. The deployment is of what was built, so GitHub isn't asked to merge the default
+// branch into it or to check the commit's statuses first. This needs a token with write access to the
+// repository's deployments.
+func (c *Client) Deploy(ctx context.Context, repository string, deployment Deployment) error {
+	if c == nil {
+		return nil
+	}
+	var created struct {
+		Id int64 `json:"id"`
+	}
+	if err := c.send(ctx, "POST", fmt.Sprintf("/repos/%s/deployments", repository), deploymentRequest{
+		Ref:                   deployment.Ref,
+		Environment:           deployment.Environment,
+		Description:           deployment.Description,
+		ProductionEnvironment: deployment.Production,
+		RequiredContexts:      []string{},
+	}, &created); err != nil {
+		return fmt.Errorf("failed to create deployment: %w", err)
+	}
+	if err := c.send(ctx, "POST", fmt.Sprintf("/repos/%s/deployments/%d/statuses", repository, created.Id), deploymentStatusRequest{
+		State:       deployment.State,
+		LogURL:      deployment.URL,
+		Description: deployment.Description,
+	}, nil); err != nil {
+		return fmt.Errorf("failed to set deployment status: %w", err)
+	}
+	return nil
+}
+
+type issueRequest struct {
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body,omitempty"`
+	// State and StateReason are only for updating an issue.
+	State       string `json:"state,omitempty"`
+	StateReason string `json:"state_reason,omitempty"`
+}
+
+// OpenIssue opens an issue in the repository given as owner/name, returning its number. This, and commenting
+// on and closing issues, needs a token with write access to the repository's issues.
+func (c *Client) OpenIssue(ctx context.Context, repository, title, body string) (int, error) {
+	if c == nil {
+		return 0, nil
+	}
+	var opened struct {
+		Number int `json:"number"`
+	}
+	if err := c.send(ctx, "POST", fmt.Sprintf("/repos/%s/issues", repository), issueRequest{Title: title, Body: body}, &opened); err != nil {
+		return 0, fmt.Errorf("failed to open issue: %w", err)
+	}
+	return opened.Number, nil
+}
+
+// Comment comments on the issue or pull request with the number in the repository given as owner/name,
+// returning the comment's id.
+func (c *Client) Comment(ctx context.Context, repository string, number int, body string) (int64, error) {
+	if c == nil {
+		return 0, nil
+	}
+	var comment struct {
+		Id int64 `json:"id"`
+	}
+	if err := c.send(ctx, "POST", fmt.Sprintf("/repos/%s/issues/%d/comments", repository, number), issueRequest{Body: body}, &comment); err != nil {
+		return 0, fmt.Errorf("failed to comment on issue: %w", err)
+	}
+	return comment.Id, nil
+}
+
+// EditComment replaces the body of the comment with the id in the repository given as owner/name.
+func (c *Client) EditComment(ctx context.Context, repository string, id int64, body string) error {
+	if c == nil {
+		return nil
+	}
+	if err := c.send(ctx, "PATCH", fmt.Sprintf("/repos/%s/issues/comments/%d", repository, id), issueRequest{Body: body}, nil); err != nil {
+		return fmt.Errorf("failed to edit comment: %w", err)
+	}
+	return nil
+}
+
+// CloseIssue closes the issue with the number in the repository given as owner/name, as completed.
+func (c *Client) CloseIssue(ctx context.Context, repository string, number int) error {
+	if c == nil {
+		return nil
+	}
+	if err := c.send(ctx, "PATCH", fmt.Sprintf("/repos/%s/issues/%d", repository, number), issueRequest{State: "closed", StateReason: "completed"}, nil); err != nil {
+		return fmt.Errorf("failed to close issue: %w", err)
+	}
+	return nil
+}
+
+// get fetches the API path and unmarshals the response into out.
+func (c *Client) get(ctx context.Context, path string, out any) error {
+	return c.do(ctx, "GET", path, nil, out)
+}
+
+// send sends the body to the API path as JSON with the method and unmarshals the response into out, unless
+// it's nil.
+func (c *Client) send(ctx context.Context, method, path string, body, out any) error {
+	marshalled, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %v", err)
+	}
+	return c.do(ctx, method, path, bytes.NewReader(marshalled), out)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body io.Reader, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL()+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("accept", "application/vnd.github+json")
+	if body != nil {
+		req.Header.Set("content-type", "application/json")
+	}
+	req.Header.Set("x-github-api-version", "2022-11-28")
+	if c.Token != "" {
+		req.Header.Set("authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			c.logger().Error("failed to close response body", "error", err)
+		}
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %v", err)
+	}
+	return nil
+}
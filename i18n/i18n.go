@@ -0,0 +1,87 @@
+// Package i18n translates the human-readable text of our messages. As with gettext, text is looked up by
+// its English, so that anything a catalog doesn't translate falls back to English rather than going missing.
+package i18n
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultLocale is the locale messages are written in when no other is configured; it needs no catalog.
+const DefaultLocale = "en"
+
+// Catalog holds the translations for one locale, keyed by the English text, including any formatting verbs:
+//
+//	"%s build of %s %s %s.": "%s-Build von %s %s %s."
+//
+// A nil *Catalog leaves text in English.
+type Catalog struct {
+	Locale   string
+	messages map[string]string
+}
+
+// New creates a catalog for the locale from its translations.
+func New(locale string, messages map[string]string) *Catalog {
+	return &Catalog{Locale: locale, messages: messages}
+}
+
+// Load reads the catalog for the locale from the YAML or JSON file at path, which maps each locale to its
+// translations, so that one file can hold several:
+//
+//	de:
+//	  succeeded: erfolgreich
+//
+// Nil is returned for the default locale, for which nothing needs translating.
+func Load(path, locale string) (*Catalog, error) {
+	if locale == "" || locale == DefaultLocale {
+		return nil, nil
+	}
+	if path == "" {
+		return nil, fmt.Errorf("locale %s needs a message catalog", locale)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message catalogs: %v", err)
+	}
+	var catalogs map[string]map[string]string
+	if err := yaml.Unmarshal(raw, &catalogs); err != nil {
+		return nil, fmt.Errorf("failed to parse message catalogs: %v", err)
+	}
+	messages, ok := catalogs[locale]
+	if !ok {
+		var locales []string
+		for name := range catalogs {
+			locales = append(locales, name)
+		}
+		slices.Sort(locales)
+		return nil, fmt.Errorf("no message catalog for locale %s, only for %s", locale, strings.Join(locales, ", "))
+	}
+	return New(locale, messages), nil
+}
+
+// T translates the English text and formats it with the arguments, as fmt.Sprintf does. Text without
+// arguments isn't formatted, so it may hold a literal %.
+func (c *Catalog) T(text string, args ...any) string {
+	if c != nil {
+		if translated, ok := c.messages[text]; ok && translated != "" {
+			text = translated
+		}
+	}
+	if len(args) == 0 {
+		return text
+	}
+	return fmt.Sprintf(text, args...)
+}
+
+// Plural translates whichever of the singular and plural English text suits n, which both are formatted
+// with. Catalogs translate each form on its own.
+func (c *Catalog) Plural(n int, singular, plural string) string {
+	if n == 1 {
+		return c.T(singular, n)
+	}
+	return c.T(plural, n)
+}
@@ -0,0 +1,56 @@
+// Package install attaches QR codes to the messages for internal distribution builds, so that testers can
+// install a build by scanning the code on their device rather than finding the build on expo.dev.
+package install
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/skip2/go-qrcode"
+	"github.com/slack-go/slack"
+)
+
+// scale is how many pixels across each module of the code is drawn, which makes a version 6 code, enough for
+// a build's details page, around 400 pixels square.
+const scale = 8
+
+// QRCodes uploads QR codes with a bot token, which needs the files:write scope. A nil *QRCodes is valid and
+// uploads nothing.
+type QRCodes struct {
+	client *slack.Client
+}
+
+// NewQRCodes creates an uploader for QR codes.
+func NewQRCodes(client *slack.Client) *QRCodes {
+	return &QRCodes{client: client}
+}
+
+// Attach uploads a QR code linking to the URL in reply to the message with the timestamp. Slack only takes
+// uploads for channels given by ID.
+func (q *QRCodes) Attach(ctx context.Context, channel, ts, url, title string) error {
+	if q == nil {
+		return nil
+	}
+	code, err := qrcode.New(url, qrcode.Medium)
+	if err != nil {
+		return fmt.Errorf("failed to encode QR code: %v", err)
+	}
+	// a negative size sets the pixels per module rather than the size of the image
+	image, err := code.PNG(-scale)
+	if err != nil {
+		return fmt.Errorf("failed to draw QR code: %v", err)
+	}
+	if _, err := q.client.UploadFileV2Context(ctx, slack.UploadFileV2Parameters{
+		Reader:          bytes.NewReader(image),
+		FileSize:        len(image),
+		Filename:        "install.png",
+		Title:           title,
+		AltTxt:          "QR code linking to " + url,
+		Channel:         channel,
+		ThreadTimestamp: ts,
+	}); err != nil {
+		return fmt.Errorf("failed to upload QR code: %v", err)
+	}
+	return nil
+}
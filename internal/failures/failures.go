@@ -0,0 +1,159 @@
+// Package failures counts the builds in a row that failed on a channel for a platform, and files a GitHub issue
+// once there have been enough of them, which is closed when a build succeeds again.
+package failures
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/NWACus/expo-slack-webhook/config"
+	"github.com/NWACus/expo-slack-webhook/expo"
+)
+
+// listed is how many of the most recent failed builds we keep, to list in the issue.
+const listed = 10
+
+// Build is a build that failed, as we list it in an issue.
+type Build struct {
+	URL     string `json:"url"`
+	Version string `json:"version"`
+	// Commit is the full hash of the commit built, which GitHub links to.
+	Commit string `json:"commit,omitempty"`
+	// Error is what Expo said went wrong, and Diagnosis what we make of it, if anything.
+	Error     expo.Error      `json:"error"`
+	Diagnosis *expo.Diagnosis `json:"diagnosis,omitempty"`
+}
+
+// streak is the builds that failed in a row, and the issue filed about them, if there is one yet.
+type streak struct {
+	// Failures counts all of the failed builds, of which Builds are the most recent, oldest first.
+	Failures int     `json:"failures"`
+	Builds   []Build `json:"builds"`
+	// Repository is where Issue was filed, as owner/name, so that it's closed there even if the app has
+	// since been linked to another.
+	Repository string `json:"repository,omitempty"`
+	Issue      int    `json:"issue,omitempty"`
+}
+
+// Failed counts the build towards the streak of failures under the key, which identifies the channel and
+// platform, opening an issue in the repository given as owner/name once cfg.FailureIssueThreshold builds
+// have failed in a row, and commenting on it with later failures. The title describes what's failing, like
+// "Android builds of Avalanche Forecast on preview keep failing".
+func Failed(ctx context.Context, cfg *config.Config, logger *slog.Logger, key, repository, title string, build Build) error {
+	if cfg.FailureIssueThreshold == 0 || cfg.Store == nil {
+		return nil
+	}
+	s, err := load(ctx, cfg, key)
+	if err != nil {
+		return err
+	}
+	s.Failures++
+	s.Builds = append(s.Builds, build)
+	if len(s.Builds) > listed {
+		s.Builds = s.Builds[len(s.Builds)-listed:]
+	}
+
+	switch {
+	case s.Issue != 0:
+		if _, err := cfg.GitHub.Comment(ctx, s.Repository, s.Issue, "Another build failed:\n\n"+item(build)); err != nil {
+			logger.Warn("failed to comment on the issue for the failures", "issue", s.Issue, "error", err)
+		}
+	case s.Failures >= cfg.FailureIssueThreshold:
+		number, err := cfg.GitHub.OpenIssue(ctx, repository, title, body(s))
+		if err != nil {
+			// we'll try again with the next failure
+			logger.Warn("failed to open an issue for the failures", "error", err)
+			break
+		}
+		logger.Info("opened an issue for the failures", "repository", repository, "issue", number)
+		s.Repository, s.Issue = repository, number
+	}
+	return save(ctx, cfg, key, s)
+}
+
+// Succeeded ends the streak of failures under the key, closing its issue, if one was opened, with a link to
+// the build that succeeded.
+func Succeeded(ctx context.Context, cfg *config.Config, logger *slog.Logger, key, url string) error {
+	if cfg.FailureIssueThreshold == 0 || cfg.Store == nil {
+		return nil
+	}
+	s, err := load(ctx, cfg, key)
+	if err != nil {
+		return err
+	}
+	if s.Failures == 0 {
+		return nil
+	}
+	if s.Issue != 0 {
+		if _, err := cfg.GitHub.Comment(ctx, s.Repository, s.Issue, fmt.Sprintf("[A build](%s) succeeded, so this is fixed.", url)); err != nil {
+			logger.Warn("failed to comment on the issue for the failures", "issue", s.Issue, "error", err)
+		}
+		if err := cfg.GitHub.CloseIssue(ctx, s.Repository, s.Issue); err != nil {
+			// the streak is kept, so that closing it is tried again after the next success
+			return err
+		}
+		logger.Info("closed the issue for the failures", "repository", s.Repository, "issue", s.Issue)
+	}
+	return cfg.Store.Delete(ctx, entryKey(key))
+}
+
+// body introduces the issue with the builds that have failed.
+func body(s streak) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "The last %d builds failed.", s.Failures)
+	if s.Failures > len(s.Builds) {
+		fmt.Fprintf(&b, " The most recent %d were:", len(s.Builds))
+	}
+	b.WriteString("\n\n")
+	for _, build := range s.Builds {
+		b.WriteString(item(build))
+	}
+	b.WriteString("\nThis issue will be closed when a build succeeds.\n")
+	return b.String()
+}
+
+// item lists a failed build in Markdown, with what we make of its error.
+func item(build Build) string {
+	line := fmt.Sprintf("- [%s](%s)", build.Version, build.URL)
+	if build.Commit != "" {
+		line += fmt.Sprintf(" of %s", build.Commit)
+	}
+	switch {
+	case build.Diagnosis != nil:
+		line += fmt.Sprintf(": likely cause: %s. Suggested fix: %s. See [the docs](%s).", build.Diagnosis.Cause, build.Diagnosis.Fix, build.Diagnosis.DocsUrl)
+	case build.Error.Message != "":
+		line += fmt.Sprintf(": %s", build.Error.Message)
+	}
+	return line + "\n"
+}
+
+func load(ctx context.Context, cfg *config.Config, key string) (streak, error) {
+	var s streak
+	raw, ok, err := cfg.Store.Get(ctx, entryKey(key))
+	if err != nil {
+		return s, fmt.Errorf("failed to look up failures: %v", err)
+	}
+	if !ok {
+		return s, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &s); err != nil {
+		return s, fmt.Errorf("failed to unmarshal failures: %v", err)
+	}
+	return s, nil
+}
+
+func save(ctx context.Context, cfg *config.Config, key string, s streak) error {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to marshal failures: %v", err)
+	}
+	// a streak lasts until a build succeeds, however long that takes
+	return cfg.Store.Set(ctx, entryKey(key), string(raw), 0)
+}
+
+func entryKey(key string) string {
+	return "failures/" + key
+}
@@ -0,0 +1,177 @@
+// Package messages holds the Slack block construction shared by the webhook handlers.
+package messages
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/slack-go/slack"
+
+	"github.com/NWACus/expo-slack-webhook/config"
+	"github.com/NWACus/expo-slack-webhook/expo"
+	"github.com/NWACus/expo-slack-webhook/i18n"
+	"github.com/NWACus/expo-slack-webhook/templates"
+)
+
+// IconAccessory shows an app icon alongside a section. Slack only renders images it can fetch securely,
+// so nil is returned for anything other than an https URL; a nil accessory is omitted from the block.
+func IconAccessory(iconURL string) *slack.Accessory {
+	parsed, err := url.Parse(iconURL)
+	if iconURL == "" || err != nil || parsed.Scheme != "https" {
+		return nil
+	}
+	return slack.NewAccessory(slack.NewImageBlockElement(iconURL, "app icon"))
+}
+
+// Section renders the named template into a section. Templates can render to nothing to leave their text
+// out, in which case nil is returned.
+func Section(set *templates.Set, name string, data any) (slack.Block, error) {
+	text, err := set.Render(name, data)
+	if err != nil || text == "" {
+		return nil, err
+	}
+	return &slack.SectionBlock{
+		Type: slack.MBTSection,
+		Text: &slack.TextBlockObject{
+			Type: slack.MarkdownType,
+			Text: text,
+		},
+	}, nil
+}
+
+// Context renders each of the named templates into an element of a context block, for details in smaller
+// text than a section's. Templates rendering to nothing are left out, and nil is returned if they all are.
+func Context(set *templates.Set, data any, names ...string) (slack.Block, error) {
+	var elements []slack.MixedElement
+	for _, name := range names {
+		text, err := set.Render(name, data)
+		if err != nil {
+			return nil, err
+		}
+		if text != "" {
+			elements = append(elements, slack.NewTextBlockObject(slack.MarkdownType, text, false, false))
+		}
+	}
+	if len(elements) == 0 {
+		return nil, nil
+	}
+	return slack.NewContextBlock("", elements...), nil
+}
+
+// PreviousUpdate describes the update published before the one for the commit we're notifying about, and
+// links to the changes made since. Rollbacks to the embedded update have no commit, so there's nothing to
+// link to for them. Commits are linked to in the GitHub repository, given as owner/name, and the update in
+// the app's project. The text comes from the update.previous template, and nil is returned if it renders to
+// nothing.
+func PreviousUpdate(set *templates.Set, repository string, project expo.Project, previous *expo.Update, commit string) (slack.Block, error) {
+	return Section(set, "update.previous", struct {
+		Repository string
+		Project    expo.Project
+		Previous   *expo.Update
+		Commit     string
+	}{Repository: repository, Project: project, Previous: previous, Commit: commit})
+}
+
+// Fallback summarises the event in plain text, for Slack to show where the blocks aren't rendered, like in
+// notifications and to screen readers: its title, followed by the error for failures.
+func Fallback(e config.Event) string {
+	if e.Error == "" {
+		return e.Title
+	}
+	return fmt.Sprintf("%s Error %s", e.Title, e.Error)
+}
+
+// Degraded notes which context couldn't be loaded for a message, so that readers know it's incomplete rather
+// than assuming there was nothing to show. Nil is returned when nothing failed.
+func Degraded(catalog *i18n.Catalog, failed ...string) slack.Block {
+	if len(failed) == 0 {
+		return nil
+	}
+	var translated []string
+	for _, context := range failed {
+		translated = append(translated, catalog.T(context))
+	}
+	return slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType, ":warning: "+catalog.T("Couldn't load %s context.", strings.Join(translated, catalog.T(" or "))), false, false))
+}
+
+const (
+	// maxSectionText is the most text Slack takes in a section.
+	maxSectionText = 3000
+	// maxExcerptLine is the longest line of a log excerpt we show, so that one long line can't crowd out the rest.
+	maxExcerptLine = 200
+)
+
+// LogExcerpt shows the end of a failed build's log in a code block, so that the failure can be triaged from
+// Slack. Slack collapses long sections behind "Show more", so the excerpt doesn't take over the channel.
+// Earlier lines are dropped to fit in a section, and nil is returned if there are no lines.
+func LogExcerpt(catalog *i18n.Catalog, lines []string) slack.Block {
+	var shown []string
+	for _, line := range lines {
+		if runes := []rune(line); len(runes) > maxExcerptLine {
+			line = string(runes[:maxExcerptLine]) + "…"
+		}
+		// backticks in the log would end the code block early
+		shown = append(shown, strings.ReplaceAll(line, "```", "'''"))
+	}
+	heading := catalog.T("*End of the build log:*")
+	for ; len(shown) > 0; shown = shown[1:] {
+		text := fmt.Sprintf("%s\n```%s```", heading, strings.Join(shown, "\n"))
+		if len(text) <= maxSectionText {
+			return slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil)
+		}
+	}
+	return nil
+}
+
+// BuildProfile shows which eas.json profile produced a build and how it's distributed, as builds for a
+// release are otherwise hard to tell apart. Empty values are left out, and nil is returned if both are.
+func BuildProfile(catalog *i18n.Catalog, profile, distribution string) slack.Block {
+	var elements []slack.MixedElement
+	if profile != "" {
+		elements = append(elements, slack.NewTextBlockObject(slack.MarkdownType, catalog.T("Profile: `%s`", profile), false, false))
+	}
+	if distribution != "" {
+		// the webhook sends these in lower case, but the GraphQL API in upper case
+		elements = append(elements, slack.NewTextBlockObject(slack.MarkdownType, catalog.T("Distribution: `%s`", strings.ToLower(distribution)), false, false))
+	}
+	if len(elements) == 0 {
+		return nil
+	}
+	return slack.NewContextBlock("", elements...)
+}
+
+const (
+	// PromoteUpdateAction identifies presses of the button offering to promote an update group.
+	PromoteUpdateAction = "promote_update"
+	// RollBackUpdateAction identifies presses of the button offering to roll an update group back.
+	RollBackUpdateAction = "roll_back_update"
+)
+
+// PromoteButton offers to republish the update group on another branch. The button's value holds the app and
+// group as appId/group, which is all the interaction handler needs to find the updates again.
+func PromoteButton(catalog *i18n.Catalog, appId, group, to string) *slack.ButtonBlockElement {
+	button := slack.NewButtonBlockElement(PromoteUpdateAction, appId+"/"+group, slack.NewTextBlockObject(slack.PlainTextType, catalog.T("Promote to %s", to), false, false))
+	button.WithConfirm(slack.NewConfirmationBlockObject(
+		slack.NewTextBlockObject(slack.PlainTextType, catalog.T("Promote to %s?", to), false, false),
+		slack.NewTextBlockObject(slack.MarkdownType, catalog.T("This republishes the update on the `%s` branch, where every build on a channel using it will download it.", to), false, false),
+		slack.NewTextBlockObject(slack.PlainTextType, catalog.T("Promote"), false, false),
+		slack.NewTextBlockObject(slack.PlainTextType, catalog.T("Cancel"), false, false),
+	))
+	return button
+}
+
+// RollBackButton offers to undo the update group by republishing the update before it, or where there's
+// none to go back to, rolling back to the update embedded in the build. Its value is the same as for
+// PromoteButton.
+func RollBackButton(catalog *i18n.Catalog, appId, group string) *slack.ButtonBlockElement {
+	button := slack.NewButtonBlockElement(RollBackUpdateAction, appId+"/"+group, slack.NewTextBlockObject(slack.PlainTextType, catalog.T("Roll back"), false, false))
+	button.WithStyle(slack.StyleDanger)
+	button.WithConfirm(slack.NewConfirmationBlockObject(
+		slack.NewTextBlockObject(slack.PlainTextType, catalog.T("Roll back this update?"), false, false),
+		slack.NewTextBlockObject(slack.MarkdownType, catalog.T("This republishes the previous update on the branch, or rolls back to the update embedded in the build if there isn't one. Only the latest update on a branch can be rolled back."), false, false),
+		slack.NewTextBlockObject(slack.PlainTextType, catalog.T("Roll back"), false, false),
+		slack.NewTextBlockObject(slack.PlainTextType, catalog.T("Cancel"), false, false),
+	))
+	return button
+}
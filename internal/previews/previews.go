@@ -0,0 +1,151 @@
+// Package previews keeps a comment on the pull request each preview build was made from up to date with the
+// latest build for each platform, so that reviewers can install the change and test it.
+package previews
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NWACus/expo-slack-webhook/config"
+	"github.com/NWACus/expo-slack-webhook/expo"
+	"github.com/NWACus/expo-slack-webhook/github"
+)
+
+const (
+	// Channel is the update channel whose builds are made for reviewing changes.
+	Channel = "preview"
+	// ttl is how long we remember the comment on a pull request after its last build; a build after that
+	// comments anew.
+	ttl = 30 * 24 * time.Hour
+)
+
+// pullRef matches the refs GitHub gives pull requests, like refs/pull/123/merge.
+var pullRef = regexp.MustCompile(`^refs/pull/([0-9]+)/`)
+
+// Build is a build made from a pull request, as we list it in the comment.
+type Build struct {
+	Platform expo.Platform `json:"platform"`
+	Status   expo.Status   `json:"status"`
+	Version  string        `json:"version"`
+	// Commit is the full hash of the commit built, which GitHub links to.
+	Commit string `json:"commit,omitempty"`
+	// URL is the build's details page, which has a QR code to install it with.
+	URL            string `json:"url"`
+	Channel        string `json:"channel"`
+	RuntimeVersion string `json:"runtimeVersion,omitempty"`
+	// Update is the latest update on the channel for the build's runtime, if there's one.
+	Update *Update `json:"update,omitempty"`
+}
+
+// Update is an update a build would load, as we list it in the comment.
+type Update struct {
+	URL    string `json:"url"`
+	Commit string `json:"commit,omitempty"`
+}
+
+// comment is the comment we keep on a pull request, and the builds it lists.
+type comment struct {
+	Id     int64                   `json:"id"`
+	Builds map[expo.Platform]Build `json:"builds"`
+}
+
+// PullRequest finds the open pull request the ref a build was made from belongs to, in the repository given
+// as owner/name: either the pull request's own ref, like refs/pull/123/merge, or the branch it's from. Nil
+// is returned for other refs, like tags, and branches without an open pull request.
+func PullRequest(ctx context.Context, cfg *config.Config, repository, ref string) (*github.PullRequest, error) {
+	if match := pullRef.FindStringSubmatch(ref); match != nil {
+		number, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid pull request ref %q: %v", ref, err)
+		}
+		return &github.PullRequest{Number: number}, nil
+	}
+	branch, isBranch := strings.CutPrefix(ref, "refs/heads/")
+	if ref == "" || !isBranch && strings.HasPrefix(ref, "refs/") {
+		return nil, nil
+	}
+	return cfg.GitHub.OpenPullRequest(ctx, repository, branch)
+}
+
+// Built lists the build in the comment on the pull request with the number, in the repository given as
+// owner/name, in place of the previous build for its platform, commenting if there's no comment yet.
+func Built(ctx context.Context, cfg *config.Config, logger *slog.Logger, repository string, number int, build Build) error {
+	if !cfg.PreviewComments || cfg.Store == nil {
+		return nil
+	}
+	key := entryKey(repository, number)
+	c := comment{Builds: map[expo.Platform]Build{}}
+	raw, ok, err := cfg.Store.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to look up comment: %v", err)
+	}
+	if ok {
+		if err := json.Unmarshal([]byte(raw), &c); err != nil {
+			return fmt.Errorf("failed to unmarshal comment: %v", err)
+		}
+	}
+	c.Builds[build.Platform] = build
+
+	if c.Id == 0 {
+		c.Id, err = cfg.GitHub.Comment(ctx, repository, number, body(c))
+		if err != nil {
+			return err
+		}
+		logger.Info("commented on pull request", "repository", repository, "pull_request", number, "comment", c.Id)
+	} else if err := cfg.GitHub.EditComment(ctx, repository, c.Id, body(c)); err != nil {
+		return err
+	}
+
+	marshalled, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to marshal comment: %v", err)
+	}
+	return cfg.Store.Set(ctx, key, string(marshalled), ttl)
+}
+
+// body lists the builds in the comment, by platform, with how to install them and the updates they'll load.
+func body(c comment) string {
+	var b strings.Builder
+	b.WriteString("### Preview builds\n\n")
+	b.WriteString("| Platform | Build | Status | Install | OTA updates |\n")
+	b.WriteString("| --- | --- | --- | --- | --- |\n")
+	for _, platform := range slices.Sorted(maps.Keys(c.Builds)) {
+		build := c.Builds[platform]
+		version := fmt.Sprintf("[%s](%s)", build.Version, build.URL)
+		if build.Commit != "" {
+			version += " of " + build.Commit
+		}
+		install := "-"
+		if build.Status == expo.StatusFinished {
+			install = fmt.Sprintf("[QR code](%s)", build.URL)
+		}
+		updates := fmt.Sprintf("`%s`", build.Channel)
+		if build.RuntimeVersion != "" {
+			updates += fmt.Sprintf(", runtime `%s`", build.RuntimeVersion)
+		}
+		updates += ": "
+		switch {
+		case build.Update == nil:
+			updates += "none published yet"
+		case build.Update.Commit != "":
+			updates += fmt.Sprintf("[latest](%s) of %s", build.Update.URL, build.Update.Commit)
+		default:
+			updates += fmt.Sprintf("[latest](%s)", build.Update.URL)
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s %s | %s | %s |\n", expo.PlatformDisplay(nil, platform), version, expo.StatusSymbol(build.Status), expo.StatusDisplay(nil, build.Status), install, updates)
+	}
+	b.WriteString("\nScan a build's QR code on its page to install it, after which it loads the updates published to its channel for its runtime. This comment is updated as the pull request is built again.\n")
+	return b.String()
+}
+
+func entryKey(repository string, number int) string {
+	return fmt.Sprintf("preview-comment/%s/%d", repository, number)
+}
@@ -0,0 +1,78 @@
+// Package recovery remembers the messages posted for failed builds and submissions, so that when a retry
+// succeeds the failure can be marked as recovered in its thread instead of a new message being posted.
+package recovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/slack-go/slack"
+
+	"github.com/NWACus/expo-slack-webhook/config"
+	"github.com/NWACus/expo-slack-webhook/threads"
+)
+
+// Reaction is added to the message for a failure once a retry succeeds.
+const Reaction = "white_check_mark"
+
+// Failed records the message posted for a failure under the key, which identifies what a retry would be
+// for, like the build of a commit for a platform; nothing is recorded for an empty key. Only messages
+// posted with a bot token can be reacted to, so nothing is recorded without one.
+func Failed(ctx context.Context, cfg *config.Config, key string, msg threads.Message) error {
+	if cfg.SlackClient == nil || cfg.Store == nil || key == "" || msg.TS == "" {
+		return nil
+	}
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %v", err)
+	}
+	return cfg.Store.Set(ctx, entryKey(key), string(raw), config.DefaultThreadTTL)
+}
+
+// Recovered reacts to the message recorded for a failure under the key and replies in its thread with the
+// blocks, reporting whether it did so. When there's no such message in the channel msg is routed to, it's
+// left to the caller to post msg as usual.
+func Recovered(ctx context.Context, cfg *config.Config, logger *slog.Logger, key string, msg config.Message, reply []slack.Block) bool {
+	if cfg.SlackClient == nil || cfg.Store == nil || key == "" {
+		return false
+	}
+	raw, ok, err := cfg.Store.Get(ctx, entryKey(key))
+	if err != nil {
+		logger.Warn("failed to look up message for the failure", "error", err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+	var failure threads.Message
+	if err := json.Unmarshal([]byte(raw), &failure); err != nil {
+		logger.Warn("failed to unmarshal message for the failure", "error", err)
+		return false
+	}
+	// threads can't span channels, so if the success is now routed elsewhere it's posted there in full
+	if failure.Channel != msg.Channel {
+		return false
+	}
+
+	logger.Info("marking the failure as recovered", "ts", failure.TS)
+	msg.Blocks = reply
+	msg.ThreadTS = failure.TS
+	if _, err := cfg.Poster.Post(ctx, msg); err != nil {
+		logger.Error("failed to post message", "error", err)
+		cfg.Metrics.SlackPostFailed()
+		return false
+	}
+	if err := cfg.SlackClient.AddReactionContext(ctx, Reaction, slack.NewRefToMessage(failure.Channel, failure.TS)); err != nil {
+		logger.Warn("failed to react to the message for the failure", "error", err)
+	}
+	if err := cfg.Store.Delete(ctx, entryKey(key)); err != nil {
+		logger.Warn("failed to forget message for the failure", "error", err)
+	}
+	return true
+}
+
+func entryKey(key string) string {
+	return "failure/" + key
+}
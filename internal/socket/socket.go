@@ -0,0 +1,88 @@
+// Package socket receives slash commands, events and button presses from Slack over a Socket Mode
+// connection, for deployments that can't expose the /slack endpoints publicly.
+package socket
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/slack-go/slack/socketmode"
+
+	"github.com/NWACus/expo-slack-webhook/api/slack/commands"
+	"github.com/NWACus/expo-slack-webhook/api/slack/events"
+	"github.com/NWACus/expo-slack-webhook/api/slack/interactions"
+	"github.com/NWACus/expo-slack-webhook/config"
+)
+
+// Run connects to Slack with the app-level token and handles what it sends until the context is done,
+// reconnecting as necessary. The bot token is used for everything else, as it is for the HTTP endpoints.
+func Run(ctx context.Context, cfg *config.Config, botToken, appToken string) error {
+	client := socketmode.New(slack.New(botToken, slack.OptionAppLevelToken(appToken)))
+	logger := cfg.Logger.With("transport", "socket")
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case evt := <-client.Events:
+				handle(ctx, cfg, logger, client, evt)
+			}
+		}
+	}()
+	return client.RunContext(ctx)
+}
+
+// handle acknowledges the event before acting on it, as Slack gives up on us after three seconds just as
+// it does over HTTP.
+func handle(ctx context.Context, cfg *config.Config, logger *slog.Logger, client *socketmode.Client, evt socketmode.Event) {
+	switch evt.Type {
+	case socketmode.EventTypeConnecting:
+		logger.Info("connecting to Slack")
+		return
+	case socketmode.EventTypeConnected:
+		logger.Info("connected to Slack")
+		return
+	case socketmode.EventTypeConnectionError, socketmode.EventTypeInvalidAuth:
+		logger.Error("failed to connect to Slack", "type", evt.Type, "error", evt.Data)
+		return
+	}
+	if evt.Request == nil {
+		return
+	}
+	client.Ack(*evt.Request)
+
+	switch evt.Type {
+	case socketmode.EventTypeInteractive:
+		callback, ok := evt.Data.(slack.InteractionCallback)
+		if !ok {
+			logger.Error("unexpected interaction", "data", evt.Data)
+			return
+		}
+		logger := logger.With("webhook", "interaction")
+		logger.Info("interaction received")
+		interactions.Dispatch(ctx, cfg, logger, &callback)
+	case socketmode.EventTypeSlashCommand:
+		command, ok := evt.Data.(slack.SlashCommand)
+		if !ok {
+			logger.Error("unexpected command", "data", evt.Data)
+			return
+		}
+		logger := logger.With("webhook", "command")
+		logger.Info("command received")
+		commands.Run(ctx, cfg, logger, command)
+	case socketmode.EventTypeEventsAPI:
+		event, ok := evt.Data.(slackevents.EventsAPIEvent)
+		if !ok {
+			logger.Error("unexpected event", "data", evt.Data)
+			return
+		}
+		logger := logger.With("webhook", "event")
+		logger.Info("event received")
+		events.Dispatch(ctx, cfg, logger, event)
+	default:
+		logger.Info("ignoring request", "type", evt.Type)
+	}
+}
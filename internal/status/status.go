@@ -0,0 +1,186 @@
+// Package status describes the latest builds and OTA updates for the apps we know of, for answering
+// questions in Slack rather than announcing webhooks.
+package status
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/NWACus/expo-slack-webhook/config"
+	"github.com/NWACus/expo-slack-webhook/expo"
+)
+
+// DefaultChannel is the update channel reported on when none is asked about.
+const DefaultChannel = "production"
+
+// Query is what to report on: the latest builds for the platforms on the update channel, the latest OTA
+// update on it, or both.
+type Query struct {
+	Channel string
+	// Platforms to report builds for; iOS and Android when empty.
+	Platforms []expo.Platform
+	Builds    bool
+	Updates   bool
+}
+
+// Report answers the query for each app, as Slack mrkdwn.
+func Report(ctx context.Context, cfg *config.Config, logger *slog.Logger, query Query) string {
+	appIds := cfg.AppIds()
+	if len(appIds) == 0 {
+		return "No Expo app is configured to report on."
+	}
+	if query.Channel == "" {
+		query.Channel = DefaultChannel
+	}
+	if len(query.Platforms) == 0 {
+		query.Platforms = []expo.Platform{expo.PlatformIOS, expo.PlatformAndroid}
+	}
+	var sections []string
+	for _, appId := range appIds {
+		sections = append(sections, appReport(ctx, cfg, logger.With("app_id", appId), appId, query))
+	}
+	return strings.Join(sections, "\n\n")
+}
+
+func appReport(ctx context.Context, cfg *config.Config, logger *slog.Logger, appId string, query Query) string {
+	name := appId
+	app, err := cfg.ExpoClient.FetchApp(ctx, appId)
+	if err != nil {
+		logger.Error("failed to fetch app", "error", err)
+	} else if app.Name != "" {
+		name = app.Name
+	}
+	repository, project := cfg.RepositoryFor(appId, app), app.Project()
+
+	lines := []string{fmt.Sprintf("*%s* on `%s`:", name, query.Channel)}
+	if query.Builds {
+		for _, platform := range query.Platforms {
+			builds, err := cfg.ExpoClient.FetchBuilds(ctx, appId, query.Channel, platform, 1, 0)
+			switch {
+			case err != nil:
+				logger.Error("failed to fetch builds", "platform", platform, "error", err)
+				lines = append(lines, fmt.Sprintf("• :warning: Couldn't load the latest %s build.", expo.PlatformDisplay(nil, platform)))
+			case len(builds) == 0:
+				lines = append(lines, fmt.Sprintf("• No %s builds.", expo.PlatformDisplay(nil, platform)))
+			default:
+				lines = append(lines, "• "+buildStatus(repository, project, platform, builds[0]))
+			}
+		}
+	}
+	if !query.Updates {
+		return strings.Join(lines, "\n")
+	}
+
+	updateChannel, err := cfg.ExpoClient.FetchUpdateChannel(ctx, appId, query.Channel)
+	if err != nil {
+		logger.Error("failed to fetch update channel", "error", err)
+		lines = append(lines, "• :warning: Couldn't load the latest OTA update.")
+		return strings.Join(lines, "\n")
+	}
+	group := latestUpdateGroup(updateChannel)
+	if len(group) == 0 {
+		lines = append(lines, "• No OTA updates.")
+		return strings.Join(lines, "\n")
+	}
+	lines = append(lines, "• "+updateStatus(repository, group))
+	return strings.Join(lines, "\n")
+}
+
+// buildStatus describes the build for the platform. The GraphQL API sends statuses in upper case, unlike
+// webhooks.
+func buildStatus(repository string, project expo.Project, platform expo.Platform, build expo.Build) string {
+	status := expo.Status(strings.ToLower(string(build.Status)))
+	text := fmt.Sprintf("%s build %s %s", expo.PlatformDisplay(nil, platform), expo.FormatBuildVersion(repository, project, build.BuildVersionMetadata), expo.StatusDisplay(nil, status))
+	if createdAt, err := time.Parse(time.RFC3339, build.CreatedAt); err == nil {
+		text += " " + expo.FormatTimestamp(nil, createdAt)
+	}
+	return text + "."
+}
+
+func updateStatus(repository string, group []expo.Update) string {
+	first := group[0]
+	var platforms []string
+	for _, update := range group {
+		platforms = append(platforms, expo.PlatformDisplay(nil, update.Platform))
+	}
+	text := fmt.Sprintf("%s OTA update on `%s`", strings.Join(platforms, " and "), first.Branch.Name)
+	switch {
+	case first.IsRollBackToEmbedded:
+		text += ", a rollback to the embedded update,"
+	case first.GitCommitHash != "":
+		text += fmt.Sprintf(" for commit <%s|%s>", expo.CommitURL(repository, first.GitCommitHash), expo.ShortCommit(first.GitCommitHash))
+	}
+	if createdAt, err := time.Parse(time.RFC3339, first.CreatedAt); err == nil {
+		text += " published " + expo.FormatTimestamp(nil, createdAt)
+	}
+	return text + "."
+}
+
+// latestUpdateGroup finds the most recently published update group across the branches the channel maps to.
+func latestUpdateGroup(channel *expo.UpdateChannel) []expo.Update {
+	var latest []expo.Update
+	var latestAt time.Time
+	for _, branch := range channel.UpdateBranches {
+		if len(branch.UpdateGroups) == 0 || len(branch.UpdateGroups[0]) == 0 {
+			continue
+		}
+		group := branch.UpdateGroups[0]
+		createdAt, err := time.Parse(time.RFC3339, group[0].CreatedAt)
+		if err != nil {
+			continue
+		}
+		if latest == nil || createdAt.After(latestAt) {
+			latest, latestAt = group, createdAt
+		}
+	}
+	return latest
+}
+
+// knownChannels are the update channel names we recognise in questions without being told they're channels.
+var knownChannels = []string{"production", "preview", "staging", "development"}
+
+// articles can precede "channel" without naming it, as in "the channel staging".
+var articles = []string{"the", "a", "that", "this", "which", "what"}
+
+// ParseQuestion makes what it can of a question like "what's the latest iOS production build?". Platforms,
+// channels and whether builds or updates are meant are picked out by keyword; anything not mentioned is
+// left at its default, so a question with none of them reports everything on production.
+func ParseQuestion(text string) Query {
+	query := Query{}
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_')
+	})
+	for i, word := range words {
+		switch {
+		case word == "ios" || word == "iphone":
+			if !slices.Contains(query.Platforms, expo.PlatformIOS) {
+				query.Platforms = append(query.Platforms, expo.PlatformIOS)
+			}
+		case word == "android":
+			if !slices.Contains(query.Platforms, expo.PlatformAndroid) {
+				query.Platforms = append(query.Platforms, expo.PlatformAndroid)
+			}
+		case word == "build" || word == "builds":
+			query.Builds = true
+		case word == "update" || word == "updates" || word == "ota":
+			query.Updates = true
+		case slices.Contains(knownChannels, word):
+			query.Channel = word
+		case word == "channel" && query.Channel == "":
+			// "the staging channel" or "the channel staging"
+			if i > 0 && !slices.Contains(articles, words[i-1]) {
+				query.Channel = words[i-1]
+			} else if i+1 < len(words) {
+				query.Channel = words[i+1]
+			}
+		}
+	}
+	if !query.Builds && !query.Updates {
+		query.Builds, query.Updates = true, true
+	}
+	return query
+}
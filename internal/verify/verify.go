@@ -0,0 +1,127 @@
+// Package verify reads webhook requests and checks that they were signed with our HMAC secret, or for
+// requests from Slack, with our signing secret.
+package verify
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/slack-go/slack"
+
+	"github.com/NWACus/expo-slack-webhook/config"
+)
+
+// Signature checks the signature sent alongside a payload, which is the hex-encoded HMAC-SHA1 digest
+// of the body.
+func Signature(secret string, body []byte, received string) error {
+	digest := hmac.New(sha1.New, []byte(secret))
+	digest.Write(body)
+	expected := fmt.Sprintf("sha1=%v", hex.EncodeToString(digest.Sum(nil)))
+	if !hmac.Equal([]byte(expected), []byte(received)) {
+		return fmt.Errorf("invalid HMAC, received %q, expected %q", received, expected)
+	}
+	return nil
+}
+
+// AnySignature checks the signature against each of the secrets, accepting it if any of them match.
+func AnySignature(secrets []string, body []byte, received string) error {
+	for _, secret := range secrets {
+		if Signature(secret, body, received) == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid HMAC, received %q, which matches none of the %d configured secrets", received, len(secrets))
+}
+
+// Read reads the body of a webhook request and verifies its signature, which is taken from the first of
+// the headers that is set. When the request should not be processed any further, an error response is
+// written and false is returned.
+func Read(cfg *config.Config, logger *slog.Logger, w http.ResponseWriter, r *http.Request, headers ...string) ([]byte, bool) {
+	body, ok := readBody(cfg, logger, w, r)
+	if !ok {
+		return nil, false
+	}
+
+	var receivedSignature string
+	for _, header := range headers {
+		if receivedSignature = r.Header.Get(header); receivedSignature != "" {
+			break
+		}
+	}
+	logger.Debug("received signature", "signature", receivedSignature)
+	if err := AnySignature(cfg.ExpoHMACSecrets, body, receivedSignature); err != nil {
+		logger.Warn("rejecting payload", "error", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return nil, false
+	}
+
+	logger.Debug("received payload", "payload", string(body))
+	return body, true
+}
+
+// SlackSignature checks the v0 signature Slack sends with requests, which covers their timestamp too, so
+// that requests more than a few minutes old are rejected as replays.
+func SlackSignature(secret string, header http.Header, body []byte) error {
+	verifier, err := slack.NewSecretsVerifier(header, secret)
+	if err != nil {
+		return err
+	}
+	if _, err := verifier.Write(body); err != nil {
+		return err
+	}
+	return verifier.Ensure()
+}
+
+// ReadSlack reads the body of a request from Slack and verifies its signature with our signing secret,
+// like Read does for webhooks from Expo.
+func ReadSlack(cfg *config.Config, logger *slog.Logger, w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	if cfg.SlackSigningSecret == "" {
+		logger.Error("no Slack signing secret configured to verify requests with")
+		w.WriteHeader(http.StatusNotFound)
+		return nil, false
+	}
+	body, ok := readBody(cfg, logger, w, r)
+	if !ok {
+		return nil, false
+	}
+	if err := SlackSignature(cfg.SlackSigningSecret, r.Header, body); err != nil {
+		logger.Warn("rejecting request", "error", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return nil, false
+	}
+	logger.Debug("received request", "body", string(body))
+	return body, true
+}
+
+// readBody reads the body of a POST request, up to our size limit.
+func readBody(cfg *config.Config, logger *slog.Logger, w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return nil, false
+	}
+
+	// Expo payloads are small, so we refuse anything large before spending time or memory on it
+	limit := cfg.MaxBodyBytes
+	if limit <= 0 {
+		limit = config.DefaultMaxBodyBytes
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			logger.Warn("rejecting oversized payload", "limit", tooLarge.Limit)
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return nil, false
+		}
+		logger.Error("failed to read request body", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	return body, true
+}
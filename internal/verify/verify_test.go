@@ -0,0 +1,57 @@
+package verify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NWACus/expo-slack-webhook/config"
+)
+
+func sign(secret string, body []byte) string {
+	digest := hmac.New(sha1.New, []byte(secret))
+	digest.Write(body)
+	return "sha1=" + hex.EncodeToString(digest.Sum(nil))
+}
+
+func TestRead(t *testing.T) {
+	body := []byte(`{"id":"35425398-97b0-4f02-ac41-beb723090aa2"}`)
+	// while a secret is rotated, payloads signed with either the old or the new one are accepted
+	secrets := []string{"current", "previous"}
+	for _, tc := range []struct {
+		name      string
+		signature string
+		expected  int
+	}{
+		{name: "first secret", signature: sign("current", body), expected: http.StatusOK},
+		{name: "second secret", signature: sign("previous", body), expected: http.StatusOK},
+		{name: "neither secret", signature: sign("other", body), expected: http.StatusUnauthorized},
+		{name: "no signature", signature: "", expected: http.StatusUnauthorized},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.Config{ExpoHMACSecrets: secrets}
+			r := httptest.NewRequest(http.MethodPost, "/build", bytes.NewReader(body))
+			if tc.signature != "" {
+				r.Header.Set("expo-signature", tc.signature)
+			}
+			w := httptest.NewRecorder()
+
+			read, ok := Read(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), w, r, "expo-signature")
+			if ok != (tc.expected == http.StatusOK) {
+				t.Fatalf("expected the payload to be accepted: %v, got %v", tc.expected == http.StatusOK, ok)
+			}
+			if ok && !bytes.Equal(read, body) {
+				t.Errorf("expected the body to be returned, got %q", read)
+			}
+			if w.Code != tc.expected {
+				t.Errorf("expected status %d, got %d", tc.expected, w.Code)
+			}
+		})
+	}
+}
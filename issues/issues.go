@@ -0,0 +1,35 @@
+// Package issues finds the issues commits refer to, like Jira tickets, so that messages can link to what a
+// build or update ships.
+package issues
+
+// Issue is an issue in a tracker, identified by its key, like AVY-123.
+type Issue struct {
+	Key string
+	URL string
+}
+
+// Tracker finds references to its issues in text.
+type Tracker interface {
+	Find(text string) []Issue
+}
+
+// Trackers finds issues with each of several trackers. A nil Trackers finds nothing.
+type Trackers []Tracker
+
+// Find returns the issues the trackers find in the texts, each once, in the order they're first mentioned.
+func (t Trackers) Find(texts ...string) []Issue {
+	var found []Issue
+	seen := map[string]bool{}
+	for _, text := range texts {
+		for _, tracker := range t {
+			for _, issue := range tracker.Find(text) {
+				if seen[issue.URL] {
+					continue
+				}
+				seen[issue.URL] = true
+				found = append(found, issue)
+			}
+		}
+	}
+	return found
+}
@@ -0,0 +1,46 @@
+package issues
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// jiraKey matches a Jira issue key, a project key followed by the issue's number, like AVY-123.
+var jiraKey = regexp.MustCompile(`\b([A-Z][A-Z0-9_]+)-([1-9][0-9]*)\b`)
+
+// Jira links to the issues of a Jira instance.
+type Jira struct {
+	// URL is where the instance is, like https://nwac.atlassian.net.
+	URL string
+	// Projects are the keys of the projects to link issues of. When empty, anything that looks like an issue
+	// key is linked, which may catch things like UTF-8.
+	Projects []string
+}
+
+// NewJira configures linking to the Jira instance at the URL, for issues in the projects, if given.
+func NewJira(rawURL string, projects []string) (*Jira, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid Jira URL %q, expected https://host", rawURL)
+	}
+	for _, project := range projects {
+		if !jiraKey.MatchString(project + "-1") {
+			return nil, fmt.Errorf("invalid Jira project key %q", project)
+		}
+	}
+	return &Jira{URL: strings.TrimSuffix(rawURL, "/"), Projects: projects}, nil
+}
+
+func (j *Jira) Find(text string) []Issue {
+	var found []Issue
+	for _, match := range jiraKey.FindAllStringSubmatch(text, -1) {
+		if len(j.Projects) > 0 && !slices.Contains(j.Projects, match[1]) {
+			continue
+		}
+		found = append(found, Issue{Key: match[0], URL: j.URL + "/browse/" + match[0]})
+	}
+	return found
+}
@@ -0,0 +1,59 @@
+package issues
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+)
+
+var (
+	// linearIdentifier matches a Linear issue identifier, a team key followed by the issue's number, like ENG-42.
+	linearIdentifier = regexp.MustCompile(`\b([A-Z][A-Z0-9]{0,6})-([1-9][0-9]*)\b`)
+	// linearMagicWord matches the references Linear itself links commits to issues by, like "fixes ENG-42" or
+	// "part of ENG-42".
+	linearMagicWord = regexp.MustCompile(`(?i:\b(?:close[sd]?|closing|fix(?:e[sd])?|fixing|resolve[sd]?|resolving|complete[sd]?|completing|refs?|references|part of|related to|contributes to|towards?)\b:?\s+)([A-Z][A-Z0-9]{0,6}-[1-9][0-9]*)\b`)
+	// linearWorkspace matches a workspace's URL key, as in linear.app/example.
+	linearWorkspace = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+)
+
+// Linear links to the issues of a Linear workspace.
+type Linear struct {
+	// Workspace is the workspace's URL key, like example in https://linear.app/example.
+	Workspace string
+	// Teams are the keys of the teams to link any mention of an issue of. When empty, only issues referred to
+	// with one of Linear's magic words, like "fixes ENG-42", are linked, as other identifiers could be Jira's.
+	Teams []string
+}
+
+// NewLinear configures linking to the issues of the Linear workspace, of the teams, if given.
+func NewLinear(workspace string, teams []string) (*Linear, error) {
+	if !linearWorkspace.MatchString(workspace) {
+		return nil, fmt.Errorf("invalid Linear workspace %q, expected its URL key", workspace)
+	}
+	for _, team := range teams {
+		if !linearIdentifier.MatchString(team + "-1") {
+			return nil, fmt.Errorf("invalid Linear team key %q", team)
+		}
+	}
+	return &Linear{Workspace: workspace, Teams: teams}, nil
+}
+
+func (l *Linear) Find(text string) []Issue {
+	var found []Issue
+	if len(l.Teams) == 0 {
+		for _, match := range linearMagicWord.FindAllStringSubmatch(text, -1) {
+			found = append(found, l.issue(match[1]))
+		}
+		return found
+	}
+	for _, match := range linearIdentifier.FindAllStringSubmatch(text, -1) {
+		if slices.Contains(l.Teams, match[1]) {
+			found = append(found, l.issue(match[0]))
+		}
+	}
+	return found
+}
+
+func (l *Linear) issue(identifier string) Issue {
+	return Issue{Key: identifier, URL: fmt.Sprintf("https://linear.app/%s/issue/%s", l.Workspace, identifier)}
+}
@@ -1,80 +1,354 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
-	"github.com/slack-go/slack"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gopkg.in/yaml.v3"
 
 	"github.com/NWACus/expo-slack-webhook/api/build"
+	"github.com/NWACus/expo-slack-webhook/api/slack/commands"
+	"github.com/NWACus/expo-slack-webhook/api/slack/events"
+	"github.com/NWACus/expo-slack-webhook/api/slack/interactions"
 	"github.com/NWACus/expo-slack-webhook/api/submit"
 	"github.com/NWACus/expo-slack-webhook/api/update"
+	"github.com/NWACus/expo-slack-webhook/api/webhook"
 	"github.com/NWACus/expo-slack-webhook/config"
-	"github.com/NWACus/expo-slack-webhook/expo"
+	"github.com/NWACus/expo-slack-webhook/internal/socket"
+	"github.com/NWACus/expo-slack-webhook/metrics"
+	"github.com/NWACus/expo-slack-webhook/pairing"
+	"github.com/NWACus/expo-slack-webhook/version"
+	"github.com/NWACus/expo-slack-webhook/worker"
 )
 
 type Options struct {
-	ExpoHMACSecret string
-	ExpoToken      string
-	SlackToken     string
-	SlackChannel   string
+	ConfigFile string `yaml:"-"`
+	Version    bool   `yaml:"-"`
 
-	Port int
+	// Settings are those shared with the serverless functions, which read them from the environment alone;
+	// see config.LoadFromEnv.
+	config.Settings `yaml:",inline"`
+
+	// SlackAppToken receives requests from Slack, like button presses, over a Socket Mode connection rather
+	// than the /slack endpoints, which needs no SlackSigningSecret.
+	SlackAppToken    string        `yaml:"slack-app-token"`
+	PairBuildsWithin time.Duration `yaml:"pair-builds-within"`
+
+	MaxConcurrency int `yaml:"max-concurrency"`
+
+	Port    int  `yaml:"port"`
+	Metrics bool `yaml:"metrics"`
+
+	ReadHeaderTimeout time.Duration `yaml:"read-header-timeout"`
+	ReadTimeout       time.Duration `yaml:"read-timeout"`
+	WriteTimeout      time.Duration `yaml:"write-timeout"`
+	IdleTimeout       time.Duration `yaml:"idle-timeout"`
 }
 
 func DefaultOptions() *Options {
+	settings := config.DefaultSettings()
+	// the serverless functions log JSON for Vercel, but people run the server in a terminal
+	settings.LogFormat = config.LogFormatText
 	return &Options{
+		Settings: settings,
+
+		MaxConcurrency: 4,
+
 		Port: 8080,
+
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       15 * time.Second,
+		// payloads are acknowledged before we talk to Expo or Slack, so responses are written quickly
+		WriteTimeout: 15 * time.Second,
+		IdleTimeout:  60 * time.Second,
 	}
 }
 
 func BindOptions(fs *flag.FlagSet, opts *Options) {
+	fs.StringVar(&opts.ConfigFile, "config", opts.ConfigFile, "Path to a YAML or JSON file holding options. Environment variables and flags take precedence over the file.")
+	fs.BoolVar(&opts.Version, "version", opts.Version, "Print version information and exit.")
+
 	fs.StringVar(&opts.SlackToken, "slack-token", opts.SlackToken, "Slack API token.")
 	fs.StringVar(&opts.SlackChannel, "slack-channel", opts.SlackChannel, "Slack channel to post updates to.")
+	fs.StringVar(&opts.SlackBuildChannel, "slack-build-channel", opts.SlackBuildChannel, "Slack channel to post builds to, instead of slack-channel.")
+	fs.StringVar(&opts.SlackSubmitChannel, "slack-submit-channel", opts.SlackSubmitChannel, "Slack channel to post submissions to, instead of slack-channel.")
+	fs.StringVar(&opts.SlackUpdateChannel, "slack-update-channel", opts.SlackUpdateChannel, "Slack channel to post OTA updates to, instead of slack-channel.")
+	fs.Var(&listFlag{into: &opts.RoutingRules}, "routing-rules", "Comma-separated rules routing matching events to other Slack channels, like 'channel=production && status=errored -> #alerts'.")
+	fs.StringVar(&opts.RoutingRulesFile, "routing-rules-file", opts.RoutingRulesFile, "File of routing rules, one per line.")
+	fs.Var(&listFlag{into: &opts.AppChannels}, "app-channels", "Comma-separated appId=channel pairs posting each Expo app's events to its own Slack channel. Webhooks for other apps are rejected.")
+	fs.Var(&listFlag{into: &opts.AppRepositories}, "app-repositories", "Comma-separated appId=owner/name pairs linking each Expo app's commits to its own GitHub repository. Webhooks for other apps are rejected.")
+	fs.IntVar(&opts.ChangelogCommits, "changelog-commits", opts.ChangelogCommits, "Number of the commits since the previous build to list in build messages, from GitHub. Zero, the default, turns the changelog off.")
+	fs.StringVar(&opts.GitHubToken, "github-token", opts.GitHubToken, "GitHub token to read commits with, needed for private repositories.")
+	fs.BoolVar(&opts.GitHubCommitStatuses, "github-commit-statuses", opts.GitHubCommitStatuses, "Set a status, like 'EAS build ios/production', on the commit each build was made from. Requires github-token.")
+	fs.BoolVar(&opts.GitHubDeployments, "github-deployments", opts.GitHubDeployments, "Record each build as a GitHub deployment of its commit to the environment named for its channel. Requires github-token.")
+	fs.BoolVar(&opts.GitHubPreviewComments, "github-preview-comments", opts.GitHubPreviewComments, "Comment on the pull request each preview build was made from with how to install it, updating the comment as the pull request is built again. Requires github-token.")
+	fs.IntVar(&opts.GitHubIssueAfterFailures, "github-issue-after-failures", opts.GitHubIssueAfterFailures, "Open a GitHub issue once this many builds in a row fail on a channel for a platform, closing it when one succeeds. Zero turns this off. Requires github-token.")
+	fs.StringVar(&opts.JiraURL, "jira-url", opts.JiraURL, "Jira instance, like https://example.atlassian.net, to link the issues commits since the previous build or update mention to.")
+	fs.Var(&listFlag{into: &opts.JiraProjects}, "jira-projects", "Comma-separated keys of the Jira projects to link issues of. Defaults to anything that looks like an issue key.")
+	fs.StringVar(&opts.LinearWorkspace, "linear-workspace", opts.LinearWorkspace, "URL key of the Linear workspace, like example in https://linear.app/example, to link the issues commits since the previous build or update mention to.")
+	fs.Var(&listFlag{into: &opts.LinearTeams}, "linear-teams", "Comma-separated keys of the Linear teams to link any mention of issues of. Defaults to issues referred to with magic words, like 'fixes ENG-42'.")
+	fs.StringVar(&opts.DefaultRepository, "default-repository", opts.DefaultRepository, "GitHub repository, as owner/name, to link commits to for apps without one in app-repositories or linked in Expo.")
+	fs.StringVar(&opts.SlackUsername, "slack-username", opts.SlackUsername, "Name to post to Slack as, instead of the app's name. Requires slack-token.")
+	fs.StringVar(&opts.SlackIconEmoji, "slack-icon-emoji", opts.SlackIconEmoji, "Emoji to post to Slack with as an avatar, instead of the app's icon. Requires slack-token.")
+	fs.StringVar(&opts.SlackWebhookURL, "slack-webhook-url", opts.SlackWebhookURL, "Slack incoming webhook URL to post updates to, instead of using a token and channel.")
+	fs.Var(&listFlag{into: &opts.SlackWorkspaces, secret: true}, "slack-workspaces", "Comma-separated channel=token pairs for channels in other Slack workspaces to post to as well.")
+	fs.StringVar(&opts.TeamsWebhookURL, "teams-webhook-url", opts.TeamsWebhookURL, "Microsoft Teams incoming webhook URL to post Adaptive Cards to, alongside or instead of Slack.")
+	fs.StringVar(&opts.MattermostURL, "mattermost-url", opts.MattermostURL, "Mattermost server URL to post to, alongside or instead of Slack.")
+	fs.StringVar(&opts.MattermostToken, "mattermost-token", opts.MattermostToken, "Mattermost bot or personal access token.")
+	fs.StringVar(&opts.MattermostChannel, "mattermost-channel", opts.MattermostChannel, "Mattermost channel ID to post to.")
+	fs.StringVar(&opts.TelegramBotToken, "telegram-bot-token", opts.TelegramBotToken, "Telegram bot token to send messages with, alongside or instead of Slack.")
+	fs.StringVar(&opts.TelegramChatID, "telegram-chat-id", opts.TelegramChatID, "Telegram chat ID or @channel to send messages to.")
+
+	fs.StringVar(&opts.SMTPAddr, "smtp-addr", opts.SMTPAddr, "SMTP server host:port to email failed builds and submissions through.")
+	fs.StringVar(&opts.SMTPUsername, "smtp-username", opts.SMTPUsername, "SMTP username, if the server requires authentication.")
+	fs.StringVar(&opts.SMTPPassword, "smtp-password", opts.SMTPPassword, "SMTP password.")
+	fs.StringVar(&opts.SMTPFrom, "smtp-from", opts.SMTPFrom, "Address to send email from.")
+	fs.Var(&listFlag{into: &opts.EmailTo}, "email-to", "Comma-separated addresses to email failed builds and submissions to.")
+	fs.Var(&listFlag{into: &opts.EmailToIOS}, "email-to-ios", "Comma-separated addresses to email iOS failures to, instead of email-to.")
+	fs.Var(&listFlag{into: &opts.EmailToAndroid}, "email-to-android", "Comma-separated addresses to email Android failures to, instead of email-to.")
+
+	fs.StringVar(&opts.PagerDutyRoutingKey, "pagerduty-routing-key", opts.PagerDutyRoutingKey, "PagerDuty Events API v2 integration key to open incidents for failed builds and submissions with.")
 
-	fs.StringVar(&opts.ExpoHMACSecret, "hmac-secret", opts.ExpoHMACSecret, "HMAC token to verify Expo webhook payloads.")
+	fs.StringVar(&opts.OpsgenieAPIKey, "opsgenie-api-key", opts.OpsgenieAPIKey, "Opsgenie API integration key to create alerts for failed builds and submissions with.")
+	fs.StringVar(&opts.OpsgenieURL, "opsgenie-url", opts.OpsgenieURL, "Opsgenie API URL, for accounts outside the US region.")
+	fs.Var(&listFlag{into: &opts.OpsgeniePriorities}, "opsgenie-priorities", "Comma-separated channel=priority pairs setting the Opsgenie alert priority for failures on each update channel.")
+	fs.StringVar(&opts.OpsgenieDefaultPriority, "opsgenie-default-priority", opts.OpsgenieDefaultPriority, "Opsgenie alert priority for failures on other channels.")
+
+	fs.Var(&listFlag{into: &opts.ExpoHMACSecrets, secret: true}, "hmac-secret", "HMAC token to verify Expo webhook payloads. Repeat the flag or separate secrets with commas to accept several while rotating them.")
 	fs.StringVar(&opts.ExpoToken, "expo-token", opts.ExpoToken, "Expo API token.")
+	fs.StringVar(&opts.ExpoAppId, "expo-app-id", opts.ExpoAppId, "Expo app ID to report on in Slack commands, when app-channels and app-repositories aren't set.")
+	fs.DurationVar(&opts.UpdateChannelCacheTTL, "update-channel-cache-ttl", opts.UpdateChannelCacheTTL, "How long to cache Expo update channels for. Zero disables the cache.")
+	fs.IntVar(&opts.UpdateChannelCacheSize, "update-channel-cache-size", opts.UpdateChannelCacheSize, "Maximum number of Expo update channels to cache.")
+	fs.BoolVar(&opts.UpdateChannelCacheShared, "update-channel-cache-shared", opts.UpdateChannelCacheShared, "Also cache Expo update channels in the state store, to share them between processes using a Redis store.")
+	fs.IntVar(&opts.SearchLimit, "search-limit", opts.SearchLimit, "Maximum number of builds, submissions or update groups to look back through for the previous one to compare with.")
+	fs.Int64Var(&opts.MaxBodyBytes, "max-body-bytes", opts.MaxBodyBytes, "Reject webhook requests with bodies larger than this many bytes.")
+	fs.DurationVar(&opts.MaxPayloadAge, "max-payload-age", opts.MaxPayloadAge, "Reject webhook payloads older than this as possible replays. Zero disables the check.")
+	fs.BoolVar(&opts.RequirePayloadTimestamp, "require-payload-timestamp", opts.RequirePayloadTimestamp, "When checking payload age, reject payloads without a timestamp.")
+
+	fs.StringVar(&opts.StateStore, "state-store", opts.StateStore, "Where to keep state between webhooks: memory:, file:///path/to/state.json, or redis://[:password@]host:port[/db] (rediss:// for TLS). Defaults to memory.")
+	fs.BoolVar(&opts.ThreadByCommit, "thread-by-commit", opts.ThreadByCommit, "Post submission and update messages as replies to the build message for the same commit.")
+	fs.DurationVar(&opts.PairBuildsWithin, "pair-builds-within", opts.PairBuildsWithin, "Combine the iOS and Android builds for the same commit and channel into one message when they finish within this long of each other. Zero disables pairing.")
+	fs.BoolVar(&opts.SetTopic, "set-topic", opts.SetTopic, "Set the channel topic to the latest version after each successful production build or submission.")
+	fs.StringVar(&opts.TopicChannel, "topic-channel", opts.TopicChannel, "Channel whose topic is set to the latest production version, instead of the one releases are posted to. Implies set-topic.")
+	fs.BoolVar(&opts.MentionFailures, "mention-failures", opts.MentionFailures, "Mention whoever started a failed build, finding them in Slack by the email address of their Expo account.")
+	fs.Var(&listFlag{into: &opts.SlackUsers}, "slack-users", "Comma-separated email=userId pairs, mentioning that Slack user for the Expo account with that email address instead.")
+	fs.StringVar(&opts.OnCallGroup, "oncall-group", opts.OnCallGroup, "Slack user group to mention on failed builds and submissions for oncall-channels, by ID or @handle.")
+	fs.Var(&listFlag{into: &opts.OnCallChannels}, "oncall-channels", "Comma-separated update channels to mention oncall-group for failures on.")
+	fs.Var(&listFlag{into: &opts.EscalationRules}, "escalation-rules", "Comma-separated rules deciding how loudly matching builds and submissions are announced, like 'channel=production && status=errored -> page'. Escalations are quiet (no mentions), post, or page (also mentions oncall-group and copies to escalation-channel); the first matching rule wins.")
+	fs.StringVar(&opts.EscalationChannel, "escalation-channel", opts.EscalationChannel, "Channel to copy messages that page oncall-group to.")
+	fs.BoolVar(&opts.PinReleases, "pin-releases", opts.PinReleases, "Keep a pinned message showing the latest production and preview builds, submissions and OTA updates in the channels they're posted to.")
+	fs.StringVar(&opts.ReleasesChannel, "releases-channel", opts.ReleasesChannel, "Channel to keep the pinned message showing the latest releases in, instead of those they're posted to. Implies pin-releases.")
+	fs.BoolVar(&opts.InstallQRCodes, "install-qr-codes", opts.InstallQRCodes, "Reply to the messages for internal distribution builds with a QR code to install them. Slack channels must be given by ID.")
+
+	fs.StringVar(&opts.SlackSigningSecret, "slack-signing-secret", opts.SlackSigningSecret, "Slack app signing secret, to verify button presses sent to /slack/interactions.")
+	fs.StringVar(&opts.SlackAppToken, "slack-app-token", opts.SlackAppToken, "Slack app-level token, to receive slash commands, mentions and button presses over Socket Mode rather than the /slack endpoints. Requires slack-token.")
+	fs.StringVar(&opts.PromoteFrom, "promote-from", opts.PromoteFrom, "Add a button to messages for OTA updates on this branch that republishes them to promote-to.")
+	fs.StringVar(&opts.PromoteTo, "promote-to", opts.PromoteTo, "Branch to promote OTA updates to.")
+	fs.Var(&listFlag{into: &opts.PromoteUsers}, "promote-users", "Comma-separated Slack user IDs allowed to promote OTA updates.")
+	fs.Var(&listFlag{into: &opts.RollbackUsers}, "rollback-users", "Comma-separated Slack user IDs allowed to roll back OTA updates, which adds a button to do so to their messages.")
+
+	fs.Var(&listFlag{into: &opts.NotifyPlatforms}, "notify-platforms", "Platforms to post messages for, separated by commas. Defaults to all of them.")
+	fs.Var(&listFlag{into: &opts.NotifyStatuses}, "notify-statuses", "Build and submission statuses to post messages for, separated by commas. Defaults to all of them.")
+	fs.Var(&listFlag{into: &opts.NotifyBuildStatuses}, "notify-build-statuses", "Build statuses to post messages for, separated by commas, instead of notify-statuses.")
+	fs.Var(&listFlag{into: &opts.NotifySubmitStatuses}, "notify-submit-statuses", "Submission statuses to post messages for, separated by commas, instead of notify-statuses.")
+
+	fs.StringVar(&opts.MessageTemplates, "message-templates", opts.MessageTemplates, "Go templates replacing the default wording of messages, by name, like build.title.")
+	fs.StringVar(&opts.MessageTemplatesFile, "message-templates-file", opts.MessageTemplatesFile, "File of Go templates replacing the default wording of messages; message-templates are applied on top.")
+	fs.StringVar(&opts.Locale, "locale", opts.Locale, "Locale to post messages in, translated by its catalog in message-catalogs. Defaults to English.")
+	fs.StringVar(&opts.MessageCatalogs, "message-catalogs", opts.MessageCatalogs, "YAML or JSON file of message translations for each locale, keyed by their English text.")
+	fs.StringVar(&opts.MessageTemplatesDir, "message-templates-dir", opts.MessageTemplatesDir, "Directory of Go templates replacing the default wording of messages, in build.tmpl, submit.tmpl and update.tmpl. Changes are picked up without restarting, as they are to message-templates-file.")
+	fs.StringVar(&opts.BuildLayout, "build-layout", opts.BuildLayout, "Layout of build messages: text, describing the build in sentences, or fields, listing its platform, version, channel, commit, duration and profile side by side.")
+
+	fs.IntVar(&opts.MaxConcurrency, "max-concurrency", opts.MaxConcurrency, "Maximum number of webhook payloads to process at once.")
 
 	fs.IntVar(&opts.Port, "port", opts.Port, "Port to listen on.")
+	fs.StringVar(&opts.LogFormat, "log-format", opts.LogFormat, "Log format, either text or json.")
+	fs.BoolVar(&opts.Metrics, "metrics", opts.Metrics, "Expose Prometheus metrics on /metrics.")
+
+	fs.DurationVar(&opts.ReadHeaderTimeout, "read-header-timeout", opts.ReadHeaderTimeout, "How long to wait for a client to send request headers.")
+	fs.DurationVar(&opts.ReadTimeout, "read-timeout", opts.ReadTimeout, "How long to wait for a client to send a whole request.")
+	fs.DurationVar(&opts.WriteTimeout, "write-timeout", opts.WriteTimeout, "How long to spend writing a response.")
+	fs.DurationVar(&opts.IdleTimeout, "idle-timeout", opts.IdleTimeout, "How long to keep idle connections open.")
+}
+
+// LoadOptions resolves options from, in increasing order of precedence: defaults, the config file,
+// environment variables and flags.
+func LoadOptions(args []string) (*Options, error) {
+	// the config file is named with a flag, so we need to parse flags once to find it before layering
+	// everything else on top of it
+	located := DefaultOptions()
+	flags := flag.NewFlagSet(args[0], flag.ExitOnError)
+	BindOptions(flags, located)
+	if err := flags.Parse(args[1:]); err != nil {
+		return nil, fmt.Errorf("failed to parse flags: %v", err)
+	}
+
+	opts := DefaultOptions()
+	if located.ConfigFile != "" {
+		if err := opts.loadFile(located.ConfigFile); err != nil {
+			return nil, err
+		}
+	}
+
+	// list flags replace what lower layers set rather than appending to it, which relies on them being bound
+	// afresh for each layer
+	envFlags := flag.NewFlagSet(args[0], flag.ExitOnError)
+	BindOptions(envFlags, opts)
+	var envErr error
+	envFlags.VisitAll(func(f *flag.Flag) {
+		name := envVarFor(f.Name)
+		if name == "" {
+			return
+		}
+		value := os.Getenv(name)
+		if value == "" || envErr != nil {
+			return
+		}
+		if err := f.Value.Set(value); err != nil {
+			envErr = fmt.Errorf("failed to set %s from $%s: %v", f.Name, name, err)
+		}
+	})
+	if envErr != nil {
+		return nil, envErr
+	}
+	flags = flag.NewFlagSet(args[0], flag.ExitOnError)
+	BindOptions(flags, opts)
+	for name, emoji := range config.EmojiFromEnv() {
+		if opts.Emoji == nil {
+			opts.Emoji = map[string]string{}
+		}
+		opts.Emoji[name] = emoji
+	}
+	if err := flags.Parse(args[1:]); err != nil {
+		return nil, fmt.Errorf("failed to parse flags: %v", err)
+	}
+	return opts, nil
+}
+
+// envVarFor determines the environment variable for a flag, matching the names config.LoadFromEnv uses.
+// Flags that only make sense on the command line have no environment variable.
+func envVarFor(flag string) string {
+	switch flag {
+	case "hmac-secret":
+		return "EXPO_HMAC_SECRET"
+	case "version":
+		return ""
+	}
+	return strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// listFlag sets a config.List from a flag that may be repeated or hold comma-separated values. The first
+// value replaces whatever the list held before, so that flags override the config file.
+type listFlag struct {
+	into *config.List
+	set  bool
+	// secret lists aren't printed as defaults in usage output
+	secret bool
+}
+
+func (f *listFlag) String() string {
+	if f.into == nil || f.secret {
+		return ""
+	}
+	return strings.Join(*f.into, ",")
+}
+
+func (f *listFlag) Set(value string) error {
+	if !f.set {
+		*f.into = nil
+		f.set = true
+	}
+	*f.into = append(*f.into, config.SplitList(value)...)
+	return nil
+}
+
+func (o *Options) loadFile(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+	// YAML is a superset of JSON, so this handles both
+	decoder := yaml.NewDecoder(bytes.NewReader(raw))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(o); err != nil && err != io.EOF {
+		return fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+	return nil
 }
 
 func (o *Options) Validate() error {
-	if o.SlackToken == "" {
-		return fmt.Errorf("slack-token is required")
+	if err := o.Settings.Validate(); err != nil {
+		return err
 	}
-	if o.SlackChannel == "" {
-		return fmt.Errorf("slack-channel is required")
+	if o.PairBuildsWithin > 0 && o.SlackToken == "" {
+		return fmt.Errorf("pair-builds-within requires slack-token, as messages are combined by editing them")
 	}
-	if o.ExpoHMACSecret == "" {
-		return fmt.Errorf("hmac-secret is required")
+	if o.SlackAppToken != "" && o.SlackToken == "" {
+		return fmt.Errorf("slack-app-token requires slack-token, as replies are posted with it")
 	}
-	if o.ExpoToken == "" {
-		return fmt.Errorf("expo-token is required")
+	if o.PromoteFrom != "" && o.SlackSigningSecret == "" && o.SlackAppToken == "" {
+		return fmt.Errorf("promote-from requires slack-signing-secret or slack-app-token, to receive button presses")
+	}
+	if len(o.RollbackUsers) > 0 && o.SlackSigningSecret == "" && o.SlackAppToken == "" {
+		return fmt.Errorf("rollback-users requires slack-signing-secret or slack-app-token, to receive button presses")
+	}
+	if o.MaxConcurrency < 1 {
+		return fmt.Errorf("max-concurrency must be at least 1")
 	}
 	return nil
 }
 
 func (o *Options) Complete() (*config.Config, error) {
-	return &config.Config{
-		ExpoHMACSecret: o.ExpoHMACSecret,
-		SlackClient:    slack.New(o.SlackToken),
-		SlackChannel:   o.SlackChannel,
-		ExpoClient:     &expo.Client{Token: o.ExpoToken},
-	}, nil
+	cfg, err := config.New(o.Settings)
+	if err != nil {
+		return nil, err
+	}
+	if o.Metrics {
+		cfg.Metrics = metrics.New()
+		cfg.ExpoClient.Metrics = cfg.Metrics
+	}
+	cfg.Workers = worker.New(o.MaxConcurrency)
+	if o.PairBuildsWithin > 0 {
+		cfg.Pairs = pairing.NewStore(o.PairBuildsWithin)
+	}
+	return cfg, nil
 }
 
+const (
+	// drainTimeout bounds how long we wait for queued payloads when shutting down.
+	drainTimeout = 30 * time.Second
+	// templatesWatchInterval is how often message template files are checked for changes.
+	templatesWatchInterval = 5 * time.Second
+)
+
 func main() {
-	opts := DefaultOptions()
-	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
-	BindOptions(flags, opts)
-	if err := flags.Parse(os.Args[1:]); err != nil {
-		log.Fatalf("failed to parse flags: %v", err)
+	if len(os.Args) > 1 && os.Args[1] == "render" {
+		if err := render(os.Args[1:]); err != nil {
+			log.Fatalf("failed to render messages: %v", err)
+		}
+		return
+	}
+
+	opts, err := LoadOptions(os.Args)
+	if err != nil {
+		log.Fatalf("failed to load options: %v", err)
+	}
+	if opts.Version {
+		fmt.Println(version.Get())
+		return
 	}
 	if err := opts.Validate(); err != nil {
 		log.Fatalf("failed to validate options: %v", err)
@@ -85,29 +359,78 @@ func main() {
 	}
 
 	mux := http.NewServeMux()
-	mux.Handle("/build", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("/build", cfg.Metrics.Instrument("build", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		build.Handle(cfg, w, r)
-	}))
-	mux.Handle("/submit", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	})))
+	mux.Handle("/submit", cfg.Metrics.Instrument("submit", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		submit.Handle(cfg, w, r)
-	}))
-	mux.Handle("/update", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	})))
+	mux.Handle("/update", cfg.Metrics.Instrument("update", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		update.Handle(cfg, w, r)
-	}))
-	server := &http.Server{Addr: fmt.Sprintf(":%d", opts.Port), Handler: mux}
+	})))
+	mux.Handle("/slack/commands", cfg.Metrics.Instrument("commands", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		commands.Handle(cfg, w, r)
+	})))
+	mux.Handle("/slack/events", cfg.Metrics.Instrument("events", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		events.Handle(cfg, w, r)
+	})))
+	mux.Handle("/slack/interactions", cfg.Metrics.Instrument("interactions", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		interactions.Handle(cfg, w, r)
+	})))
+	mux.HandleFunc("/version", version.Handler)
+	mux.Handle("/webhook", cfg.Metrics.Instrument("generic", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		webhook.Handle(cfg, w, r)
+	})))
+	if cfg.Metrics != nil {
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+		if err := cfg.Metrics.Register(registry); err != nil {
+			log.Fatalf("failed to register metrics: %v", err)
+		}
+		mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	}
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%d", opts.Port),
+		Handler:           mux,
+		ReadHeaderTimeout: opts.ReadHeaderTimeout,
+		ReadTimeout:       opts.ReadTimeout,
+		WriteTimeout:      opts.WriteTimeout,
+		IdleTimeout:       opts.IdleTimeout,
+	}
 
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
+	stopped := make(chan struct{})
 	go func() {
+		defer close(stopped)
 		<-ctx.Done()
-		log.Printf("got an interrupt, shutting down server")
+		cfg.Logger.Info("got an interrupt, shutting down server")
 		if err := server.Shutdown(context.Background()); err != nil {
-			log.Printf("failed to shutdown http server: %v", err)
+			cfg.Logger.Error("failed to shutdown http server", "error", err)
+		}
+		// we've acknowledged everything in the queue to Expo already, so give it a chance to make it to Slack
+		drainCtx, drainCancel := context.WithTimeout(context.Background(), drainTimeout)
+		defer drainCancel()
+		if err := cfg.Workers.Wait(drainCtx); err != nil {
+			cfg.Logger.Error("failed to finish processing payloads before shutting down", "error", err)
 		}
 	}()
 
-	if err := server.ListenAndServe(); err != nil {
-		log.Fatalf("failed to start http server: %v", err)
+	go cfg.Templates.Watch(ctx, cfg.Logger, templatesWatchInterval)
+
+	if opts.SlackAppToken != "" {
+		go func() {
+			if err := socket.Run(ctx, cfg, opts.SlackToken, opts.SlackAppToken); err != nil && ctx.Err() == nil {
+				cfg.Logger.Error("failed to run Socket Mode connection", "error", err)
+			}
+		}()
+	}
+
+	cfg.Logger.Info("listening", "port", opts.Port)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		cfg.Logger.Error("failed to start http server", "error", err)
+		os.Exit(1)
 	}
+	<-stopped
 }
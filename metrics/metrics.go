@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics holds the collectors we expose when running as a server. A nil *Metrics is valid and records
+// nothing, which is what the serverless entrypoints use.
+type Metrics struct {
+	webhooksReceived    *prometheus.CounterVec
+	slackPostFailures   prometheus.Counter
+	expoRequestDuration *prometheus.HistogramVec
+}
+
+func New() *Metrics {
+	return &Metrics{
+		webhooksReceived: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "expo_webhooks_received_total",
+			Help: "Webhooks received, by type and the HTTP status code we responded with.",
+		}, []string{"type", "code"}),
+		slackPostFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "slack_post_failures_total",
+			Help: "Messages we failed to post to Slack.",
+		}),
+		expoRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "expo_graphql_request_duration_seconds",
+			Help:    "Latency of requests to the Expo GraphQL API, by operation.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"operation"}),
+	}
+}
+
+// Register adds all of our collectors to the registry.
+func (m *Metrics) Register(reg prometheus.Registerer) error {
+	for _, collector := range []prometheus.Collector{m.webhooksReceived, m.slackPostFailures, m.expoRequestDuration} {
+		if err := reg.Register(collector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Metrics) SlackPostFailed() {
+	if m == nil {
+		return
+	}
+	m.slackPostFailures.Inc()
+}
+
+func (m *Metrics) ObserveExpoRequest(operation string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.expoRequestDuration.WithLabelValues(operation).Observe(d.Seconds())
+}
+
+// Instrument counts requests to the handler by the status code it responds with.
+func (m *Metrics) Instrument(webhook string, next http.Handler) http.Handler {
+	if m == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		m.webhooksReceived.WithLabelValues(webhook, strconv.Itoa(recorder.status)).Inc()
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	wrote  bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wrote {
+		r.status = status
+		r.wrote = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,77 @@
+package pairing
+
+import (
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+
+	"github.com/NWACus/expo-slack-webhook/expo"
+)
+
+// Store holds on to build messages for a short window, so that the build for the other platform from the
+// same commit can be folded into the same message rather than posted on its own. State is only held in
+// memory, so builds only pair when they're handled by the same process. A nil *Store pairs nothing.
+type Store struct {
+	window time.Duration
+
+	lock    sync.Mutex
+	entries map[string]Entry
+}
+
+// Entry is a build message that's waiting for its pair.
+type Entry struct {
+	Platform expo.Platform
+	// Channel and TS identify the posted Slack message.
+	Channel string
+	TS      string
+	Blocks  []slack.Block
+	// Text is the message's plain-text summary, to be combined with the other build's.
+	Text string
+
+	expires time.Time
+}
+
+// NewStore creates a store that pairs builds finishing within the window of each other.
+func NewStore(window time.Duration) *Store {
+	return &Store{window: window, entries: map[string]Entry{}}
+}
+
+// Hold records the message posted for a build, keyed by its commit and update channel.
+func (s *Store) Hold(commit, channel string, e Entry) {
+	if s == nil || commit == "" || e.TS == "" {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := time.Now()
+	for key, held := range s.entries {
+		if now.After(held.expires) {
+			delete(s.entries, key)
+		}
+	}
+	e.expires = now.Add(s.window)
+	s.entries[entryKey(commit, channel, e.Channel)] = e
+}
+
+// Take returns the message held for a build of another platform from the same commit and update channel,
+// posted to the Slack channel, if there is one. A message can only be taken once, so that builds pair up
+// rather than accumulating.
+func (s *Store) Take(commit, channel, slackChannel string, platform expo.Platform) (Entry, bool) {
+	if s == nil || commit == "" {
+		return Entry{}, false
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	key := entryKey(commit, channel, slackChannel)
+	e, ok := s.entries[key]
+	if !ok || time.Now().After(e.expires) || e.Platform.Equal(platform) {
+		return Entry{}, false
+	}
+	delete(s.entries, key)
+	return e, true
+}
+
+func entryKey(commit, channel, slackChannel string) string {
+	return slackChannel + "/" + channel + "/" + commit
+}
@@ -0,0 +1,232 @@
+// Package releases keeps track of the latest builds, submissions and OTA updates of each app on the
+// production and preview channels, and keeps a pinned Slack message showing them up to date.
+package releases
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+
+	"github.com/NWACus/expo-slack-webhook/expo"
+	"github.com/NWACus/expo-slack-webhook/store"
+)
+
+// Channels are the update channels whose releases are tracked, in the order they're shown.
+var Channels = []string{"production", "preview"}
+
+// platforms are shown in this order.
+var platforms = []expo.Platform{expo.PlatformIOS, expo.PlatformAndroid}
+
+// Release is the latest known state of one platform on one channel.
+type Release struct {
+	// Build and Submitted are the versions of the latest successful build and store submission.
+	Build     string `json:"build,omitempty"`
+	Submitted string `json:"submitted,omitempty"`
+	// Update is the commit of the latest OTA update; RolledBack is set instead when it went back to the
+	// update embedded in the build.
+	Update     string `json:"update,omitempty"`
+	RolledBack bool   `json:"rolledBack,omitempty"`
+}
+
+// appState is what we remember for each app: its releases by channel and platform, and the pinned
+// messages showing them by Slack channel.
+type appState struct {
+	Name     string                                `json:"name"`
+	Releases map[string]map[expo.Platform]*Release `json:"releases"`
+	Pins     map[string]string                     `json:"pins"`
+}
+
+// State records releases in the backend and keeps a pinned message in each Slack channel they're posted
+// to showing the latest of them. A nil *State is valid and records nothing.
+type State struct {
+	client  *slack.Client
+	channel string
+	backend store.Store
+	// lock serialises changes made by this process; others sharing the backend may still race us, in
+	// which case the next release puts things right
+	lock sync.Mutex
+}
+
+// NewState creates a state that keeps releases in the backend. When channel is set, the pinned message is
+// kept there rather than in each channel releases are posted to.
+func NewState(client *slack.Client, channel string, backend store.Store) *State {
+	return &State{client: client, channel: channel, backend: backend}
+}
+
+// Built records a successful build of the version.
+func (s *State) Built(ctx context.Context, slackChannel, appId, appName, channel string, platform expo.Platform, version string) error {
+	return s.record(ctx, slackChannel, appId, appName, channel, platform, func(r *Release) {
+		r.Build = version
+	})
+}
+
+// Submitted records a successful store submission of the version.
+func (s *State) Submitted(ctx context.Context, slackChannel, appId, appName, channel string, platform expo.Platform, version string) error {
+	return s.record(ctx, slackChannel, appId, appName, channel, platform, func(r *Release) {
+		r.Submitted = version
+	})
+}
+
+// Updated records an OTA update for the commit, or a rollback to the embedded update.
+func (s *State) Updated(ctx context.Context, slackChannel, appId, appName, channel string, platform expo.Platform, commit string, rolledBack bool) error {
+	return s.record(ctx, slackChannel, appId, appName, channel, platform, func(r *Release) {
+		r.Update, r.RolledBack = commit, rolledBack
+	})
+}
+
+func (s *State) record(ctx context.Context, slackChannel, appId, appName, channel string, platform expo.Platform, change func(*Release)) error {
+	if s == nil || !slices.Contains(Channels, channel) {
+		return nil
+	}
+	if s.channel != "" {
+		slackChannel = s.channel
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	state, err := s.load(ctx, appId)
+	if err != nil {
+		return err
+	}
+	if appName != "" {
+		state.Name = appName
+	}
+	if state.Releases[channel] == nil {
+		state.Releases[channel] = map[expo.Platform]*Release{}
+	}
+	if state.Releases[channel][platform] == nil {
+		state.Releases[channel][platform] = &Release{}
+	}
+	change(state.Releases[channel][platform])
+	if _, ok := state.Pins[slackChannel]; !ok && slackChannel != "" {
+		state.Pins[slackChannel] = ""
+	}
+
+	// we save before touching Slack, so that a failure there doesn't lose the release
+	if err := s.save(ctx, appId, state); err != nil {
+		return err
+	}
+	var errs []string
+	blocks := blocksFor(appId, state)
+	for pinned, ts := range state.Pins {
+		ts, err := s.pin(ctx, pinned, ts, blocks)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", pinned, err))
+			continue
+		}
+		state.Pins[pinned] = ts
+	}
+	if err := s.save(ctx, appId, state); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to update pinned messages: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// pin edits the pinned message in the channel, or posts and pins one if there's none, returning its
+// timestamp.
+func (s *State) pin(ctx context.Context, channel, ts string, blocks []slack.Block) (string, error) {
+	if ts != "" {
+		_, _, _, err := s.client.UpdateMessageContext(ctx, channel, ts, slack.MsgOptionBlocks(blocks...))
+		if err == nil {
+			return ts, nil
+		}
+		// someone may have deleted it, in which case we start again
+		if err.Error() != "message_not_found" {
+			return ts, err
+		}
+	}
+	_, ts, err := s.client.PostMessageContext(ctx, channel, slack.MsgOptionBlocks(blocks...), slack.MsgOptionDisableLinkUnfurl())
+	if err != nil {
+		return "", err
+	}
+	if err := s.client.AddPinContext(ctx, channel, slack.NewRefToMessage(channel, ts)); err != nil {
+		return ts, fmt.Errorf("failed to pin message: %v", err)
+	}
+	return ts, nil
+}
+
+func (s *State) load(ctx context.Context, appId string) (*appState, error) {
+	state := &appState{}
+	raw, ok, err := s.backend.Get(ctx, entryKey(appId))
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up releases: %v", err)
+	}
+	if ok {
+		if err := json.Unmarshal([]byte(raw), state); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal releases for app %s: %v", appId, err)
+		}
+	}
+	if state.Releases == nil {
+		state.Releases = map[string]map[expo.Platform]*Release{}
+	}
+	if state.Pins == nil {
+		state.Pins = map[string]string{}
+	}
+	return state, nil
+}
+
+func (s *State) save(ctx context.Context, appId string, state *appState) error {
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal releases: %v", err)
+	}
+	// releases are current until replaced, so they never expire
+	if err := s.backend.Set(ctx, entryKey(appId), string(raw), 0); err != nil {
+		return fmt.Errorf("failed to remember releases: %v", err)
+	}
+	return nil
+}
+
+// blocksFor describes the releases of the app, one line per platform on each channel.
+func blocksFor(appId string, state *appState) []slack.Block {
+	name := state.Name
+	if name == "" {
+		name = appId
+	}
+	lines := []string{fmt.Sprintf(":pushpin: *Current releases of %s*", name)}
+	for _, channel := range Channels {
+		var platformLines []string
+		for _, platform := range platforms {
+			release := state.Releases[channel][platform]
+			if release == nil {
+				continue
+			}
+			var parts []string
+			if release.Build != "" {
+				parts = append(parts, "built "+release.Build)
+			}
+			if release.Submitted != "" {
+				parts = append(parts, "submitted "+release.Submitted)
+			}
+			switch {
+			case release.RolledBack:
+				parts = append(parts, "OTA update rolled back to the embedded update")
+			case release.Update != "":
+				parts = append(parts, fmt.Sprintf("OTA update `%s`", expo.ShortCommit(release.Update)))
+			}
+			platformLines = append(platformLines, fmt.Sprintf("• %s: %s", expo.PlatformDisplay(nil, platform), strings.Join(parts, " · ")))
+		}
+		if len(platformLines) > 0 {
+			lines = append(lines, fmt.Sprintf("*%s*", channel))
+			lines = append(lines, platformLines...)
+		}
+	}
+	now := time.Now()
+	return []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, strings.Join(lines, "\n"), false, false), nil, nil),
+		slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType, "Updated "+expo.SlackDate(now), false, false)),
+	}
+}
+
+func entryKey(appId string) string {
+	return "releases/" + appId
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/slack-go/slack"
+
+	"github.com/NWACus/expo-slack-webhook/api/build"
+	"github.com/NWACus/expo-slack-webhook/api/submit"
+	"github.com/NWACus/expo-slack-webhook/api/update"
+	"github.com/NWACus/expo-slack-webhook/config"
+	"github.com/NWACus/expo-slack-webhook/expo"
+	"github.com/NWACus/expo-slack-webhook/i18n"
+	"github.com/NWACus/expo-slack-webhook/templates"
+)
+
+// RenderOptions configure the render subcommand, which prints the messages a sample payload would be posted
+// as, so that message templates can be checked before they're deployed.
+type RenderOptions struct {
+	PayloadPath          string
+	Event                string
+	MessageTemplates     string
+	MessageTemplatesFile string
+	MessageTemplatesDir  string
+	Locale               string
+	MessageCatalogs      string
+	BuildLayout          string
+}
+
+func BindRenderOptions(fs *flag.FlagSet, opts *RenderOptions) {
+	fs.StringVar(&opts.PayloadPath, "payload", opts.PayloadPath, "Path to a JSON file holding a sample payload.")
+	fs.StringVar(&opts.Event, "event", opts.Event, "Type of payload, one of build, submit or update.")
+	fs.StringVar(&opts.MessageTemplates, "message-templates", opts.MessageTemplates, "Go templates replacing the default wording of messages, by name, like build.title.")
+	fs.StringVar(&opts.MessageTemplatesFile, "message-templates-file", opts.MessageTemplatesFile, "File of Go templates replacing the default wording of messages.")
+	fs.StringVar(&opts.MessageTemplatesDir, "message-templates-dir", opts.MessageTemplatesDir, "Directory of Go templates replacing the default wording of messages, in build.tmpl, submit.tmpl and update.tmpl.")
+	fs.StringVar(&opts.Locale, "locale", opts.Locale, "Locale to render messages in, translated by its catalog in message-catalogs. Defaults to English.")
+	fs.StringVar(&opts.MessageCatalogs, "message-catalogs", opts.MessageCatalogs, "YAML or JSON file of message translations for each locale, keyed by their English text.")
+	fs.StringVar(&opts.BuildLayout, "build-layout", opts.BuildLayout, "Layout of build messages, either text or fields.")
+}
+
+func (o *RenderOptions) Validate() error {
+	if o.PayloadPath == "" {
+		return fmt.Errorf("payload is required")
+	}
+	switch o.Event {
+	case "build", "submit", "update":
+	case "":
+		return fmt.Errorf("event is required")
+	default:
+		return fmt.Errorf("event must be one of build, submit or update")
+	}
+	return config.ValidateBuildLayout(o.BuildLayout)
+}
+
+// render runs the render subcommand with its arguments, printing each message in a form that can be pasted
+// into Slack's Block Kit Builder. Nothing is looked up in Expo, so context like the previous build is left
+// out; EMOJI_* variables are honoured as they are by the server.
+func render(args []string) error {
+	opts := &RenderOptions{}
+	flags := flag.NewFlagSet(args[0], flag.ExitOnError)
+	BindRenderOptions(flags, opts)
+	if err := flags.Parse(args[1:]); err != nil {
+		return fmt.Errorf("failed to parse flags: %v", err)
+	}
+	if err := opts.Validate(); err != nil {
+		return fmt.Errorf("failed to validate options: %v", err)
+	}
+
+	payload, err := os.ReadFile(opts.PayloadPath)
+	if err != nil {
+		return fmt.Errorf("failed to read payload file: %v", err)
+	}
+	emoji, err := expo.NewEmoji(config.EmojiFromEnv())
+	if err != nil {
+		return err
+	}
+	catalog, err := i18n.Load(opts.MessageCatalogs, opts.Locale)
+	if err != nil {
+		return err
+	}
+	set, err := templates.Load(emoji, catalog, opts.MessageTemplatesFile, opts.MessageTemplatesDir, opts.MessageTemplates)
+	if err != nil {
+		return err
+	}
+	cfg := &config.Config{Emoji: emoji, Catalog: catalog, Templates: set, BuildLayout: opts.BuildLayout}
+
+	var rendered [][]slack.Block
+	switch opts.Event {
+	case "build":
+		blocks, err := build.Render(cfg, payload)
+		if err != nil {
+			return err
+		}
+		rendered = append(rendered, blocks)
+	case "submit":
+		blocks, err := submit.Render(cfg, payload)
+		if err != nil {
+			return err
+		}
+		rendered = append(rendered, blocks)
+	case "update":
+		if rendered, err = update.Render(cfg, payload); err != nil {
+			return err
+		}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	// Slack's link syntax uses angle brackets, which are much easier to read unescaped
+	encoder.SetEscapeHTML(false)
+	for _, blocks := range rendered {
+		if err := encoder.Encode(struct {
+			Blocks []slack.Block `json:"blocks"`
+		}{Blocks: blocks}); err != nil {
+			return fmt.Errorf("failed to print blocks: %v", err)
+		}
+	}
+	return nil
+}
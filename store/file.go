@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// File is a Store kept in memory and written through to a JSON file, so that state survives restarts of a
+// single server. It isn't safe for several processes to share a file.
+type File struct {
+	*Memory
+	path string
+}
+
+// NewFile loads the store from the file at the path, which is created when first written if it doesn't
+// exist yet.
+func NewFile(path string) (*File, error) {
+	if path == "" {
+		return nil, fmt.Errorf("missing path for file store")
+	}
+	f := &File{Memory: NewMemory(), path: path}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store: %v", err)
+	}
+	if err := json.Unmarshal(raw, &f.entries); err != nil {
+		return nil, fmt.Errorf("failed to parse store %s: %v", path, err)
+	}
+	return f, nil
+}
+
+func (f *File) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.set(key, value, ttl)
+	return f.save()
+}
+
+func (f *File) Delete(ctx context.Context, key string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	delete(f.entries, key)
+	return f.save()
+}
+
+// save writes the store out, replacing the file atomically so that a crash can't leave it half written.
+// The lock must be held.
+func (f *File) save() error {
+	raw, err := json.Marshal(f.entries)
+	if err != nil {
+		return fmt.Errorf("failed to marshal store: %v", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write store: %v", err)
+	}
+	if _, err := tmp.Write(raw); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write store: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write store: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("failed to write store: %v", err)
+	}
+	return nil
+}
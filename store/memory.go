@@ -0,0 +1,67 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory is a Store that only lasts as long as the process.
+type Memory struct {
+	lock    sync.Mutex
+	entries map[string]entry
+}
+
+type entry struct {
+	Value string `json:"value"`
+	// Expires is zero for entries that don't expire.
+	Expires time.Time `json:"expires"`
+}
+
+func (e entry) expired(now time.Time) bool {
+	return !e.Expires.IsZero() && now.After(e.Expires)
+}
+
+func NewMemory() *Memory {
+	return &Memory{entries: map[string]entry{}}
+}
+
+func (m *Memory) Get(_ context.Context, key string) (string, bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	e, ok := m.entries[key]
+	if !ok || e.expired(time.Now()) {
+		return "", false, nil
+	}
+	return e.Value, true, nil
+}
+
+func (m *Memory) Set(_ context.Context, key, value string, ttl time.Duration) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.set(key, value, ttl)
+	return nil
+}
+
+func (m *Memory) Delete(_ context.Context, key string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.entries, key)
+	return nil
+}
+
+// set stores the value and drops expired entries, so that the map doesn't grow forever. The lock must be
+// held.
+func (m *Memory) set(key, value string, ttl time.Duration) {
+	now := time.Now()
+	for k, e := range m.entries {
+		if e.expired(now) {
+			delete(m.entries, k)
+		}
+	}
+	e := entry{Value: value}
+	if ttl > 0 {
+		e.Expires = now.Add(ttl)
+	}
+	m.entries[key] = e
+}
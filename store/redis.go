@@ -0,0 +1,54 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisTimeout bounds each command when the context has no deadline of its own.
+const redisTimeout = 5 * time.Second
+
+// Redis is a Store in a Redis server. Connections are pooled, and made again when they fail.
+type Redis struct {
+	client *redis.Client
+}
+
+// NewRedis configures a store for the server at the URL; connections are made when first needed.
+func NewRedis(u *url.URL) (*Redis, error) {
+	opts, err := redis.ParseURL(u.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %v", err)
+	}
+	if opts.Username != "" && opts.Password == "" {
+		// redis://secret@host is a common way to write a password without a username
+		opts.Username, opts.Password = "", opts.Username
+	}
+	opts.ReadTimeout = redisTimeout
+	opts.WriteTimeout = redisTimeout
+	return &Redis{client: redis.NewClient(opts)}, nil
+}
+
+func (r *Redis) Get(ctx context.Context, key string) (string, bool, error) {
+	value, err := r.client.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+func (r *Redis) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	// zero means no expiry to Redis, as it does to us
+	return r.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (r *Redis) Delete(ctx context.Context, key string) error {
+	return r.client.Del(ctx, key).Err()
+}
@@ -0,0 +1,104 @@
+package store
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestRedis(t *testing.T, server *miniredis.Miniredis, raw string) *Redis {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("failed to parse URL: %v", err)
+	}
+	r, err := NewRedis(u)
+	if err != nil {
+		t.Fatalf("failed to configure store: %v", err)
+	}
+	t.Cleanup(func() { _ = r.client.Close() })
+	return r
+}
+
+func TestRedis(t *testing.T) {
+	server := miniredis.RunT(t)
+	r := newTestRedis(t, server, "redis://"+server.Addr())
+	ctx := context.Background()
+
+	if _, ok, err := r.Get(ctx, "missing"); err != nil || ok {
+		t.Fatalf("expected a missing key to be reported as such, got ok=%v, err=%v", ok, err)
+	}
+	if err := r.Set(ctx, "key", "value", time.Minute); err != nil {
+		t.Fatalf("failed to set: %v", err)
+	}
+	value, ok, err := r.Get(ctx, "key")
+	if err != nil || !ok || value != "value" {
+		t.Fatalf("expected value, got %q, ok=%v, err=%v", value, ok, err)
+	}
+	if ttl := server.TTL("key"); ttl != time.Minute {
+		t.Errorf("expected a TTL of a minute, got %v", ttl)
+	}
+	server.FastForward(time.Minute)
+	if _, ok, _ := r.Get(ctx, "key"); ok {
+		t.Errorf("expected key to expire")
+	}
+
+	if err := r.Set(ctx, "forever", "value", 0); err != nil {
+		t.Fatalf("failed to set: %v", err)
+	}
+	if ttl := server.TTL("forever"); ttl != 0 {
+		t.Errorf("expected no TTL, got %v", ttl)
+	}
+	if err := r.Delete(ctx, "forever"); err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+	if server.Exists("forever") {
+		t.Errorf("expected key to be deleted")
+	}
+}
+
+func TestRedisURL(t *testing.T) {
+	for _, tc := range []struct {
+		name, userinfo string
+	}{
+		{name: "password only", userinfo: ":secret@"},
+		{name: "password as username", userinfo: "secret@"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server := miniredis.RunT(t)
+			server.RequireAuth("secret")
+			r := newTestRedis(t, server, "redis://"+tc.userinfo+server.Addr()+"/2")
+			if err := r.Set(context.Background(), "key", "value", 0); err != nil {
+				t.Fatalf("failed to set: %v", err)
+			}
+			server.Select(2)
+			if got, _ := server.Get("key"); got != "value" {
+				t.Errorf("expected the key in database 2, got %q", got)
+			}
+		})
+	}
+}
+
+func TestRedisReconnects(t *testing.T) {
+	server := miniredis.RunT(t)
+	r := newTestRedis(t, server, "redis://"+server.Addr())
+	ctx := context.Background()
+	if err := r.Set(ctx, "key", "value", 0); err != nil {
+		t.Fatalf("failed to set: %v", err)
+	}
+
+	server.Close()
+	if _, _, err := r.Get(ctx, "key"); err == nil {
+		t.Fatalf("expected an error while the server is down")
+	}
+	if err := server.Restart(); err != nil {
+		t.Fatalf("failed to restart server: %v", err)
+	}
+	value, ok, err := r.Get(ctx, "key")
+	if err != nil || !ok || value != "value" {
+		t.Fatalf("expected value after reconnecting, got %q, ok=%v, err=%v", value, ok, err)
+	}
+}
@@ -0,0 +1,44 @@
+// Package store keeps the little state we need between webhooks, like the Slack messages we posted, in a
+// backend that may outlive the process.
+package store
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Store holds string values by key until they expire.
+type Store interface {
+	// Get returns the value for the key, and whether there was one.
+	Get(ctx context.Context, key string) (string, bool, error)
+	// Set stores the value for the key until the TTL passes; a zero TTL keeps it indefinitely.
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	// Delete removes the key, if it's there.
+	Delete(ctx context.Context, key string) error
+}
+
+// Open connects to the store described by the URL:
+//
+//   - memory: keeps state in this process only
+//   - file:///path/to/state.json keeps state in a JSON file, for a single server that restarts
+//   - redis://[:password@]host:port[/db] or rediss:// for TLS keeps state in Redis, which also works for
+//     the serverless functions
+func Open(raw string) (Store, error) {
+	if raw == "" || raw == "memory:" {
+		return NewMemory(), nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid store URL: %v", err)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "file":
+		return NewFile(u.Path)
+	case "redis", "rediss":
+		return NewRedis(u)
+	}
+	return nil, fmt.Errorf("unsupported store %q, expected memory:, file:// or redis://", u.Scheme)
+}
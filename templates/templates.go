@@ -0,0 +1,330 @@
+// Package templates renders the text of our Slack messages with Go's text/template, so that messages can be
+// reworded without forking. The defaults in default.tmpl reproduce the built-in wording, and any of them can
+// be replaced by defining a template with the same name.
+package templates
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/slack-go/slack/slackutilsx"
+
+	"github.com/NWACus/expo-slack-webhook/expo"
+	"github.com/NWACus/expo-slack-webhook/i18n"
+	"github.com/NWACus/expo-slack-webhook/issues"
+)
+
+//go:embed default.tmpl
+var defaults string
+
+// funcs are the helpers available to templates, besides those depending on the set's emoji and catalog;
+// see Set.funcs.
+var funcs = template.FuncMap{
+	"version":       expo.FormatVersion,
+	"buildVersion":  expo.FormatBuildVersion,
+	"commitURL":     expo.CommitURL,
+	"compareURL":    expo.CompareURL,
+	"shortCommit":   expo.ShortCommit,
+	"commitSummary": commitSummary,
+	"slackDate":     slackDate,
+	"expoLink":      expoLink,
+	"link":          link,
+	"githubCompare": githubCompare,
+	"fileSize":      expo.FormatSize,
+	"issueLinks":    issueLinks,
+}
+
+// EventFiles are the files in a templates directory that are loaded, in order; each holds the templates
+// for one kind of message, like build.title and build.details in build.tmpl.
+var EventFiles = []string{"build.tmpl", "submit.tmpl", "update.tmpl"}
+
+// Set holds the templates messages are rendered with. A nil *Set renders with the defaults.
+type Set struct {
+	// emoji are the overrides platformEmoji and statusEmoji use
+	emoji *expo.Emoji
+	// catalog translates text wrapped in t, and that of the helpers
+	catalog *i18n.Catalog
+	// files and text are what the set was loaded from, to be read again by Reload
+	files []file
+	text  string
+
+	lock     sync.RWMutex
+	template *template.Template
+	modified map[string]time.Time
+}
+
+var defaultSet = mustNew()
+
+func mustNew() *Set {
+	set, err := New(nil, nil)
+	if err != nil {
+		panic(fmt.Sprintf("failed to parse default templates: %v", err))
+	}
+	return set
+}
+
+// New parses the default templates, then each of the texts in turn, so that templates defined in later
+// texts replace those of the same name defined before them. Platform and status emoji are rendered with the
+// overrides in emoji, and text is translated by the catalog.
+func New(emoji *expo.Emoji, catalog *i18n.Catalog, texts ...string) (*Set, error) {
+	set := &Set{emoji: emoji, catalog: catalog}
+	names := make([]string, len(texts))
+	for i := range texts {
+		names[i] = fmt.Sprintf("custom-%d", i)
+	}
+	root, err := set.parse(names, texts)
+	if err != nil {
+		return nil, err
+	}
+	set.template = root
+	return set, nil
+}
+
+// parse parses the defaults and then each of the texts, named so that errors say where they are.
+func (s *Set) parse(names, texts []string) (*template.Template, error) {
+	root, err := template.New("default").Funcs(funcs).Funcs(s.funcs()).Parse(defaults)
+	if err != nil {
+		return nil, err
+	}
+	for i, text := range texts {
+		if root, err = root.New(names[i]).Parse(text); err != nil {
+			return nil, fmt.Errorf("failed to parse message templates: %v", err)
+		}
+	}
+	return root, nil
+}
+
+// Load parses the templates in the file at path, if any, then those in each of EventFiles in dir, if any,
+// and then those in text, so that one can be tweaked without editing the others. Files in dir that don't
+// exist are skipped. Platform and status emoji are rendered with the overrides in emoji, and text is
+// translated by the catalog.
+func Load(emoji *expo.Emoji, catalog *i18n.Catalog, path, dir, text string) (*Set, error) {
+	set := &Set{emoji: emoji, catalog: catalog, text: text}
+	if path != "" {
+		set.files = append(set.files, file{path: path})
+	}
+	if dir != "" {
+		for _, name := range EventFiles {
+			set.files = append(set.files, file{path: filepath.Join(dir, name), optional: true})
+		}
+	}
+	if err := set.Reload(); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// Reload reads and parses the set's files again. When they fail to parse, the templates already loaded
+// are kept and the error is returned.
+func (s *Set) Reload() error {
+	if s == nil {
+		return nil
+	}
+	var names, texts []string
+	modified := map[string]time.Time{}
+	for _, f := range s.files {
+		modified[f.path] = modTime(f.path)
+		raw, err := os.ReadFile(f.path)
+		if errors.Is(err, fs.ErrNotExist) && f.optional {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read message templates: %v", err)
+		}
+		names = append(names, f.path)
+		texts = append(texts, string(raw))
+	}
+	if s.text != "" {
+		names = append(names, "custom")
+		texts = append(texts, s.text)
+	}
+	root, err := s.parse(names, texts)
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.template = root
+	s.modified = modified
+	return nil
+}
+
+// Watch reloads the set whenever one of its files changes, checking every interval until the context is
+// done. Templates that fail to parse are logged, and the last good ones kept until they're fixed.
+func (s *Set) Watch(ctx context.Context, logger *slog.Logger, interval time.Duration) {
+	if s == nil || len(s.files) == 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !s.changed() {
+			continue
+		}
+		if err := s.Reload(); err != nil {
+			logger.Error("failed to reload message templates", "error", err)
+			// don't log the same error every interval
+			s.lock.Lock()
+			for _, f := range s.files {
+				s.modified[f.path] = modTime(f.path)
+			}
+			s.lock.Unlock()
+			continue
+		}
+		logger.Info("reloaded message templates")
+	}
+}
+
+// file is a file of templates; optional ones are skipped while they don't exist.
+type file struct {
+	path     string
+	optional bool
+}
+
+// changed reports whether any of the set's files were modified, created or removed since they were read.
+func (s *Set) changed() bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	for _, f := range s.files {
+		if !modTime(f.path).Equal(s.modified[f.path]) {
+			return true
+		}
+	}
+	return false
+}
+
+// modTime is when the file at path was last modified, or the zero time if it doesn't exist.
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// Render executes the named template with the data. Leading and trailing whitespace is trimmed, so that
+// templates can be laid out across lines; an empty result means the text should be left out.
+func (s *Set) Render(name string, data any) (string, error) {
+	if s == nil {
+		s = defaultSet
+	}
+	s.lock.RLock()
+	root := s.template
+	s.lock.RUnlock()
+	var out strings.Builder
+	if err := root.ExecuteTemplate(&out, name, data); err != nil {
+		return "", fmt.Errorf("failed to render %s template: %v", name, err)
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// funcs are the helpers that depend on the set's emoji and catalog.
+func (s *Set) funcs() template.FuncMap {
+	return template.FuncMap{
+		"t":                 s.catalog.T,
+		"platformEmoji":     s.emoji.Platform,
+		"statusEmoji":       s.emoji.Status,
+		"distributionEmoji": s.emoji.Distribution,
+		"platformDisplay":   func(platform expo.Platform) string { return expo.PlatformDisplay(s.catalog, platform) },
+		"statusDisplay":     func(status expo.Status) string { return expo.StatusDisplay(s.catalog, status) },
+		"actor":             func(actor expo.Actor) string { return expo.FormatActor(s.catalog, actor) },
+		"timestamp":         s.timestamp,
+		"relativeTime":      s.relativeTime,
+		"humanDuration":     s.humanDuration,
+		"duration":          func(d time.Duration) string { return expo.FormatDuration(s.catalog, d) },
+	}
+}
+
+// timestamp describes an RFC 3339 timestamp, as the API sends them, in the reader's time zone and relative to
+// now; see expo.FormatTimestamp.
+func (s *Set) timestamp(value string) (string, error) {
+	t, err := parseTime(value)
+	if err != nil {
+		return "", err
+	}
+	return expo.FormatTimestamp(s.catalog, t), nil
+}
+
+// slackDate is the Slack date token for an RFC 3339 timestamp, which shows it in the reader's time zone.
+func slackDate(value string) (string, error) {
+	t, err := parseTime(value)
+	if err != nil {
+		return "", err
+	}
+	return expo.SlackDate(t), nil
+}
+
+// relativeTime describes roughly how long ago an RFC 3339 timestamp was, like "3 hours ago".
+func (s *Set) relativeTime(value string) (string, error) {
+	t, err := parseTime(value)
+	if err != nil {
+		return "", err
+	}
+	return s.catalog.T("%s ago", expo.FormatDuration(s.catalog, time.Since(t))), nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse time %q: %v", value, err)
+	}
+	return t, nil
+}
+
+// humanDuration describes roughly how long passed between two RFC 3339 timestamps, like how long a build
+// took from its createdAt to its completedAt.
+func (s *Set) humanDuration(from, to string) (string, error) {
+	start, err := parseTime(from)
+	if err != nil {
+		return "", err
+	}
+	end, err := parseTime(to)
+	if err != nil {
+		return "", err
+	}
+	return expo.FormatDuration(s.catalog, end.Sub(start)), nil
+}
+
+// commitSummary is the first line of a commit message, escaped so that Slack shows it as it was written.
+func commitSummary(message string) string {
+	return slackutilsx.EscapeMessage(expo.CommitSummary(message))
+}
+
+// link labels a URL, in Slack's mrkdwn.
+func link(url, label string) string {
+	return fmt.Sprintf("<%s|%s>", url, label)
+}
+
+// expoLink links to a page of the project on expo.dev; see expo.ProjectURL.
+func expoLink(project expo.Project, kind, id, label string) string {
+	return link(expo.ProjectURL(project, kind, id), label)
+}
+
+// issueLinks links to each of the issues, labelled by its key.
+func issueLinks(found []issues.Issue) string {
+	var links []string
+	for _, issue := range found {
+		links = append(links, link(issue.URL, issue.Key))
+	}
+	return strings.Join(links, ", ")
+}
+
+// githubCompare links to the changes between two commits in the GitHub repository, given as owner/name.
+func githubCompare(repository, from, to, label string) string {
+	return link(expo.CompareURL(repository, from, to), label)
+}
@@ -0,0 +1,81 @@
+package threads
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/NWACus/expo-slack-webhook/store"
+)
+
+// Store remembers which Slack message we posted for a commit in each channel, so that later notifications
+// for the same commit in that channel can be posted as replies in its thread. Threads only form when
+// related webhooks are handled by processes sharing the backend, which for the in-memory backend means
+// the same process. A nil *Store is valid and remembers nothing.
+type Store struct {
+	backend store.Store
+	ttl     time.Duration
+}
+
+// NewStore creates a store that keeps messages in the backend, forgetting them after the TTL.
+func NewStore(backend store.Store, ttl time.Duration) *Store {
+	return &Store{backend: backend, ttl: ttl}
+}
+
+// Remember records the timestamp of the message posted for the commit in the channel.
+func (s *Store) Remember(ctx context.Context, channel, commit, ts string) error {
+	if s == nil || commit == "" || ts == "" {
+		return nil
+	}
+	return s.backend.Set(ctx, entryKey(channel, commit), ts, s.ttl)
+}
+
+// Lookup returns the timestamp of the message posted for the commit in the channel, or an empty string if
+// there's none.
+func (s *Store) Lookup(ctx context.Context, channel, commit string) (string, error) {
+	if s == nil || commit == "" {
+		return "", nil
+	}
+	ts, _, err := s.backend.Get(ctx, entryKey(channel, commit))
+	return ts, err
+}
+
+// Message identifies a posted Slack message.
+type Message struct {
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+}
+
+// RememberBuild records the message posted for the build, so that the submission of exactly that build can
+// be threaded under it.
+func (s *Store) RememberBuild(ctx context.Context, buildId string, msg Message) error {
+	if s == nil || buildId == "" || msg.TS == "" {
+		return nil
+	}
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %v", err)
+	}
+	return s.backend.Set(ctx, "build/"+buildId, string(raw), s.ttl)
+}
+
+// LookupBuild returns the message posted for the build, if there is one.
+func (s *Store) LookupBuild(ctx context.Context, buildId string) (Message, bool, error) {
+	if s == nil || buildId == "" {
+		return Message{}, false, nil
+	}
+	raw, ok, err := s.backend.Get(ctx, "build/"+buildId)
+	if err != nil || !ok {
+		return Message{}, false, err
+	}
+	var msg Message
+	if err := json.Unmarshal([]byte(raw), &msg); err != nil {
+		return Message{}, false, fmt.Errorf("failed to unmarshal message for build %s: %v", buildId, err)
+	}
+	return msg, true, nil
+}
+
+func entryKey(channel, commit string) string {
+	return "thread/" + channel + "/" + commit
+}
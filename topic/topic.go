@@ -0,0 +1,82 @@
+// Package topic keeps Slack channel topics showing the latest production release of each platform, so the
+// current version is visible at a glance.
+package topic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/slack-go/slack"
+
+	"github.com/NWACus/expo-slack-webhook/expo"
+	"github.com/NWACus/expo-slack-webhook/store"
+)
+
+// ReleaseChannel is the update channel whose builds and submissions count as releases.
+const ReleaseChannel = "production"
+
+// maxLength is the longest topic Slack accepts, in characters.
+const maxLength = 250
+
+// platforms are listed in the topic in this order.
+var platforms = []expo.Platform{expo.PlatformIOS, expo.PlatformAndroid}
+
+// Setter sets channel topics with a bot token. The latest version of each platform is remembered in the
+// backend, so the topic can show both platforms when only one was released. A nil *Setter is valid and
+// sets nothing.
+type Setter struct {
+	client  *slack.Client
+	channel string
+	backend store.Store
+}
+
+// NewSetter creates a setter for topics. When channel is set, its topic is set rather than that of the
+// channel the release was posted to.
+func NewSetter(client *slack.Client, channel string, backend store.Store) *Setter {
+	return &Setter{client: client, channel: channel, backend: backend}
+}
+
+// Released records the version of the app released for the platform, and sets the topic of the channel
+// the release was posted to, or the configured channel, to the latest versions.
+func (s *Setter) Released(ctx context.Context, channel, appId, appName string, platform expo.Platform, version string) error {
+	if s == nil {
+		return nil
+	}
+	if s.channel != "" {
+		channel = s.channel
+	}
+	if err := s.backend.Set(ctx, entryKey(channel, appId, platform), version, 0); err != nil {
+		return fmt.Errorf("failed to remember version: %v", err)
+	}
+
+	var released []string
+	for _, platform := range platforms {
+		version, ok, err := s.backend.Get(ctx, entryKey(channel, appId, platform))
+		if err != nil {
+			return fmt.Errorf("failed to look up version: %v", err)
+		}
+		if ok {
+			released = append(released, expo.PlatformDisplay(nil, platform)+" "+version)
+		}
+	}
+	topic := fmt.Sprintf("%s in %s: %s", appName, ReleaseChannel, strings.Join(released, " · "))
+	// the separator is more than a byte, so we count runes to avoid cutting one in half
+	if runes := []rune(topic); len(runes) > maxLength {
+		topic = string(runes[:maxLength])
+	}
+
+	// setting the topic posts a notice in the channel, so we don't repeat one already set
+	info, err := s.client.GetConversationInfoContext(ctx, &slack.GetConversationInfoInput{ChannelID: channel})
+	if err == nil && info.Topic.Value == topic {
+		return nil
+	}
+	if _, err := s.client.SetTopicOfConversationContext(ctx, channel, topic); err != nil {
+		return fmt.Errorf("failed to set topic: %v", err)
+	}
+	return nil
+}
+
+func entryKey(channel, appId string, platform expo.Platform) string {
+	return "topic/" + channel + "/" + appId + "/" + string(platform)
+}
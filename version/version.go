@@ -0,0 +1,85 @@
+// Package version reports which revision of the webhook is running.
+package version
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// These may be set at build time with, for example:
+//
+//	go build -ldflags "-X github.com/NWACus/expo-slack-webhook/version.Commit=$(git rev-parse HEAD)"
+//
+// When they are not, we fall back to whatever the Go toolchain stamped into the binary.
+var (
+	Version   = ""
+	Commit    = ""
+	BuildDate = ""
+)
+
+// Info describes the running build.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"buildDate"`
+	Modified  bool   `json:"modified,omitempty"`
+}
+
+// Get determines the build info, preferring values injected with ldflags.
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, BuildDate: BuildDate}
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	if info.Version == "" && build.Main.Version != "(devel)" {
+		info.Version = build.Main.Version
+	}
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = setting.Value
+			}
+		case "vcs.time":
+			if info.BuildDate == "" {
+				info.BuildDate = setting.Value
+			}
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+	return info
+}
+
+func (i Info) String() string {
+	version := i.Version
+	if version == "" {
+		version = "unknown"
+	}
+	commit := i.Commit
+	if commit == "" {
+		commit = "unknown"
+	} else if i.Modified {
+		commit += "-dirty"
+	}
+	date := i.BuildDate
+	if date == "" {
+		date = "unknown"
+	}
+	return fmt.Sprintf("version %s, commit %s, built %s", version, commit, date)
+}
+
+// Handler serves the build info as JSON.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(Get()); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,55 @@
+package worker
+
+import (
+	"context"
+	"sync"
+)
+
+// Pool bounds how many payloads we process at once, so a burst of webhooks doesn't fan out into more
+// Expo and Slack calls than they'll tolerate. Work runs in the background so that webhooks can be
+// acknowledged immediately. A nil *Pool runs work inline instead, which is what the serverless
+// entrypoints need, as they are frozen as soon as they respond.
+type Pool struct {
+	slots chan struct{}
+	wg    sync.WaitGroup
+}
+
+// New creates a pool that runs at most size pieces of work at a time.
+func New(size int) *Pool {
+	return &Pool{slots: make(chan struct{}, size)}
+}
+
+// Go queues the work to run once a slot is free.
+func (p *Pool) Go(ctx context.Context, work func(ctx context.Context)) {
+	if p == nil {
+		work(ctx)
+		return
+	}
+	// the request context is cancelled once we respond, but the work needs to outlive it
+	ctx = context.WithoutCancel(ctx)
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.slots <- struct{}{}
+		defer func() { <-p.slots }()
+		work(ctx)
+	}()
+}
+
+// Wait blocks until all queued work has finished, or the context is done.
+func (p *Pool) Wait(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}